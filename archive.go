@@ -0,0 +1,145 @@
+package gormx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ArchivePolicy Archive 的选项
+type ArchivePolicy struct {
+	// 归档表，为空时为 <table>_archive，不存在时按原表的结构创建
+	TargetTable string
+	// 每批归档的条数，为0时为1000
+	BatchSize int
+	// 复制后从原表物理删除，复制和删除在同一个事务中
+	DeleteAfterCopy bool
+	// 每批之间等待的时间，为0时不等待
+	Interval time.Duration
+	// 每批提交后回调
+	OnProgress func(ArchiveProgress)
+}
+
+// ArchiveProgress 归档进度，LastPK 为最后一条已归档记录的主键，可以用于中断后按 pk > LastPK 继续
+type ArchiveProgress struct {
+	Copied  int64
+	Deleted int64
+	LastPK  any
+}
+
+// Archive 把满足条件的记录按主键顺序分批复制到归档表，用于把冷数据移出热表：
+//
+//	progress, err := repo.Archive(ctx, gormx.Conds(gormx.Field[time.Time]("create_at").Lt(cutoff)),
+//		gormx.ArchivePolicy{DeleteAfterCopy: true, OnProgress: func(p gormx.ArchiveProgress) { log.Printf("%+v", p) }})
+//
+// 每批在独立的事务中执行 INSERT INTO ... SELECT 和 DELETE，中途出错时已提交的批次不会回滚。
+// 条件不会过滤已软删除的记录，删除是物理删除；归档表与原表的列需要一致，
+// 不删除原表记录时重复执行会重复复制
+func (b *BaseRepo[T]) Archive(ctx context.Context, condition map[string]any, policy ArchivePolicy) (ArchiveProgress, error) {
+	var progress ArchiveProgress
+	s, err := b.gormSchema()
+	if err != nil {
+		return progress, err
+	}
+	if err = b.singlePK(); err != nil {
+		return progress, errors.WithMessage(err, "archive")
+	}
+	if policy.BatchSize <= 0 {
+		policy.BatchSize = 1000
+	}
+	target := policy.TargetTable
+	if target == "" {
+		target = s.Table + "_archive"
+	}
+	db := b.withTransactionCtx(ctx)
+	if !db.Migrator().HasTable(target) {
+		if err = db.Exec(archiveTableDDL(db.Dialector.Name(), db.Statement.Quote(target), db.Statement.Quote(s.Table))).Error; err != nil {
+			return progress, errors.Wrapf(err, "db: create %s archive table %s error", b.StructName, target)
+		}
+	}
+
+	columns := make([]string, 0, len(s.DBNames))
+	for _, name := range s.DBNames {
+		columns = append(columns, db.Statement.Quote(name))
+	}
+	pk := db.Statement.Quote(b.PrimaryKey)
+	copySQL := fmt.Sprintf("INSERT INTO %s (%s) SELECT %[2]s FROM %s WHERE %s IN ?",
+		db.Statement.Quote(target), strings.Join(columns, ", "), db.Statement.Quote(s.Table), pk)
+	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE %s IN ?", db.Statement.Quote(s.Table), pk)
+
+	c := b.columnKeys(condition)
+	for {
+		var (
+			m   T
+			pks []any
+		)
+		query := b.withTransactionCtx(ctx).Unscoped().Model(&m).Scopes(whereCond(c))
+		if progress.LastPK != nil {
+			query = query.Where(pk+" > ?", progress.LastPK)
+		}
+		if err = query.Order(pk).Limit(policy.BatchSize).Pluck(b.PrimaryKey, &pks).Error; err != nil {
+			return progress, errors.Wrapf(err, "db: archive %s error, select pks after %v, condition: %v", b.StructName, progress.LastPK, condition)
+		}
+		if len(pks) == 0 {
+			return progress, nil
+		}
+		var copied, deleted int64
+		err = b.InTx(ctx, func(ctx context.Context) error {
+			tx := b.withTransactionCtx(ctx).Exec(copySQL, pks)
+			if tx.Error != nil {
+				return tx.Error
+			}
+			copied = tx.RowsAffected
+			if !policy.DeleteAfterCopy {
+				return nil
+			}
+			tx = b.withTransactionCtx(ctx).Exec(deleteSQL, pks)
+			deleted = tx.RowsAffected
+			return tx.Error
+		})
+		if err != nil {
+			return progress, errors.Wrapf(err, "db: archive %s to %s error, pks: %v", b.StructName, target, pks)
+		}
+		if policy.DeleteAfterCopy {
+			if err = b.invalidateCache(ctx, pks, nil); err != nil {
+				return progress, err
+			}
+		}
+		progress.Copied += copied
+		progress.Deleted += deleted
+		progress.LastPK = pks[len(pks)-1]
+		if policy.OnProgress != nil {
+			policy.OnProgress(progress)
+		}
+		if len(pks) < policy.BatchSize {
+			return progress, nil
+		}
+		if policy.Interval > 0 {
+			select {
+			case <-ctx.Done():
+				return progress, errors.Wrapf(ctx.Err(), "db: archive %s canceled, copied: %d", b.StructName, progress.Copied)
+			case <-time.After(policy.Interval):
+			}
+		}
+	}
+}
+
+// archiveTableDDL 按原表的结构创建归档表。不能按模型 CreateTable：postgres 的索引名在schema内全局唯一，
+// 沿用模型的索引名会与原表冲突
+//
+// mysql 的索引名只在表内唯一，LIKE 复制列和索引；postgres 的 LIKE INCLUDING ALL 按默认规则重新生成索引名；
+// 其他数据库只复制列，不创建索引
+func archiveTableDDL(dialect, target, source string) string {
+	switch dialect {
+	case "mysql":
+		return fmt.Sprintf("CREATE TABLE %s LIKE %s", target, source)
+	case "postgres":
+		return fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING ALL)", target, source)
+	case "sqlserver":
+		return fmt.Sprintf("SELECT * INTO %s FROM %s WHERE 1 = 0", target, source)
+	}
+	return fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM %s WHERE 1 = 0", target, source)
+}
@@ -0,0 +1,68 @@
+package gormx
+
+import (
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ArrayCondition postgres 数组列的条件，通过 ArrayContains、ArrayOverlaps 构造，作为map条件的值使用，key为数组列：
+//
+//	posts, err := repo.SelectByMap(ctx, map[string]any{"tags": gormx.ArrayContains("go", "db")})
+//
+// 只支持 postgres，其他数据库执行时返回错误；使用数组条件的查询不走查询缓存
+type ArrayCondition struct {
+	op     string
+	values []any
+	column string
+}
+
+// ArrayContains 数组列包含所有values，column @> ARRAY[values]
+func ArrayContains[V any](values ...V) ArrayCondition {
+	return newArrayCondition("@>", values)
+}
+
+// ArrayOverlaps 数组列与values有交集，column && ARRAY[values]
+func ArrayOverlaps[V any](values ...V) ArrayCondition {
+	return newArrayCondition("&&", values)
+}
+
+func newArrayCondition[V any](op string, values []V) ArrayCondition {
+	c := ArrayCondition{op: op, values: make([]any, 0, len(values))}
+	for _, v := range values {
+		c.values = append(c.values, v)
+	}
+	return c
+}
+
+func (c ArrayCondition) condOn(column string) clause.Expression {
+	c.column = column
+	return c
+}
+
+// Build 实现 clause.Expression
+func (c ArrayCondition) Build(builder clause.Builder) {
+	stmt, ok := builder.(*gorm.Statement)
+	if !ok {
+		return
+	}
+	if name := stmt.Dialector.Name(); name != "postgres" {
+		_ = stmt.AddError(errors.Errorf("db: array condition %s on %s requires postgres, got %s", c.op, c.column, name))
+		return
+	}
+	builder.WriteQuoted(clause.Column{Name: c.column})
+	builder.WriteString(" " + c.op + " ")
+	if len(c.values) == 0 {
+		// 空的 ARRAY[] 无法推断类型，使用字面量
+		builder.WriteString("'{}'")
+		return
+	}
+	builder.WriteString("ARRAY[")
+	for i, v := range c.values {
+		if i > 0 {
+			builder.WriteByte(',')
+		}
+		builder.AddVar(builder, v)
+	}
+	builder.WriteByte(']')
+}
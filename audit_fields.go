@@ -0,0 +1,121 @@
+package gormx
+
+import (
+	"context"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ModelAuditInfo 创建人和最后修改人，与 ModelBaseInfo 一起嵌入模型，由 AuditFieldsPlugin 自动填充
+type ModelAuditInfo struct {
+	CreateBy string `gorm:"column:create_by;NOT NULL;default:''" json:"create_by"` // 创建人
+	UpdateBy string `gorm:"column:update_by;NOT NULL;default:''" json:"update_by"` // 最后修改人
+}
+
+const (
+	createByColumn = "create_by"
+	updateByColumn = "update_by"
+)
+
+type contextOperatorKey struct{}
+
+// WithOperator 在ctx中设置当前操作人，例如在http中间件中设置登录用户的ID
+func WithOperator(ctx context.Context, operator string) context.Context {
+	return context.WithValue(ctx, contextOperatorKey{}, operator)
+}
+
+// OperatorFromCtx 取 WithOperator 设置的操作人，没有时返回空字符串
+func OperatorFromCtx(ctx context.Context) string {
+	operator, _ := ctx.Value(contextOperatorKey{}).(string)
+	return operator
+}
+
+// AuditFieldsPlugin gorm插件，插入时填充 create_by 和 update_by，更新时填充 update_by，只处理有这些列的模型
+//
+//	db.Use(gormx.AuditFieldsPlugin{})
+//
+// Operator为nil时使用 OperatorFromCtx；操作人为空时不填充。插入时已经赋值的 create_by 不会被覆盖，
+// 更新时 updateData 中带有 update_by 时以 updateData 为准；BatchUpdateByPK 直接执行SQL，
+// 需要自行给记录的 UpdateBy 赋值并在columns中带上 update_by
+type AuditFieldsPlugin struct {
+	Operator func(ctx context.Context) string
+}
+
+func (AuditFieldsPlugin) Name() string {
+	return "gormx:audit_fields"
+}
+
+func (p AuditFieldsPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("gormx:audit_fields", p.beforeCreate); err != nil {
+		return err
+	}
+	return cb.Update().Before("gorm:update").Register("gormx:audit_fields", p.beforeUpdate)
+}
+
+func (p AuditFieldsPlugin) operator(ctx context.Context) string {
+	if p.Operator != nil {
+		return p.Operator(ctx)
+	}
+	return OperatorFromCtx(ctx)
+}
+
+func (p AuditFieldsPlugin) beforeCreate(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	operator := p.operator(stmt.Context)
+	if operator == "" {
+		return
+	}
+	for _, name := range []string{createByColumn, updateByColumn} {
+		field := stmt.Schema.LookUpField(name)
+		if field == nil {
+			continue
+		}
+		switch stmt.ReflectValue.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < stmt.ReflectValue.Len(); i++ {
+				setIfZero(db, field, reflect.Indirect(stmt.ReflectValue.Index(i)), operator)
+			}
+		case reflect.Struct:
+			setIfZero(db, field, stmt.ReflectValue, operator)
+		}
+	}
+}
+
+func setIfZero(db *gorm.DB, field *schema.Field, rv reflect.Value, value any) {
+	if _, zero := field.ValueOf(db.Statement.Context, rv); !zero {
+		return
+	}
+	if err := field.Set(db.Statement.Context, rv, value); err != nil {
+		_ = db.AddError(err)
+	}
+}
+
+func (p AuditFieldsPlugin) beforeUpdate(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.Schema.LookUpField(updateByColumn) == nil {
+		return
+	}
+	if dest, ok := stmt.Dest.(map[string]any); ok {
+		if _, set := dest[updateByColumn]; set {
+			return
+		}
+		if _, set := dest["UpdateBy"]; set {
+			return
+		}
+	}
+	operator := p.operator(stmt.Context)
+	if operator == "" {
+		return
+	}
+	stmt.SetColumn(updateByColumn, operator, true)
+	// 指定了更新的列时（例如 Save），把 update_by 加入更新的列
+	if len(stmt.Selects) > 0 && stmt.Selects[0] != "*" {
+		stmt.Selects = append(stmt.Selects, updateByColumn)
+	}
+}
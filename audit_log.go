@@ -0,0 +1,276 @@
+package gormx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AuditLog 审计日志，Diff为变更的json，格式为 {"列名": {"old": 旧值, "new": 新值}}，插入只有new，删除只有old
+type AuditLog struct {
+	ID        int64     `gorm:"column:id;primaryKey;autoIncrement"`
+	Table     string    `gorm:"column:table_name;size:128;NOT NULL;index:idx_gormx_audit_table_pk"`
+	PK        string    `gorm:"column:pk;size:128;NOT NULL;index:idx_gormx_audit_table_pk"`
+	Operation string    `gorm:"column:operation;size:16;NOT NULL"`
+	Diff      string    `gorm:"column:diff;type:text"`
+	Operator  string    `gorm:"column:operator;size:128;NOT NULL;default:''"`
+	CreateAt  time.Time `gorm:"column:create_at;NOT NULL;index"`
+}
+
+func (AuditLog) TableName() string {
+	return "gormx_audit_log"
+}
+
+// 审计日志的操作类型
+const (
+	AuditInsert = "insert"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// MigrateAuditLog 创建审计日志表
+func MigrateAuditLog(db *gorm.DB) error {
+	if err := db.AutoMigrate(&AuditLog{}); err != nil {
+		return errors.Wrap(err, "db: migrate audit log error")
+	}
+	return nil
+}
+
+// WithAuditLog 把repo的 Insert、Update、Delete 记录到审计日志表，需要注册 AuditLogPlugin
+func WithAuditLog() Option {
+	return func(o *options) {
+		o.audit = true
+	}
+}
+
+const (
+	auditSettingKey = "gormx:audit"
+	auditOldRowsKey = "gormx:audit_old_rows"
+)
+
+// AuditLogPlugin gorm插件，把开启了 WithAuditLog 的repo的写操作记录到 gormx_audit_log，表通过 MigrateAuditLog 创建
+//
+//	db.Use(gormx.AuditLogPlugin{})
+//	repo := gormx.NewBaseRepo[Order](db, gormx.WithAuditLog())
+//
+// 更新和删除前会查出受影响的记录（mysql、postgres 加行锁），更新后再按主键查出新值计算差异；
+// 审计日志与写操作在同一个事务中写入（不在事务中且关闭了gorm默认事务时为单独的语句），写入失败时写操作返回错误
+// 直接执行SQL的方法（BatchUpdateByPK、RawExec等）不记录
+type AuditLogPlugin struct {
+	// 操作人，为nil时使用 OperatorFromCtx
+	Operator func(ctx context.Context) string
+}
+
+func (AuditLogPlugin) Name() string {
+	return "gormx:audit_log"
+}
+
+func (p AuditLogPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	name := "gormx:audit_log"
+	if err := cb.Create().After("gorm:create").Before("gorm:commit_or_rollback_transaction").Register(name, p.afterCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:begin_transaction").Before("gorm:update").Register(name+":before", p.loadOld); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Before("gorm:commit_or_rollback_transaction").Register(name, p.afterUpdate); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:begin_transaction").Before("gorm:delete").Register(name+":before", p.loadOld); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Before("gorm:commit_or_rollback_transaction").Register(name, p.afterDelete)
+}
+
+func auditEnabled(db *gorm.DB) bool {
+	if db.Error != nil || db.DryRun || db.Statement.Schema == nil || db.Statement.Schema.PrioritizedPrimaryField == nil {
+		return false
+	}
+	v, ok := db.Get(auditSettingKey)
+	return ok && v == true
+}
+
+func (p AuditLogPlugin) afterCreate(db *gorm.DB) {
+	if !auditEnabled(db) || db.RowsAffected == 0 {
+		return
+	}
+	stmt := db.Statement
+	var rows []reflect.Value
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			rows = append(rows, reflect.Indirect(stmt.ReflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		rows = append(rows, stmt.ReflectValue)
+	}
+	logs := make([]AuditLog, 0, len(rows))
+	for _, rv := range rows {
+		diff := make(map[string]auditChange, len(stmt.Schema.DBNames))
+		for _, name := range stmt.Schema.DBNames {
+			// 零值的列由数据库默认值填充，插入后不一定回填到模型上，不记录
+			if v, zero := stmt.Schema.FieldsByDBName[name].ValueOf(stmt.Context, rv); !zero {
+				diff[name] = auditChange{New: v}
+			}
+		}
+		pk, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(stmt.Context, rv)
+		logs = append(logs, p.newLog(db, AuditInsert, pk, diff))
+	}
+	p.write(db, logs)
+}
+
+// statementWhere 更新、删除语句最终的条件，与gorm生成的一致：WHERE子句加上模型的非零主键，没有条件时返回false
+func statementWhere(stmt *gorm.Statement) (clause.Where, bool) {
+	var where clause.Where
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if w, ok := c.Expression.(clause.Where); ok {
+			where.Exprs = append(where.Exprs, w.Exprs...)
+		}
+	}
+	if stmt.ReflectValue.Kind() == reflect.Struct {
+		for _, field := range stmt.Schema.PrimaryFields {
+			if v, zero := field.ValueOf(stmt.Context, stmt.ReflectValue); !zero {
+				where.Exprs = append(where.Exprs, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: v})
+			}
+		}
+	}
+	return where, len(where.Exprs) > 0
+}
+
+// loadOld 更新、删除前查出受影响的记录
+func (p AuditLogPlugin) loadOld(db *gorm.DB) {
+	if !auditEnabled(db) {
+		return
+	}
+	stmt := db.Statement
+	where, ok := statementWhere(stmt)
+	if !ok {
+		return
+	}
+	query := db.Session(&gorm.Session{NewDB: true}).Table(stmt.Table).Clauses(where)
+	if name := db.Dialector.Name(); name == "mysql" || name == "postgres" {
+		query = query.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate})
+	}
+	var old []map[string]any
+	if err := query.Find(&old).Error; err != nil {
+		_ = db.AddError(errors.Wrapf(err, "db: audit %s load rows error", stmt.Table))
+		return
+	}
+	db.InstanceSet(auditOldRowsKey, old)
+}
+
+func oldRows(db *gorm.DB) []map[string]any {
+	v, ok := db.InstanceGet(auditOldRowsKey)
+	if !ok {
+		return nil
+	}
+	return v.([]map[string]any)
+}
+
+func (p AuditLogPlugin) afterUpdate(db *gorm.DB) {
+	old := oldRows(db)
+	if !auditEnabled(db) || db.RowsAffected == 0 || len(old) == 0 {
+		return
+	}
+	stmt := db.Statement
+	pkName := stmt.Schema.PrioritizedPrimaryField.DBName
+	pks := make([]any, 0, len(old))
+	for _, row := range old {
+		pks = append(pks, row[pkName])
+	}
+	var current []map[string]any
+	if err := db.Session(&gorm.Session{NewDB: true}).Table(stmt.Table).Where(map[string]any{pkName: pks}).Find(&current).Error; err != nil {
+		_ = db.AddError(errors.Wrapf(err, "db: audit %s load rows error", stmt.Table))
+		return
+	}
+	byPK := make(map[string]map[string]any, len(current))
+	for _, row := range current {
+		byPK[cacheKeyPart(auditValue(row[pkName]))] = row
+	}
+	logs := make([]AuditLog, 0, len(old))
+	for _, before := range old {
+		pk := auditValue(before[pkName])
+		after, ok := byPK[cacheKeyPart(pk)]
+		if !ok {
+			continue
+		}
+		diff := make(map[string]auditChange)
+		for _, col := range SortedKeys(after) {
+			o, n := auditValue(before[col]), auditValue(after[col])
+			if !reflect.DeepEqual(o, n) {
+				diff[col] = auditChange{Old: o, New: n}
+			}
+		}
+		if len(diff) > 0 {
+			logs = append(logs, p.newLog(db, AuditUpdate, pk, diff))
+		}
+	}
+	p.write(db, logs)
+}
+
+func (p AuditLogPlugin) afterDelete(db *gorm.DB) {
+	old := oldRows(db)
+	if !auditEnabled(db) || db.RowsAffected == 0 || len(old) == 0 {
+		return
+	}
+	pkName := db.Statement.Schema.PrioritizedPrimaryField.DBName
+	logs := make([]AuditLog, 0, len(old))
+	for _, row := range old {
+		diff := make(map[string]auditChange, len(row))
+		for col, v := range row {
+			diff[col] = auditChange{Old: auditValue(v)}
+		}
+		logs = append(logs, p.newLog(db, AuditDelete, auditValue(row[pkName]), diff))
+	}
+	p.write(db, logs)
+}
+
+type auditChange struct {
+	Old any `json:"old,omitempty"`
+	New any `json:"new,omitempty"`
+}
+
+// auditValue mysql 驱动以 []byte 返回字符串等类型的列
+func auditValue(v any) any {
+	if bs, ok := v.([]byte); ok {
+		return string(bs)
+	}
+	return v
+}
+
+func (p AuditLogPlugin) newLog(db *gorm.DB, op string, pk any, diff map[string]auditChange) AuditLog {
+	ctx := db.Statement.Context
+	operator := OperatorFromCtx(ctx)
+	if p.Operator != nil {
+		operator = p.Operator(ctx)
+	}
+	bs, err := json.Marshal(diff)
+	if err != nil {
+		_ = db.AddError(errors.Wrapf(err, "db: audit %s marshal diff error", db.Statement.Table))
+	}
+	return AuditLog{
+		Table:     db.Statement.Table,
+		PK:        fmt.Sprint(pk),
+		Operation: op,
+		Diff:      string(bs),
+		Operator:  operator,
+		CreateAt:  time.Now(),
+	}
+}
+
+// write 在同一个连接（事务）上写入审计日志
+func (p AuditLogPlugin) write(db *gorm.DB, logs []AuditLog) {
+	if len(logs) == 0 || db.Error != nil {
+		return
+	}
+	if err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Create(&logs).Error; err != nil {
+		_ = db.AddError(errors.Wrapf(err, "db: write %s audit log error", db.Statement.Table))
+	}
+}
@@ -0,0 +1,77 @@
+package gormx
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UpdateTimeMode UpdateByMap 时更新时间列的处理方式
+type UpdateTimeMode int
+
+const (
+	// UpdateTimeKeep 不设置，由数据库的 ON UPDATE CURRENT_TIMESTAMP 或gorm的 autoUpdateTime 处理
+	UpdateTimeKeep UpdateTimeMode = iota
+	// UpdateTimeGo 设置为应用的当前时间 time.Now()
+	UpdateTimeGo
+	// UpdateTimeDB 设置为数据库的当前时间 CURRENT_TIMESTAMP
+	UpdateTimeDB
+)
+
+// defaultUpdateTimeColumn ModelBaseInfo 中更新时间的列名
+const defaultUpdateTimeColumn = "update_at"
+
+// WithAutoUpdateTime UpdateByMap、UpdateByPKWithMap、IncrementByPK 等按map更新时自动设置更新时间列，
+// 适用于没有 ON UPDATE CURRENT_TIMESTAMP 的表；column为空时为 update_at，模型没有该列时不设置。
+// updateData中已经带有该列时以updateData为准，单次调用可以通过 WithUpdateTimeMode 覆盖
+func WithAutoUpdateTime(mode UpdateTimeMode, column string) Option {
+	return func(o *options) {
+		if column == "" {
+			column = defaultUpdateTimeColumn
+		}
+		o.updateTimeMode = mode
+		o.updateTimeColumn = column
+	}
+}
+
+type contextUpdateTimeModeKey struct{}
+
+// WithUpdateTimeMode 覆盖本次调用更新时间列的处理方式，例如数据修复时传入 UpdateTimeKeep 保留原来的更新时间
+func WithUpdateTimeMode(ctx context.Context, mode UpdateTimeMode) context.Context {
+	return context.WithValue(ctx, contextUpdateTimeModeKey{}, mode)
+}
+
+// touchUpdateTime 按配置在updateData中加入更新时间列，不修改调用方的map
+func (b *BaseRepo[T]) touchUpdateTime(ctx context.Context, updateData map[string]any) map[string]any {
+	mode := b.opts.updateTimeMode
+	if m, ok := ctx.Value(contextUpdateTimeModeKey{}).(UpdateTimeMode); ok {
+		mode = m
+	}
+	if mode == UpdateTimeKeep || len(updateData) == 0 {
+		return updateData
+	}
+	column := b.opts.updateTimeColumn
+	if column == "" {
+		column = defaultUpdateTimeColumn
+	}
+	f, err := b.lookupColumn(column)
+	if err != nil {
+		return updateData
+	}
+	for k := range updateData {
+		if k == f.Name || k == f.DBName || Camel2Snake(k) == f.DBName {
+			return updateData
+		}
+	}
+	data := make(map[string]any, len(updateData)+1)
+	for k, v := range updateData {
+		data[k] = v
+	}
+	if mode == UpdateTimeDB {
+		data[f.DBName] = gorm.Expr("CURRENT_TIMESTAMP")
+	} else {
+		data[f.DBName] = time.Now()
+	}
+	return data
+}
@@ -3,11 +3,14 @@ package gormx
 import (
 	"context"
 	"go/ast"
+	"maps"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/schema"
 	"gorm.io/gorm/utils"
 )
@@ -17,51 +20,153 @@ type BaseRepo[T any] struct {
 	// 泛型参数代表的struct名称，例如：BaseRepo[Pop]
 	StructName string
 	PrimaryKey string
+
+	opts  options
+	debug bool
 }
 
 // NewBaseRepo 这个函数的意义在于不暴露db进行初始化，外部只能通过函数DB()获取
-func NewBaseRepo[T any](db *gorm.DB) BaseRepo[T] {
+// T可以是struct或者指向struct的指针（BaseRepo[*Pop]），模型中嵌入的匿名结构体可以是指针
+func NewBaseRepo[T any](db *gorm.DB, opts ...Option) BaseRepo[T] {
 	b := BaseRepo[T]{
 		GormDB: db,
 	}
-	var m T
-	b.StructName = reflect.ValueOf(m).Type().Name()
-	b.PrimaryKey = b.parsePrimaryKey()
+	for _, opt := range opts {
+		opt(&b.opts)
+	}
+	ms := b.Schema()
+	b.StructName = ms.Name
+	b.PrimaryKey = ms.PrimaryKey
 	return b
 }
 
 // WithTransactionCtx 和事务相关的db操作，在取db连接时均采用此方法
 func (b *BaseRepo[T]) withTransactionCtx(ctx context.Context) *gorm.DB {
+	b.markWrite(ctx)
+	db := b.withDebug(dbWithCtx(ctx, b.GormDB))
+	if b.opts.audit {
+		db = db.Set(auditSettingKey, true)
+	}
+	if b.opts.history {
+		db = db.Set(historySettingKey, true)
+	}
+	return b.preflight(ctx, b.withSettings(db)).Session(&gorm.Session{})
+}
+
+// DB ctx中存在事务时返回事务，否则返回repo的db，带有repo的配置；供包外的查询（例如 gormxtest 中的断言）参与ctx中的事务
+func (b *BaseRepo[T]) DB(ctx context.Context) *gorm.DB {
+	return b.withTransactionCtx(ctx)
+}
+
+// withSettings 读写共用的repo配置，由插件在回调中读取
+//
+// Set、Clauses 返回的db会在同一个Statement上累加条件，最后开启新的会话，
+// 调用方在返回的db上执行多条语句时互不影响
+func (b *BaseRepo[T]) withSettings(db *gorm.DB) *gorm.DB {
+	if b.opts.strict {
+		db = db.Set(strictSettingKey, true)
+	}
+	if b.opts.circuitBreaker != nil {
+		db = db.Set(circuitSettingKey, b.opts.circuitBreaker)
+	}
+	if b.opts.limiter != nil {
+		db = db.Set(limiterSettingKey, b.opts.limiter)
+	}
+	return db.Session(&gorm.Session{})
+}
+
+// Debug 返回浅拷贝的repo，通过它执行的操作打印完整SQL，不影响原repo和全局的日志级别：
+//
+//	n, err := repo.Debug().UpdateByMap(ctx, condition, updateData)
+//
+// ctx中的事务和读库同样打印；在 Debug 的repo上开启的事务，事务内其他repo的操作也会打印
+func (b *BaseRepo[T]) Debug() *BaseRepo[T] {
+	c := *b
+	c.GormDB = b.GormDB.Debug()
+	c.debug = true
+	return &c
+}
+
+func (b *BaseRepo[T]) withDebug(db *gorm.DB) *gorm.DB {
+	if b.debug {
+		return db.Debug()
+	}
+	return db
+}
+
+// dbWithCtx ctx中存在事务时返回事务，否则返回db，供包级别的函数复用事务
+func dbWithCtx(ctx context.Context, db *gorm.DB) *gorm.DB {
 	tx, ok := ctx.Value(contextTxKey{}).(*gorm.DB)
 	if ok {
-		return tx
+		return withDryRun(ctx, tx)
 	}
-	return b.GormDB.WithContext(ctx)
+	return withDryRun(ctx, db.WithContext(ctx))
 }
 
 // InTx fn是包含了事务操作的方法，只要fn里面有异常，里面的db操作都会回滚
+// 在事务内嵌套调用时通过SAVEPOINT实现，fn出错只回滚到SAVEPOINT，见 TxNested
 func (b *BaseRepo[T]) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
-	return b.GormDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		ctx = context.WithValue(ctx, contextTxKey{}, tx)
-		return fn(ctx)
-	})
+	return b.InTxPropagation(ctx, TxNested, fn)
 }
 
-func (b *BaseRepo[T]) parsePrimaryKey() string {
+// gormSchema 由gorm解析的模型schema，gorm内部会缓存解析结果
+func (b *BaseRepo[T]) gormSchema() (*schema.Schema, error) {
 	var m T
-	return recursiveParsePrimaryKey(reflect.ValueOf(m))
+	stmt := &gorm.Statement{DB: b.GormDB}
+	if err := stmt.Parse(&m); err != nil {
+		return nil, errors.Wrapf(err, "db: parse %s schema error", b.StructName)
+	}
+	return stmt.Schema, nil
+}
+
+func (b *BaseRepo[T]) tableName() string {
+	s, err := b.gormSchema()
+	if err != nil {
+		return Camel2Snake(b.StructName)
+	}
+	return s.Table
+}
+
+// pkValue 取出记录的主键值，主键为零值时返回false
+func (b *BaseRepo[T]) pkValue(ctx context.Context, m *T) (any, bool) {
+	s, err := b.gormSchema()
+	if err != nil || m == nil {
+		return nil, false
+	}
+	field := s.LookUpField(b.PrimaryKey)
+	if field == nil {
+		return nil, false
+	}
+	v, isZero := field.ValueOf(ctx, indirectModel(reflect.ValueOf(m)))
+	return v, !isZero
+}
+
+// lookupColumn 按字段名或列名（兼容驼峰和蛇形）查找模型字段，不存在时返回 ErrUnknownColumn
+func (b *BaseRepo[T]) lookupColumn(name string) (*schema.Field, error) {
+	s, err := b.gormSchema()
+	if err != nil {
+		return nil, err
+	}
+	f := schemaColumn(s, name)
+	if f == nil {
+		return nil, errors.Wrapf(ErrUnknownColumn, "db: %s column: %s", b.StructName, name)
+	}
+	return f, nil
 }
 
-func recursiveParsePrimaryKey(reflectValue reflect.Value) string {
-	reflectType := IndirectType(reflectValue.Type())
-	var hasId bool
+// recursiveParsePrimaryKeys 按类型解析带有 primaryKey 标签的列，嵌入的匿名结构体可以是指针；
+// hasId 为是否有名为id的列，没有带标签的列时以id为主键
+func recursiveParsePrimaryKeys(reflectType reflect.Type) (keys []string, hasId bool) {
+	reflectType = IndirectType(reflectType)
+	if reflectType.Kind() != reflect.Struct {
+		return nil, false
+	}
 	for i := 0; i < reflectType.NumField(); i++ {
 		if fieldStruct := reflectType.Field(i); ast.IsExported(fieldStruct.Name) {
 			if fieldStruct.Anonymous {
-				res := recursiveParsePrimaryKey(reflectValue.Field(i))
-				if res != "" {
-					return res
-				}
+				res, id := recursiveParsePrimaryKeys(fieldStruct.Type)
+				keys = append(keys, res...)
+				hasId = hasId || id
 			} else {
 				tagSetting := schema.ParseTagSetting(fieldStruct.Tag.Get("gorm"), ";")
 
@@ -72,7 +177,7 @@ func recursiveParsePrimaryKey(reflectValue reflect.Value) string {
 				}
 
 				if utils.CheckTruth(tagSetting["PRIMARYKEY"], tagSetting["PRIMARY_KEY"]) {
-					return columnName
+					keys = append(keys, columnName)
 				}
 
 				if columnName == "id" {
@@ -81,32 +186,38 @@ func recursiveParsePrimaryKey(reflectValue reflect.Value) string {
 			}
 		}
 	}
-	if hasId {
-		return "id"
-	}
-	return ""
+	return keys, hasId
 }
 
 // Insert 插入单条记录
 func (b *BaseRepo[T]) Insert(ctx context.Context, m *T) (err error) {
+	if err = b.assignIDs(ctx, m); err != nil {
+		return err
+	}
 	if err = b.withTransactionCtx(ctx).Create(m).Error; err != nil {
-		err = errors.Wrapf(err, "db: insert %s error, param: %+v", b.StructName, m)
+		return errors.Wrapf(err, "db: insert %s error, param: %+v", b.StructName, m)
 	}
-	return
+	return b.invalidateInserted(ctx, m)
 }
 
 // BatchInsert 批量插入
 // 注：需要根据插入数据的大小来设置batchSize
 func (b *BaseRepo[T]) BatchInsert(ctx context.Context, m []*T, batchSize int) (int64, error) {
+	if err := b.assignIDs(ctx, m...); err != nil {
+		return 0, err
+	}
 	tx := b.withTransactionCtx(ctx).CreateInBatches(m, batchSize)
 	if tx.Error != nil {
 		return 0, errors.Wrapf(tx.Error, "db: batch insert %s error, param: %+v", b.StructName, m)
 	}
-	return tx.RowsAffected, nil
+	return tx.RowsAffected, b.invalidateInserted(ctx, m...)
 }
 
 // DeleteByPK 根据主键删除，支持单个主键或者一个主键数组
 func (b *BaseRepo[T]) DeleteByPK(ctx context.Context, pks any) (int64, error) {
+	if err := b.singlePK(); err != nil {
+		return 0, errors.WithMessage(err, "delete by pk")
+	}
 	var m T
 	tx := b.withTransactionCtx(ctx).Where(map[string]any{
 		b.PrimaryKey: pks,
@@ -114,38 +225,117 @@ func (b *BaseRepo[T]) DeleteByPK(ctx context.Context, pks any) (int64, error) {
 	if err := tx.Error; err != nil {
 		return 0, errors.Wrapf(err, "db: delete %s by pks error, pks: %v", b.StructName, pks)
 	}
-	return tx.RowsAffected, nil
+	return tx.RowsAffected, b.invalidateCache(ctx, Interface2Array(pks), nil)
 }
 
 // DeleteByMap 根据条件删除，支持零值
 // condition示例：{"name","张三"}
 // condition里的key兼容驼峰和蛇形
 func (b *BaseRepo[T]) DeleteByMap(ctx context.Context, condition map[string]any) (int64, error) {
-	c := camel2SnakeForMapKey(condition)
+	c := b.columnKeys(condition)
+	pks, err := b.affectedPKs(ctx, c)
+	if err != nil {
+		return 0, err
+	}
 	var m T
-	tx := b.withTransactionCtx(ctx).Where(c).Delete(&m)
+	tx := b.withTransactionCtx(ctx).Scopes(whereCond(c)).Delete(&m)
 	if err := tx.Error; err != nil {
 		return 0, errors.Wrapf(err, "db: delete %s by map error, condition: %v", b.StructName, condition)
 	}
-	return tx.RowsAffected, nil
+	return tx.RowsAffected, b.invalidateCache(ctx, pks, nil)
 }
 
 // UpdateByPK 根据主键更新非空字段
+// 模型带有 `gormx:"version"` 标签的字段时使用乐观锁，版本号不一致时返回 ErrStaleObject
 func (b *BaseRepo[T]) UpdateByPK(ctx context.Context, t *T) (int64, error) {
-	tx := b.withTransactionCtx(ctx).Model(t).Updates(t)
+	var rows int64
+	if vf := b.versionField(); vf != nil {
+		n, err := b.updateByPKVersioned(ctx, t, vf)
+		if err != nil {
+			return 0, err
+		}
+		rows = n
+	} else {
+		tx := b.withTransactionCtx(ctx).Model(t).Updates(t)
+		if err := tx.Error; err != nil {
+			return 0, errors.Wrapf(err, "db: update %s by pk error, param: %+v", b.StructName, t)
+		}
+		rows = tx.RowsAffected
+	}
+	if pk, ok := b.pkValue(ctx, t); ok {
+		return rows, b.invalidateCache(ctx, []any{pk}, b.rowColumns(ctx, t, false))
+	}
+	return rows, nil
+}
+
+// UpdateByPKSelect 根据主键只更新fields中的字段，零值同样写入，例如把计数清零、清空字符串：
+//
+//	u.LoginFailures, u.Remark = 0, ""
+//	n, err := repo.UpdateByPKSelect(ctx, u, []string{UserFields.LoginFailures.Column(), "Remark"})
+//
+// fields 为字段名或列名，模型中不存在时返回 ErrUnknownColumn，不能包含主键；带有版本号字段时同样使用乐观锁
+func (b *BaseRepo[T]) UpdateByPKSelect(ctx context.Context, t *T, fields []string) (int64, error) {
+	if err := b.singlePK(); err != nil {
+		return 0, errors.WithMessage(err, "update by pk select")
+	}
+	pk, ok := b.pkValue(ctx, t)
+	if !ok {
+		return 0, errors.Errorf("db: update %s by pk select error, primary key is zero, param: %+v", b.StructName, t)
+	}
+	if len(fields) == 0 {
+		return 0, errors.Errorf("db: update %s by pk select error, fields is empty", b.StructName)
+	}
+	rv := indirectModel(reflect.ValueOf(t))
+	updateData := make(map[string]any, len(fields))
+	for _, name := range fields {
+		f, err := b.lookupColumn(name)
+		if err != nil {
+			return 0, errors.WithMessage(err, "update by pk select")
+		}
+		if f.PrimaryKey {
+			return 0, errors.Errorf("db: update %s by pk select error, can not update primary key %s", b.StructName, f.DBName)
+		}
+		updateData[f.DBName], _ = f.ValueOf(ctx, rv)
+	}
+	// 写入后的列值，用于失效缓存
+	columns := maps.Clone(updateData)
+	db := b.withTransactionCtx(ctx).Model(t)
+	vf := b.versionField()
+	var version any
+	if vf != nil {
+		version, _ = vf.ValueOf(ctx, rv)
+		column := db.Statement.Quote(vf.DBName)
+		updateData[vf.DBName] = gorm.Expr(column + " + 1")
+		db = db.Where(column+" = ?", version)
+	}
+	tx := db.Updates(updateData)
 	if err := tx.Error; err != nil {
-		return 0, errors.Wrapf(err, "db: update %s by pk error, param: %+v", b.StructName, t)
+		return 0, errors.Wrapf(err, "db: update %s by pk select error, fields: %v, param: %+v", b.StructName, fields, t)
 	}
-	return tx.RowsAffected, nil
+	if vf != nil {
+		if tx.RowsAffected == 0 {
+			return 0, errors.Wrapf(ErrStaleObject, "db: update %s by pk select error, version: %v, param: %+v", b.StructName, version, t)
+		}
+		fv := Indirect(rv.FieldByIndex(vf.StructField.Index))
+		if fv.CanInt() {
+			fv.SetInt(fv.Int() + 1)
+		} else if fv.CanUint() {
+			fv.SetUint(fv.Uint() + 1)
+		}
+	}
+	return tx.RowsAffected, b.invalidateCache(ctx, []any{pk}, columns)
 }
 
 // UpdateByPKWithMap 根据id更新，支持零值
 //
-// updateData示例：{"age","18"}
+// updateData示例：{"age","18"}，值可以是 Expr 表达式：{"price": gormx.Expr("price * ?", 1.1)}
 //
 // 注：这里会删除updateData里的以下字段
-// 1、带有gorm标签：autoCreateTime、autoUpdateTime的字段
+// 1、带有gorm标签：autoCreateTime、autoUpdateTime的字段，key为字段名或列名均会删除
 func (b *BaseRepo[T]) UpdateByPKWithMap(ctx context.Context, pk any, updateData map[string]any) (int64, error) {
+	if err := b.singlePK(); err != nil {
+		return 0, errors.WithMessage(err, "update by pk")
+	}
 	return b.UpdateByMap(ctx, map[string]any{b.PrimaryKey: pk}, updateData)
 }
 
@@ -154,42 +344,55 @@ func (b *BaseRepo[T]) UpdateByPKWithMap(ctx context.Context, pk any, updateData
 // condition示例：{"name","张三"}
 // condition里的key兼容驼峰和蛇形
 //
-// updateData示例：{"age","18"}
+// updateData示例：{"age","18"}，值可以是 Expr 表达式，表达式引用的列不存在时返回 ErrUnknownColumn；
+// WithStrict 时condition和updateData中不存在的列同样返回 ErrUnknownColumn
 //
 // 注：这里会删除updateData里的以下字段
-// 1、带有gorm标签：autoCreateTime、autoUpdateTime的字段
+// 1、带有gorm标签：autoCreateTime、autoUpdateTime的字段，key为字段名或列名均会删除
+// 开启 WithAutoUpdateTime 时会设置更新时间列
 func (b *BaseRepo[T]) UpdateByMap(ctx context.Context, condition map[string]any, updateData map[string]any) (int64, error) {
-	c := camel2SnakeForMapKey(condition)
+	c := b.columnKeys(condition)
+	if err := b.checkColumns(updateData); err != nil {
+		return 0, errors.WithMessage(err, "update")
+	}
 	b.deleteAutoTime(updateData)
+	updateData, err := b.resolveUpdateExprs(b.touchUpdateTime(ctx, updateData))
+	if err != nil {
+		return 0, err
+	}
+	pks, err := b.affectedPKs(ctx, c)
+	if err != nil {
+		return 0, err
+	}
 
 	var m T
-	tx := b.withTransactionCtx(ctx).Model(&m).Where(c).Updates(updateData)
+	tx := b.withTransactionCtx(ctx).Model(&m).Scopes(whereCond(c)).Updates(updateData)
 	if err := tx.Error; err != nil {
 		return 0, errors.Wrapf(err, "db: update %s by map error, condition: %v, updateData: %v", b.StructName, c, updateData)
 	}
-	return tx.RowsAffected, nil
+	return tx.RowsAffected, b.invalidateCache(ctx, pks, updateData)
 }
 
+// deleteAutoTime 删除updateData中对应 autoCreateTime、autoUpdateTime 字段的key，
+// key可以是字段名、列名（包括 column 标签指定的列名）或者驼峰、蛇形的写法
 func (b *BaseRepo[T]) deleteAutoTime(updateData map[string]any) {
-	var m T
-	b.recursiveDeleteAutoTime(reflect.ValueOf(m), updateData)
-}
-
-func (b *BaseRepo[T]) recursiveDeleteAutoTime(v reflect.Value, updateData map[string]any) {
-	t := IndirectType(v.Type())
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		if field.Anonymous {
-			b.recursiveDeleteAutoTime(v.Field(i), updateData)
-		} else {
-			tag := field.Tag.Get("gorm")
-			autoCreateTime := strings.Contains(tag, "autoCreateTime") &&
-				!strings.Contains(tag, "autoCreateTime:false")
-
-			autoUpdateTime := strings.Contains(tag, "autoUpdateTime") &&
-				!strings.Contains(tag, "autoUpdateTime:false")
-			if autoCreateTime || autoUpdateTime {
-				delete(updateData, field.Name)
+	fields := b.Schema().AutoTimeFields
+	if len(fields) == 0 {
+		return
+	}
+	s, _ := b.gormSchema()
+	for k := range updateData {
+		for _, name := range fields {
+			if k == name || Camel2Snake(k) == Camel2Snake(name) {
+				delete(updateData, k)
+				break
+			}
+			if s == nil {
+				continue
+			}
+			if f := schemaColumn(s, k); f != nil && f.Name == name {
+				delete(updateData, k)
+				break
 			}
 		}
 	}
@@ -202,6 +405,19 @@ func (b *BaseRepo[T]) SelectOne(ctx context.Context, condition *T) (*T, error) {
 
 // SelectOneByPK 根据主键查找
 func (b *BaseRepo[T]) SelectOneByPK(ctx context.Context, pk any) (*T, error) {
+	if err := b.singlePK(); err != nil {
+		return nil, errors.WithMessage(err, "select one by pk")
+	}
+	if b.pkFilterRejects(pk) {
+		return nil, nil
+	}
+	if b.cacheEnabled(ctx) {
+		res, err := b.selectOneByPKCached(withoutMasking(ctx), pk)
+		if err != nil {
+			return nil, err
+		}
+		return res, b.maskCached(ctx, res)
+	}
 	return b.SelectOneByMap(ctx, map[string]any{b.PrimaryKey: pk})
 }
 
@@ -209,7 +425,7 @@ func (b *BaseRepo[T]) SelectOneByPK(ctx context.Context, pk any) (*T, error) {
 // condition示例：{"name","张三"}
 // condition里的key兼容驼峰和蛇形
 func (b *BaseRepo[T]) SelectOneByMap(ctx context.Context, condition map[string]any) (*T, error) {
-	c := camel2SnakeForMapKey(condition)
+	c := b.columnKeys(condition)
 	return b.selectOne(ctx, c)
 }
 
@@ -239,6 +455,23 @@ func (b *BaseRepo[T]) SelectAll(ctx context.Context) ([]*T, error) {
 
 // SelectByPK 根据主键查找，支持单个主键或者一个主键数组
 func (b *BaseRepo[T]) SelectByPK(ctx context.Context, pks any) ([]*T, error) {
+	if err := b.singlePK(); err != nil {
+		return nil, errors.WithMessage(err, "select by pk")
+	}
+	if b.opts.pkFilter != nil {
+		filtered := b.filterPKs(pks)
+		if len(filtered) == 0 {
+			return nil, nil
+		}
+		pks = filtered
+	}
+	if b.cacheEnabled(ctx) {
+		res, err := b.selectByPKCached(withoutMasking(ctx), pks)
+		if err != nil {
+			return nil, err
+		}
+		return res, b.maskCached(ctx, res...)
+	}
 	return b.SelectByMap(ctx, map[string]any{b.PrimaryKey: pks})
 }
 
@@ -246,29 +479,140 @@ func (b *BaseRepo[T]) SelectByPK(ctx context.Context, pks any) ([]*T, error) {
 // condition示例：{"name","张三"}
 // condition里的key兼容驼峰和蛇形
 func (b *BaseRepo[T]) SelectByMap(ctx context.Context, condition map[string]any) ([]*T, error) {
-	c := camel2SnakeForMapKey(condition)
+	c := b.columnKeys(condition)
 	return b._select(ctx, c)
 }
 
-func camel2SnakeForMapKey(condition map[string]any) map[string]any {
-	c := make(map[string]any)
-	for k, v := range condition {
-		c[Camel2Snake(k)] = v
+// columnKeys map的key转换为列名：先按字段名或列名在模型的schema中查找，与db的 NamingStrategy 以及 column 标签一致，
+// 找不到时按 Camel2Snake 转换；多个key对应同一列时（例如 UserID 和 user_id）以与列名相同的key为准，保证结果确定
+// 值为 *gorm.DB 时转换为 InSubQuery
+func (b *BaseRepo[T]) columnKeys(condition map[string]any) map[string]any {
+	s, _ := b.gormSchema()
+	c := make(map[string]any, len(condition))
+	for _, k := range SortedKeys(condition) {
+		column := Camel2Snake(k)
+		if s != nil {
+			if f := schemaColumn(s, k); f != nil {
+				column = f.DBName
+			}
+		}
+		if _, exists := c[column]; exists && k != column {
+			if _, hasColumn := condition[column]; hasColumn {
+				continue
+			}
+		}
+		v := condition[k]
+		if db, ok := v.(*gorm.DB); ok {
+			v = InSubQuery(db)
+		}
+		c[column] = v
 	}
 	return c
 }
 
+// condExpr 可以作为map条件值的表达式条件（例如 JSONEq），生成的SQL不引用map的key，key只用于区分多个条件
+type condExpr interface {
+	clause.Expression
+	condExpr()
+}
+
+// columnCond 作为map条件值时以key为列生成条件的表达式（例如 ArrayContains）
+type columnCond interface {
+	condOn(column string) clause.Expression
+}
+
+// whereCond 添加条件，map条件中值为 condExpr、columnCond 的项单独作为表达式添加，其余项作为普通的 列 = 值 条件
+func whereCond(condition any) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		c, ok := condition.(map[string]any)
+		if !ok {
+			return db.Where(condition)
+		}
+		strictCheck(db, c)
+		plain := make(map[string]any, len(c))
+		var exprs []clause.Expression
+		for _, k := range SortedKeys(c) {
+			switch e := c[k].(type) {
+			case condExpr:
+				exprs = append(exprs, e)
+				continue
+			case columnCond:
+				exprs = append(exprs, e.condOn(k))
+				continue
+			}
+			plain[k] = c[k]
+		}
+		db = db.Where(plain)
+		for _, e := range exprs {
+			db = db.Where(e)
+		}
+		return db
+	}
+}
+
+// SortedKeys map的key按字典序排列，condition、updateData等map生成SQL时的列顺序与此一致，
+// 用于计算语句指纹、缓存key等需要稳定顺序的场景
+func SortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (b *BaseRepo[T]) _select(ctx context.Context, condition any) ([]*T, error) {
-	var (
-		m   T
-		res []*T
-	)
-	if err := b.withTransactionCtx(ctx).Model(&m).Where("deleted !=?", Deleted).Where(condition).Find(&res).Error; err != nil {
+	if c, ok := condition.(map[string]any); ok && !hasExprCond(c) && b.queryCacheEnabled(ctx) {
+		res, err := b.selectByMapCached(withoutMasking(ctx), c)
+		if err != nil {
+			return nil, err
+		}
+		return res, b.maskCached(ctx, res...)
+	}
+	return b.selectFromDB(ctx, condition)
+}
+
+func (b *BaseRepo[T]) selectFromDB(ctx context.Context, condition any) ([]*T, error) {
+	var m T
+	query := b.readDB(ctx).Model(&m).Scopes(b.notDeleted).Scopes(whereCond(condition))
+	res, err := b.find(b.withQueryOptions(ctx, query))
+	if err != nil {
 		return nil, errors.Wrapf(err, "db: select %s error, condition: %+v", b.StructName, condition)
 	}
 	return res, nil
 }
 
+// find 执行查询，T为指针（BaseRepo[*Pop]）时gorm不能直接扫描到 []**Pop，先扫描到 []*Pop 再转换
+func (b *BaseRepo[T]) find(query *gorm.DB) ([]*T, error) {
+	var res []*T
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Ptr {
+		return res, query.Find(&res).Error
+	}
+	rows := reflect.New(reflect.SliceOf(reflect.PointerTo(IndirectType(t))))
+	if err := query.Find(rows.Interface()).Error; err != nil {
+		return nil, err
+	}
+	rows = rows.Elem()
+	res = make([]*T, 0, rows.Len())
+	for i := 0; i < rows.Len(); i++ {
+		v := rows.Index(i)
+		for v.Type() != t {
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			v = p
+		}
+		m := v.Interface().(T)
+		res = append(res, &m)
+	}
+	return res, nil
+}
+
+// withQueryOptions 在查询上追加ctx中的查询选项：预加载、索引提示、执行时间限制
+func (b *BaseRepo[T]) withQueryOptions(ctx context.Context, db *gorm.DB) *gorm.DB {
+	return withStatementTimeout(ctx, withIndexHints(ctx, b.withPreloads(ctx, db)))
+}
+
 type PageParam struct {
 	PageNo   int32
 	PageSize int32
@@ -276,11 +620,8 @@ type PageParam struct {
 }
 
 func (b *BaseRepo[T]) ListPage(_ context.Context, query *gorm.DB, page *PageParam) ([]*T, int32, error) {
-	var (
-		total int64
-		res   []*T
-	)
-	query = query.Where("deleted !=?", Deleted)
+	var total int64
+	query = query.Scopes(b.notDeleted)
 	if page != nil {
 		if err := query.Count(&total).Error; err != nil {
 			return nil, 0, errors.Wrapf(err, "db: select count %s error", b.StructName)
@@ -293,7 +634,8 @@ func (b *BaseRepo[T]) ListPage(_ context.Context, query *gorm.DB, page *PagePara
 			query = query.Order(page.OrderBy)
 		}
 	}
-	if err := query.Find(&res).Error; err != nil {
+	res, err := b.find(query)
+	if err != nil {
 		return nil, 0, errors.Wrapf(err, "db: select %s error, orders: %s", b.StructName, strings.Join(orders, ","))
 	}
 	if total == 0 {
@@ -302,25 +644,50 @@ func (b *BaseRepo[T]) ListPage(_ context.Context, query *gorm.DB, page *PagePara
 	return res, int32(total), nil
 }
 
+// PageSelect 根据条件分页查询，query为map时与 SelectByMap 的条件相同，query为字符串时支持 :name 命名参数，args传入 map[string]any 或 sql.Named：
+//
+//	repo.PageSelect(ctx, page, "status = :status AND create_at >= :start_time",
+//		map[string]any{"status": 1, "start_time": start})
 func (b *BaseRepo[T]) PageSelect(ctx context.Context, page *PageParam, query any, args ...any) ([]*T, int32, error) {
+	if c, ok := query.(map[string]any); ok && len(args) == 0 && b.pageCacheEnabled(ctx) {
+		return b.pageSelectCached(ctx, page, c)
+	}
+	return b.pageSelect(ctx, page, query, args...)
+}
+
+func (b *BaseRepo[T]) pageSelect(ctx context.Context, page *PageParam, query any, args ...any) ([]*T, int32, error) {
 	var (
 		m     T
 		total int64
-		res   []*T
 	)
+	if s, ok := query.(string); ok {
+		var err error
+		if query, args, err = bindNamed(s, args); err != nil {
+			return nil, 0, err
+		}
+	}
+	where := func(db *gorm.DB) *gorm.DB {
+		// map条件与 SelectByMap 相同，支持 LikeCondition 等表达式条件
+		if c, ok := query.(map[string]any); ok && len(args) == 0 {
+			return db.Scopes(whereCond(b.columnKeys(c)))
+		}
+		return db.Where(query, args...)
+	}
 	if page != nil {
-		if err := b.withTransactionCtx(ctx).Model(&m).Where("deleted !=?", Deleted).Where(query, args...).Count(&total).Error; err != nil {
+		countQuery := b.readDB(ctx).Model(&m).Scopes(b.notDeleted, where)
+		if err := withStatementTimeout(ctx, withIndexHints(ctx, countQuery)).Count(&total).Error; err != nil {
 			return nil, 0, errors.Wrapf(err, "db: select count %s error, query: %+v, args: %+v", b.StructName, query, args)
 		}
 	}
-	q := b.withTransactionCtx(ctx).Model(&m).Where("deleted !=?", Deleted).Where(query, args...)
+	q := b.readDB(ctx).Model(&m).Scopes(b.notDeleted, where)
 	if page != nil {
 		q = q.Offset(int(page.PageNo-1) * int(page.PageSize)).Limit(int(page.PageSize))
 		if page.OrderBy != "" {
 			q = q.Order(page.OrderBy)
 		}
 	}
-	if err := q.Find(&res).Error; err != nil {
+	res, err := b.find(b.withQueryOptions(ctx, q))
+	if err != nil {
 		return nil, 0, errors.Wrapf(err, "db: select %s error, query: %+v, args: %+v", b.StructName, query, args)
 	}
 	if total == 0 {
@@ -329,23 +696,7 @@ func (b *BaseRepo[T]) PageSelect(ctx context.Context, page *PageParam, query any
 	return res, int32(total), nil
 }
 
+// Camel2Snake 驼峰转蛇形，与gorm默认的 NamingStrategy 一致，缩写整体转换：UserID -> user_id，HTTPStatus -> http_status
 func Camel2Snake(s string) string {
-	data := make([]byte, 0, len(s)*2)
-	j := false
-	num := len(s)
-	for i := 0; i < num; i++ {
-		d := s[i]
-		// or通过ASCII码进行大小写的转化
-		// 65-90（A-Z），97-122（a-z）
-		// 判断如果字母为大写的A-Z就在前面拼接一个_
-		if i > 0 && d >= 'A' && d <= 'Z' && j {
-			data = append(data, '_')
-		}
-		if d != '_' {
-			j = true
-		}
-		data = append(data, d)
-	}
-	// ToLower把大写字母统一转小写
-	return strings.ToLower(string(data[:]))
+	return schema.NamingStrategy{}.ColumnName("", s)
 }
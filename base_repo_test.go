@@ -0,0 +1,52 @@
+package gormx
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/utils/tests"
+)
+
+type accessorUser struct {
+	ID   int64 `gorm:"primaryKey"`
+	Name string
+	Age  int
+}
+
+// 开启repo配置后，在同一个accessor返回的db上执行的多条语句互不影响
+func TestAccessorReuse(t *testing.T) {
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := map[string][]Option{
+		"none":   nil,
+		"strict": {WithStrict()},
+		"audit":  {WithAuditLog()},
+	}
+	for name, o := range opts {
+		t.Run(name, func(t *testing.T) {
+			repo := NewBaseRepo[accessorUser](db, o...)
+			ctx := context.Background()
+			for accessor, conn := range map[string]*gorm.DB{
+				"withTransactionCtx": repo.withTransactionCtx(ctx),
+				"readDB":             repo.readDB(ctx),
+			} {
+				update := conn.Model(&accessorUser{}).Where("id = ?", 1).Updates(map[string]any{"name": "a", "age": 1})
+				var n int64
+				count := conn.Model(&accessorUser{}).Where("id = ?", 1).Count(&n)
+				if sql := update.Statement.SQL.String(); !strings.HasPrefix(sql, "UPDATE") {
+					t.Errorf("%s: update sql: %s", accessor, sql)
+				}
+				if sql := count.Statement.SQL.String(); !strings.HasPrefix(sql, "SELECT count(*)") || strings.Count(sql, "id = ?") != 1 {
+					t.Errorf("%s: count sql: %s", accessor, sql)
+				}
+				if vars := count.Statement.Vars; len(vars) != 1 {
+					t.Errorf("%s: count vars: %v", accessor, vars)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package gormx
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// batchUpdateChunkSize 每条UPDATE语句最多更新的记录数
+	batchUpdateChunkSize = 1000
+	// batchUpdateMaxVars 每条语句的最大参数数量，mysql和postgres的上限均为65535
+	batchUpdateMaxVars = 60000
+)
+
+// BatchUpdateByPK 按主键批量更新items的columns列，每条记录的值可以不同，返回影响行数
+// columns兼容驼峰和蛇形，会更新零值
+//
+// 生成 UPDATE ... SET col = CASE pk WHEN ? THEN ? ... ELSE col END WHERE pk IN (...)，
+// 每 1000 条记录一条语句，多条语句在同一个事务中执行；
+// 模型带有乐观锁版本号时退化为在事务中逐条执行 UpdateByPKSelect，同样只更新columns列（包括零值），
+// 任意一条版本号不一致时全部回滚
+func (b *BaseRepo[T]) BatchUpdateByPK(ctx context.Context, items []*T, columns []string) (int64, error) {
+	if len(items) == 0 || len(columns) == 0 {
+		return 0, nil
+	}
+	s, err := b.gormSchema()
+	if err != nil {
+		return 0, err
+	}
+	pkField := s.LookUpField(b.PrimaryKey)
+	if pkField == nil {
+		return 0, errors.Errorf("db: batch update %s error, primary key %s not found", b.StructName, b.PrimaryKey)
+	}
+	// 按列名去重排序，相同的列集合生成相同的语句
+	byColumn := make(map[string]*schema.Field, len(columns))
+	for _, col := range columns {
+		field, err := b.lookupColumn(col)
+		if err != nil {
+			return 0, errors.WithMessage(err, "batch update")
+		}
+		byColumn[field.DBName] = field
+	}
+	fields := make([]*schema.Field, 0, len(byColumn))
+	for _, col := range SortedKeys(byColumn) {
+		fields = append(fields, byColumn[col])
+	}
+
+	var rows int64
+	err = b.InTx(ctx, func(ctx context.Context) error {
+		if b.versionField() != nil {
+			names := make([]string, 0, len(fields))
+			for _, field := range fields {
+				if !field.PrimaryKey {
+					names = append(names, field.DBName)
+				}
+			}
+			if len(names) == 0 {
+				return nil
+			}
+			for _, item := range items {
+				n, err := b.UpdateByPKSelect(ctx, item, names)
+				if err != nil {
+					return err
+				}
+				rows += n
+			}
+			return nil
+		}
+		chunk := min(batchUpdateChunkSize, batchUpdateMaxVars/(2*len(fields)+1))
+		for start := 0; start < len(items); start += chunk {
+			n, err := b.batchUpdateChunk(ctx, items[start:min(start+chunk, len(items))], pkField, fields)
+			if err != nil {
+				return err
+			}
+			rows += n
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func (b *BaseRepo[T]) batchUpdateChunk(ctx context.Context, items []*T, pkField *schema.Field, fields []*schema.Field) (int64, error) {
+	db := b.withTransactionCtx(ctx)
+	quote := func(s string) string { return db.Statement.Quote(s) }
+	pks := make([]any, 0, len(items))
+	values := make([][]any, len(fields))
+	for _, item := range items {
+		rv := indirectModel(reflect.ValueOf(item))
+		pk, isZero := pkField.ValueOf(ctx, rv)
+		if isZero {
+			return 0, errors.Errorf("db: batch update %s error, primary key is empty, param: %+v", b.StructName, item)
+		}
+		pks = append(pks, pk)
+		for i, field := range fields {
+			v, _ := field.ValueOf(ctx, rv)
+			values[i] = append(values[i], v)
+		}
+	}
+
+	var (
+		sql  strings.Builder
+		vars = make([]any, 0, len(items)*(2*len(fields)+1))
+	)
+	sql.WriteString("UPDATE " + quote(b.tableName()) + " SET ")
+	changed := make(map[string]any, len(fields))
+	for i, field := range fields {
+		if i > 0 {
+			sql.WriteString(", ")
+		}
+		col := quote(field.DBName)
+		sql.WriteString(col + " = CASE " + quote(b.PrimaryKey))
+		for j, pk := range pks {
+			sql.WriteString(" WHEN ? THEN ?")
+			vars = append(vars, pk, values[i][j])
+		}
+		sql.WriteString(" ELSE " + col + " END")
+		changed[field.DBName] = values[i]
+	}
+	sql.WriteString(" WHERE " + quote(b.PrimaryKey) + " IN ?")
+	vars = append(vars, pks)
+
+	tx := db.Exec(sql.String(), vars...)
+	if err := tx.Error; err != nil {
+		return 0, errors.Wrapf(err, "db: batch update %s by pk error, pks: %v", b.StructName, pks)
+	}
+	return tx.RowsAffected, b.invalidateCache(ctx, pks, changed)
+}
@@ -0,0 +1,541 @@
+package gormx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// DefaultCacheTTL WithCache 未指定 ttl 时的默认过期时间
+const DefaultCacheTTL = 5 * time.Minute
+
+// DefaultNegativeCacheTTL WithNegativeCache 未指定 ttl 时的默认过期时间
+const DefaultNegativeCacheTTL = 10 * time.Second
+
+// negativeCacheValue 记录不存在时缓存的值，模型序列化后不会是null
+var negativeCacheValue = []byte("null")
+
+// ErrCacheMiss 缓存中不存在对应的key
+var ErrCacheMiss = errors.New("gormx: cache miss")
+
+// Cache 查询结果缓存，Get 在 key 不存在或已过期时返回 ErrCacheMiss
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// memoryCacheMinSweep key数量达到该值后才开始清理过期的key
+const memoryCacheMinSweep = 1024
+
+// MemoryCache 进程内缓存，过期的key在读取时惰性删除，key数量比上次清理后翻倍时整体清理一次，
+// 写入的均摊开销为O(1)
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+	// 标签 -> 缓存key
+	tags map[string]map[string]struct{}
+	// sweepAt key数量达到该值时清理过期的key
+	sweepAt int
+}
+
+type memoryCacheEntry struct {
+	value    []byte
+	expireAt time.Time
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		entries: make(map[string]memoryCacheEntry),
+		tags:    make(map[string]map[string]struct{}),
+		sweepAt: memoryCacheMinSweep,
+	}
+}
+
+func (c *MemoryCache) Get(_ context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	if time.Now().After(e.expireAt) {
+		delete(c.entries, key)
+		return nil, ErrCacheMiss
+	}
+	return e.value, nil
+}
+
+func (c *MemoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= c.sweepAt {
+		c.sweep(now)
+	}
+	c.entries[key] = memoryCacheEntry{value: value, expireAt: now.Add(ttl)}
+	return nil
+}
+
+// sweep 删除过期的key以及标签中已经不存在的key，调用方持有锁
+func (c *MemoryCache) sweep(now time.Time) {
+	for k, e := range c.entries {
+		if now.After(e.expireAt) {
+			delete(c.entries, k)
+		}
+	}
+	for tag, keys := range c.tags {
+		for k := range keys {
+			if _, ok := c.entries[k]; !ok {
+				delete(keys, k)
+			}
+		}
+		if len(keys) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+	c.sweepAt = max(2*len(c.entries), memoryCacheMinSweep)
+}
+
+func (c *MemoryCache) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range keys {
+		delete(c.entries, k)
+	}
+	return nil
+}
+
+// RedisDoer 执行单条redis命令，key不存在时需要返回 (nil, nil)
+//
+// go-redis 适配示例：
+//
+//	gormx.RedisDoFunc(func(ctx context.Context, args ...any) (any, error) {
+//		v, err := rdb.Do(ctx, args...).Result()
+//		if errors.Is(err, redis.Nil) {
+//			return nil, nil
+//		}
+//		return v, err
+//	})
+type RedisDoer interface {
+	Do(ctx context.Context, args ...any) (any, error)
+}
+
+// RedisDoFunc 将普通函数适配为 RedisDoer
+type RedisDoFunc func(ctx context.Context, args ...any) (any, error)
+
+func (f RedisDoFunc) Do(ctx context.Context, args ...any) (any, error) {
+	return f(ctx, args...)
+}
+
+// RedisCache 基于redis的缓存实现，不依赖具体的redis客户端
+type RedisCache struct {
+	client RedisDoer
+}
+
+func NewRedisCache(client RedisDoer) *RedisCache {
+	return &RedisCache{client: client}
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	v, err := c.client.Do(ctx, "GET", key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cache: redis get error, key: %s", key)
+	}
+	switch s := v.(type) {
+	case nil:
+		return nil, ErrCacheMiss
+	case string:
+		return []byte(s), nil
+	case []byte:
+		return s, nil
+	default:
+		return nil, errors.Errorf("cache: redis get error, unexpected reply type %T, key: %s", v, key)
+	}
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if _, err := c.client.Do(ctx, "SET", key, value, "PX", ttl.Milliseconds()); err != nil {
+		return errors.Wrapf(err, "cache: redis set error, key: %s", key)
+	}
+	return nil
+}
+
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(keys)+1)
+	args = append(args, "DEL")
+	for _, k := range keys {
+		args = append(args, k)
+	}
+	if _, err := c.client.Do(ctx, args...); err != nil {
+		return errors.Wrapf(err, "cache: redis del error, keys: %v", keys)
+	}
+	return nil
+}
+
+func (b *BaseRepo[T]) cacheEnabled(ctx context.Context) bool {
+	if b.opts.cache == nil || consistencyFromCtx(ctx).kind == consistencyStrong || dryRunFromCtx(ctx) != nil {
+		return false
+	}
+	// 缓存按单列主键组织，复合主键的模型不走缓存
+	if len(b.Schema().PrimaryKeys) > 1 {
+		return false
+	}
+	// 缓存中只有模型本身，没有关联
+	if len(b.modelPreloads(ctx)) > 0 {
+		return false
+	}
+	// 缓存中是解密后的记录，加密字段不能以明文写入缓存
+	if s, err := b.gormSchema(); err != nil || len(encryptedFields(s)) > 0 {
+		return false
+	}
+	// 事务内可能读到未提交的数据，不走缓存
+	_, inTx := ctx.Value(contextTxKey{}).(*gorm.DB)
+	return !inTx
+}
+
+// pkCacheKey 缓存key格式：gormx:表名:主键值
+func (b *BaseRepo[T]) pkCacheKey(pk any) string {
+	return fmt.Sprintf("gormx:%s:%s", b.tableName(), cacheKeyPart(pk))
+}
+
+func cacheKeyPart(v any) string {
+	switch s := v.(type) {
+	case []byte:
+		return string(s)
+	case *string:
+		if s != nil {
+			return *s
+		}
+	}
+	rv := Indirect(reflect.ValueOf(v))
+	if !rv.IsValid() {
+		// nil 或空指针
+		return fmt.Sprint(nil)
+	}
+	return fmt.Sprint(rv.Interface())
+}
+
+// cachedRow 缓存中的一条记录：列名 -> 字段值的json，按gorm的列序列化，
+// 不受 json:"-"、MarshalJSON 等模型本身序列化方式的影响，与数据库中读出的记录一致
+type cachedRow map[string]json.RawMessage
+
+var jsonNull = []byte("null")
+
+func (b *BaseRepo[T]) toCachedRows(ctx context.Context, rows []*T) ([]cachedRow, error) {
+	s, err := b.gormSchema()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]cachedRow, 0, len(rows))
+	for _, row := range rows {
+		rv := indirectModel(reflect.ValueOf(row))
+		cr := make(cachedRow, len(s.DBNames))
+		for _, f := range s.Fields {
+			if f.DBName == "" {
+				continue
+			}
+			v, _ := f.ValueOf(ctx, rv)
+			raw, err := json.Marshal(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cache: marshal %s.%s error", b.StructName, f.Name)
+			}
+			cr[f.DBName] = raw
+		}
+		res = append(res, cr)
+	}
+	return res, nil
+}
+
+func (b *BaseRepo[T]) fromCachedRows(ctx context.Context, rows []cachedRow) ([]*T, error) {
+	s, err := b.gormSchema()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*T, 0, len(rows))
+	for _, cr := range rows {
+		m := new(T)
+		rv := indirectModel(reflect.ValueOf(m))
+		for column, raw := range cr {
+			f := s.LookUpField(column)
+			if f == nil || f.DBName == "" || bytes.Equal(raw, jsonNull) {
+				continue
+			}
+			if err := json.Unmarshal(raw, f.ReflectValueOf(ctx, rv).Addr().Interface()); err != nil {
+				return nil, errors.Wrapf(err, "cache: unmarshal %s.%s error", b.StructName, f.Name)
+			}
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+// getCachedByPK 缓存异常时按未命中处理，由调用方回源数据库；命中记录不存在的缓存时返回 (nil, true)
+func (b *BaseRepo[T]) getCachedByPK(ctx context.Context, pk any) (*T, bool) {
+	data, err := b.opts.cache.Get(ctx, b.pkCacheKey(pk))
+	if err != nil {
+		recordCache(ctx, false)
+		return nil, false
+	}
+	if bytes.Equal(data, negativeCacheValue) {
+		recordCache(ctx, true)
+		return nil, true
+	}
+	var cr cachedRow
+	if err = json.Unmarshal(data, &cr); err != nil {
+		recordCache(ctx, false)
+		return nil, false
+	}
+	rows, err := b.fromCachedRows(ctx, []cachedRow{cr})
+	if err != nil {
+		recordCache(ctx, false)
+		return nil, false
+	}
+	recordCache(ctx, true)
+	return rows[0], true
+}
+
+func (b *BaseRepo[T]) setCached(ctx context.Context, rows ...*T) {
+	for _, row := range rows {
+		pk, ok := b.pkValue(ctx, row)
+		if !ok {
+			continue
+		}
+		crs, err := b.toCachedRows(ctx, []*T{row})
+		if err != nil {
+			continue
+		}
+		data, err := json.Marshal(crs[0])
+		if err != nil {
+			continue
+		}
+		if tc, ok := b.tagCache(); ok {
+			tags := b.columnTags(ctx, map[string]any{b.PrimaryKey: pk})
+			_ = tc.SetWithTags(ctx, b.pkCacheKey(pk), data, b.opts.cacheTTL, tags...)
+		} else {
+			_ = b.opts.cache.Set(ctx, b.pkCacheKey(pk), data, b.opts.cacheTTL)
+		}
+	}
+}
+
+// setMissed 缓存记录不存在的主键，开启 WithNegativeCache 时生效
+func (b *BaseRepo[T]) setMissed(ctx context.Context, pks ...any) {
+	if b.opts.negativeCacheTTL <= 0 {
+		return
+	}
+	for _, pk := range pks {
+		_ = b.opts.cache.Set(ctx, b.pkCacheKey(pk), negativeCacheValue, b.opts.negativeCacheTTL)
+	}
+}
+
+// invalidateMissed 新增记录后删除其主键上不存在的缓存
+func (b *BaseRepo[T]) invalidateMissed(ctx context.Context, rows ...*T) error {
+	if b.opts.cache == nil || b.opts.negativeCacheTTL <= 0 || dryRunFromCtx(ctx) != nil {
+		return nil
+	}
+	if txHooksFromCtx(ctx) != nil {
+		AfterCommit(ctx, func(ctx context.Context) {
+			_ = b.invalidateMissed(ctx, rows...)
+		})
+	}
+	keys := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if pk, ok := b.pkValue(ctx, row); ok {
+			keys = append(keys, b.pkCacheKey(pk))
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := b.opts.cache.Delete(ctx, keys...); err != nil {
+		return errors.Wrapf(err, "db: invalidate %s cache error, keys: %v", b.StructName, keys)
+	}
+	return nil
+}
+
+// invalidateCache 失效主键缓存；开启查询缓存时同时失效主键标签和 columns 中的列值标签
+//
+// columns 为写入后记录的列值，用于失效写入后才满足条件的查询
+func (b *BaseRepo[T]) invalidateCache(ctx context.Context, pks []any, columns map[string]any) error {
+	if b.opts.cache == nil || (len(pks) == 0 && len(columns) == 0) || dryRunFromCtx(ctx) != nil {
+		return nil
+	}
+	// 事务提交前其他请求可能把旧数据重新写入缓存，提交后再失效一次
+	if txHooksFromCtx(ctx) != nil {
+		AfterCommit(ctx, func(ctx context.Context) {
+			_ = b.invalidateCache(ctx, pks, columns)
+		})
+	}
+	keys := make([]string, 0, len(pks))
+	for _, pk := range pks {
+		keys = append(keys, b.pkCacheKey(pk))
+	}
+	if err := b.opts.cache.Delete(ctx, keys...); err != nil {
+		return errors.Wrapf(err, "db: invalidate %s cache error, pks: %v", b.StructName, pks)
+	}
+	tc, ok := b.tagCache()
+	if !ok {
+		return nil
+	}
+	tags := b.columnTags(ctx, map[string]any{b.PrimaryKey: pks})
+	tags = append(tags, b.columnTags(ctx, columns)...)
+	if b.opts.pageCache {
+		tags = append(tags, b.pageTag(ctx))
+	}
+	if err := tc.InvalidateTags(ctx, tags...); err != nil {
+		return errors.Wrapf(err, "db: invalidate %s cache tags error, tags: %v", b.StructName, tags)
+	}
+	return nil
+}
+
+// affectedPKs 查出满足条件的记录主键，用于写操作后失效缓存
+func (b *BaseRepo[T]) affectedPKs(ctx context.Context, condition map[string]any) ([]any, error) {
+	if b.opts.cache == nil {
+		return nil, nil
+	}
+	if pks, ok := condition[b.PrimaryKey]; ok && len(condition) == 1 {
+		return Interface2Array(pks), nil
+	}
+	var (
+		m   T
+		pks []any
+	)
+	if err := b.withTransactionCtx(ctx).Model(&m).Scopes(whereCond(condition)).Pluck(b.PrimaryKey, &pks).Error; err != nil {
+		return nil, errors.Wrapf(err, "db: select %s pks error, condition: %v", b.StructName, condition)
+	}
+	return pks, nil
+}
+
+// selectOneByPKCached SelectOneByPK 的读穿透实现
+func (b *BaseRepo[T]) selectOneByPKCached(ctx context.Context, pk any) (*T, error) {
+	if res, ok := b.getCachedByPK(ctx, pk); ok {
+		return res, nil
+	}
+	if b.opts.flight == nil {
+		return b.loadOneByPK(ctx, pk)
+	}
+	v, err := b.opts.flight.do(b.pkCacheKey(pk), func() (any, error) {
+		return b.loadOneByPK(ctx, pk)
+	})
+	res, _ := v.(*T)
+	if err != nil || res == nil {
+		return nil, err
+	}
+	// 多个调用方共享同一个结果，按缓存的格式重新解码得到深拷贝，避免脱敏等修改影响其他调用方
+	return b.cloneRow(ctx, res)
+}
+
+// cloneRow 通过缓存的编码复制记录，T为指针或字段为指针时同样不共享
+func (b *BaseRepo[T]) cloneRow(ctx context.Context, row *T) (*T, error) {
+	crs, err := b.toCachedRows(ctx, []*T{row})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := b.fromCachedRows(ctx, crs)
+	if err != nil {
+		return nil, err
+	}
+	return rows[0], nil
+}
+
+// loadOneByPK 回源数据库并写入缓存
+func (b *BaseRepo[T]) loadOneByPK(ctx context.Context, pk any) (*T, error) {
+	rows, err := b.selectFromDB(ctx, map[string]any{b.PrimaryKey: pk})
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		if rows, err = b.confirmMissed(ctx, []any{pk}); err != nil || len(rows) == 0 {
+			return nil, err
+		}
+	}
+	if len(rows) > 1 {
+		return nil, errors.Errorf("db: select one %s error, result must be one, now it is %d, pk %v", b.StructName, len(rows), pk)
+	}
+	b.setCached(ctx, rows[0])
+	return rows[0], nil
+}
+
+// selectByPKCached SelectByPK 的读穿透实现，只对未命中的主键回源
+func (b *BaseRepo[T]) selectByPKCached(ctx context.Context, pks any) ([]*T, error) {
+	var (
+		res    []*T
+		misses []any
+	)
+	for _, pk := range Interface2Array(pks) {
+		if m, ok := b.getCachedByPK(ctx, pk); ok {
+			if m != nil {
+				res = append(res, m)
+			}
+		} else {
+			misses = append(misses, pk)
+		}
+	}
+	if len(misses) == 0 {
+		return res, nil
+	}
+	rows, err := b.selectFromDB(ctx, map[string]any{b.PrimaryKey: misses})
+	if err != nil {
+		return nil, err
+	}
+	b.setCached(ctx, rows...)
+	if b.opts.negativeCacheTTL > 0 {
+		found := make(map[string]struct{}, len(rows))
+		for _, row := range rows {
+			if pk, ok := b.pkValue(ctx, row); ok {
+				found[cacheKeyPart(pk)] = struct{}{}
+			}
+		}
+		var notFound []any
+		for _, pk := range misses {
+			if _, ok := found[cacheKeyPart(pk)]; !ok {
+				notFound = append(notFound, pk)
+			}
+		}
+		confirmed, err := b.confirmMissed(ctx, notFound)
+		if err != nil {
+			return nil, err
+		}
+		b.setCached(ctx, confirmed...)
+		rows = append(rows, confirmed...)
+	}
+	return append(res, rows...), nil
+}
+
+// confirmMissed 缓存回源时没有查到的主键，回源读的可能是还没有同步新增记录的从库，
+// 到主库确认后才缓存为不存在，避免 invalidateMissed 之后又被从库的结果缓存一个ttl；
+// 返回主库上查到的记录
+func (b *BaseRepo[T]) confirmMissed(ctx context.Context, pks []any) ([]*T, error) {
+	if b.opts.negativeCacheTTL <= 0 || len(pks) == 0 {
+		return nil, nil
+	}
+	var rows []*T
+	if !b.readsPrimary(ctx) {
+		var err error
+		if rows, err = b.selectFromDB(RequireFresh(ctx), map[string]any{b.PrimaryKey: pks}); err != nil {
+			return nil, err
+		}
+	}
+	found := make(map[string]struct{}, len(rows))
+	for _, row := range rows {
+		if pk, ok := b.pkValue(ctx, row); ok {
+			found[cacheKeyPart(pk)] = struct{}{}
+		}
+	}
+	for _, pk := range pks {
+		if _, ok := found[cacheKeyPart(pk)]; !ok {
+			b.setMissed(ctx, pk)
+		}
+	}
+	return rows, nil
+}
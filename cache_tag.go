@@ -0,0 +1,247 @@
+package gormx
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm/clause"
+)
+
+// TagCache 支持按标签失效的缓存
+//
+// 查询结果写入时登记标签（表、租户、条件列值、结果主键），写操作只失效受影响的标签，
+// 不需要按表整体清空
+type TagCache interface {
+	Cache
+	SetWithTags(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+func (c *MemoryCache) SetWithTags(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	if err := c.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	return nil
+}
+
+func (c *MemoryCache) InvalidateTags(_ context.Context, tags ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tag := range tags {
+		for k := range c.tags[tag] {
+			delete(c.entries, k)
+		}
+		delete(c.tags, tag)
+	}
+	return nil
+}
+
+// SetWithTags 标签以set的形式保存，过期时间与缓存key一致
+func (c *RedisCache) SetWithTags(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	if err := c.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := c.client.Do(ctx, "SADD", tag, key); err != nil {
+			return errors.Wrapf(err, "cache: redis sadd error, tag: %s", tag)
+		}
+		if _, err := c.client.Do(ctx, "PEXPIRE", tag, ttl.Milliseconds()); err != nil {
+			return errors.Wrapf(err, "cache: redis pexpire error, tag: %s", tag)
+		}
+	}
+	return nil
+}
+
+func (c *RedisCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		v, err := c.client.Do(ctx, "SMEMBERS", tag)
+		if err != nil {
+			return errors.Wrapf(err, "cache: redis smembers error, tag: %s", tag)
+		}
+		members, _ := v.([]any)
+		keys := make([]string, 0, len(members)+1)
+		for _, m := range members {
+			keys = append(keys, cacheKeyPart(m))
+		}
+		keys = append(keys, tag)
+		if err = c.Delete(ctx, keys...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *BaseRepo[T]) tagCache() (TagCache, bool) {
+	tc, ok := b.opts.cache.(TagCache)
+	return tc, ok
+}
+
+func (b *BaseRepo[T]) queryCacheEnabled(ctx context.Context) bool {
+	if !b.opts.queryCache || !b.cacheEnabled(ctx) {
+		return false
+	}
+	_, ok := b.tagCache()
+	return ok
+}
+
+// cacheScope 条件查询缓存的作用域：表名[:租户]
+func (b *BaseRepo[T]) cacheScope(ctx context.Context) string {
+	scope := b.tableName()
+	if b.opts.cacheTenant != nil {
+		scope += ":" + b.opts.cacheTenant(ctx)
+	}
+	return scope
+}
+
+// tagPrefix 标签格式：gormx:tag:表名[:租户]
+func (b *BaseRepo[T]) tagPrefix(ctx context.Context) string {
+	return "gormx:tag:" + b.cacheScope(ctx)
+}
+
+// columnTags 将列值转换为标签，key兼容字段名和列名，数组值会拆成多个标签
+func (b *BaseRepo[T]) columnTags(ctx context.Context, columns map[string]any) []string {
+	prefix := b.tagPrefix(ctx)
+	s, _ := b.gormSchema()
+	var tags []string
+	for k, v := range columns {
+		column := Camel2Snake(k)
+		if s != nil {
+			if field := schemaColumn(s, k); field != nil {
+				column = field.DBName
+			}
+		}
+		if _, isExpr := v.(clause.Expr); isExpr || column == softDeleteColumn {
+			// 软删除状态变化以及表达式更新后的值未知，会影响所有查询
+			tags = append(tags, prefix)
+			continue
+		}
+		for _, value := range tagValues(v) {
+			tags = append(tags, fmt.Sprintf("%s:%s=%s", prefix, column, cacheKeyPart(value)))
+		}
+	}
+	return tags
+}
+
+func tagValues(v any) []any {
+	switch v.(type) {
+	case nil:
+		return []any{nil}
+	case []byte:
+		return []any{v}
+	}
+	return Interface2Array(v)
+}
+
+// rowColumns 记录中的列值，zero 为false时跳过零值字段（按非零值更新时未写入的列）
+func (b *BaseRepo[T]) rowColumns(ctx context.Context, m *T, zero bool) map[string]any {
+	s, err := b.gormSchema()
+	if err != nil || m == nil {
+		return nil
+	}
+	rv := indirectModel(reflect.ValueOf(m))
+	columns := make(map[string]any)
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		if v, isZero := field.ValueOf(ctx, rv); zero || !isZero {
+			columns[field.DBName] = v
+		}
+	}
+	return columns
+}
+
+// invalidateInserted 新增记录可能命中条件查询和全表查询，并改变分页结果
+func (b *BaseRepo[T]) invalidateInserted(ctx context.Context, rows ...*T) error {
+	b.addToPKFilter(ctx, rows...)
+	if err := b.invalidateMissed(ctx, rows...); err != nil {
+		return err
+	}
+	if !b.opts.queryCache && !b.opts.pageCache || dryRunFromCtx(ctx) != nil {
+		return nil
+	}
+	tc, ok := b.tagCache()
+	if !ok || len(rows) == 0 {
+		return nil
+	}
+	if txHooksFromCtx(ctx) != nil {
+		AfterCommit(ctx, func(ctx context.Context) {
+			_ = b.invalidateInserted(ctx, rows...)
+		})
+	}
+	tags := []string{b.tagPrefix(ctx) + ":*"}
+	for _, row := range rows {
+		// 零值同样写入了数据库，例如 status=0 的记录会命中 {"status": 0} 的查询
+		columns := b.rowColumns(ctx, row, true)
+		// 新增记录的软删除标记只是默认值，不需要失效整表
+		delete(columns, softDeleteColumn)
+		tags = append(tags, b.columnTags(ctx, columns)...)
+	}
+	if b.opts.pageCache {
+		tags = append(tags, b.pageTag(ctx))
+	}
+	if err := tc.InvalidateTags(ctx, tags...); err != nil {
+		return errors.Wrapf(err, "db: invalidate %s cache tags error, tags: %v", b.StructName, tags)
+	}
+	return nil
+}
+
+// selectByMapCached 条件查询的缓存，结果登记表、条件列值以及结果主键标签
+func (b *BaseRepo[T]) selectByMapCached(ctx context.Context, condition map[string]any) ([]*T, error) {
+	tc, _ := b.tagCache()
+	raw, err := json.Marshal(condition)
+	if err != nil {
+		return b.selectFromDB(ctx, condition)
+	}
+	sum := sha1.Sum(raw)
+	key := fmt.Sprintf("gormx:%s:q:%s", b.cacheScope(ctx), hex.EncodeToString(sum[:]))
+	if data, err := tc.Get(ctx, key); err == nil {
+		var crs []cachedRow
+		if err = json.Unmarshal(data, &crs); err == nil {
+			if res, err := b.fromCachedRows(ctx, crs); err == nil {
+				recordCache(ctx, true)
+				return res, nil
+			}
+		}
+	}
+	recordCache(ctx, false)
+
+	res, err := b.selectFromDB(ctx, condition)
+	if err != nil {
+		return nil, err
+	}
+	prefix := b.tagPrefix(ctx)
+	tags := append([]string{prefix}, b.columnTags(ctx, condition)...)
+	if len(condition) == 0 {
+		tags = append(tags, prefix+":*")
+	}
+	pks := make([]any, 0, len(res))
+	for _, row := range res {
+		if pk, ok := b.pkValue(ctx, row); ok {
+			pks = append(pks, pk)
+		}
+	}
+	tags = append(tags, b.columnTags(ctx, map[string]any{b.PrimaryKey: pks})...)
+	if crs, err := b.toCachedRows(ctx, res); err == nil {
+		if data, err := json.Marshal(crs); err == nil {
+			_ = tc.SetWithTags(ctx, key, data, b.opts.cacheTTL, tags...)
+		}
+	}
+	return res, nil
+}
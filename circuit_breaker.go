@@ -0,0 +1,289 @@
+package gormx
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// ErrCircuitOpen 熔断器打开，语句没有发送到数据库
+var ErrCircuitOpen = errors.New("db: circuit breaker is open")
+
+// CircuitState 熔断器的状态
+type CircuitState int8
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// CircuitBreakerConfig 熔断器配置，零值字段使用括号中的默认值
+type CircuitBreakerConfig struct {
+	// 统计窗口（10s），每个窗口重新计数
+	Window time.Duration
+	// 窗口内语句数达到 MinRequests（20）后才判断是否熔断
+	MinRequests int
+	// 失败比例达到 ErrorRate（0.5）时熔断
+	ErrorRate float64
+	// 耗时超过 SlowThreshold 的语句比例达到 SlowRate 时熔断，SlowThreshold 为0时不按耗时熔断
+	SlowThreshold time.Duration
+	SlowRate      float64
+	// 熔断后经过 OpenTimeout（5s）进入半开状态
+	OpenTimeout time.Duration
+	// 半开状态最多放行的探测语句数（1），全部成功后关闭，任意一条失败重新熔断
+	HalfOpenProbes int
+	// 判断错误是否计为失败，为空时连接错误、超时、连接数过多计为失败，记录不存在、唯一键冲突等业务错误不计
+	IsFailure func(err error) bool
+	// 状态变化时回调，用于告警和上报监控；回调时持有熔断器的锁，不能在回调中调用熔断器的方法
+	OnStateChange func(from, to CircuitState)
+}
+
+// CircuitBreakerMetrics 熔断器的累计指标
+type CircuitBreakerMetrics struct {
+	State    CircuitState
+	Requests int64
+	Failures int64
+	Slow     int64
+	// 熔断期间直接拒绝的语句数
+	Rejected int64
+	// 熔断次数
+	Opened int64
+}
+
+// CircuitBreaker 数据源的熔断器，数据库故障时快速失败返回 ErrCircuitOpen，而不是让所有请求堆积在耗尽的连接池上
+type CircuitBreaker struct {
+	cfg CircuitBreakerConfig
+
+	mu          sync.Mutex
+	state       CircuitState
+	windowStart time.Time
+	requests    int
+	failures    int
+	slow        int
+	openedAt    time.Time
+	probes      int
+	probeOK     int
+	metrics     CircuitBreakerMetrics
+}
+
+// NewCircuitBreaker 创建熔断器，通过 CircuitBreakerPlugin 作用于整个数据源或通过 WithCircuitBreaker 作用于单个repo，
+// 同一个熔断器可以由多个repo共享
+func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 20
+	}
+	if cfg.ErrorRate <= 0 {
+		cfg.ErrorRate = 0.5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 5 * time.Second
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = isCircuitFailure
+	}
+	return &CircuitBreaker{cfg: cfg, windowStart: time.Now()}
+}
+
+// isCircuitFailure 连接错误、超时和连接数过多说明数据库不可用，其他错误与数据库是否健康无关
+func isCircuitFailure(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || IsTransientConnError(err) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "Error 1040") || strings.Contains(msg, "too many connections") ||
+		strings.Contains(msg, "SQLSTATE 53300")
+}
+
+// State 当前状态
+func (c *CircuitBreaker) State() CircuitState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance(time.Now())
+	return c.state
+}
+
+// Metrics 累计指标
+func (c *CircuitBreaker) Metrics() CircuitBreakerMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance(time.Now())
+	m := c.metrics
+	m.State = c.state
+	return m
+}
+
+// advance 熔断超时后进入半开，关闭状态下窗口到期时重新计数
+func (c *CircuitBreaker) advance(now time.Time) {
+	switch c.state {
+	case CircuitOpen:
+		if now.Sub(c.openedAt) >= c.cfg.OpenTimeout {
+			c.probes, c.probeOK = 0, 0
+			c.setState(CircuitHalfOpen)
+		}
+	case CircuitClosed:
+		if now.Sub(c.windowStart) >= c.cfg.Window {
+			c.windowStart, c.requests, c.failures, c.slow = now, 0, 0, 0
+		}
+	}
+}
+
+func (c *CircuitBreaker) setState(to CircuitState) {
+	from := c.state
+	if from == to {
+		return
+	}
+	c.state = to
+	if to == CircuitOpen {
+		c.metrics.Opened++
+	}
+	if to == CircuitClosed {
+		c.windowStart, c.requests, c.failures, c.slow = time.Now(), 0, 0, 0
+	}
+	if c.cfg.OnStateChange != nil {
+		c.cfg.OnStateChange(from, to)
+	}
+}
+
+// allow 是否放行一条语句，半开状态下占用一个探测名额
+func (c *CircuitBreaker) allow() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance(time.Now())
+	switch c.state {
+	case CircuitOpen:
+		c.metrics.Rejected++
+		return false
+	case CircuitHalfOpen:
+		if c.probes >= c.cfg.HalfOpenProbes {
+			c.metrics.Rejected++
+			return false
+		}
+		c.probes++
+	}
+	return true
+}
+
+// record 记录放行的语句的结果
+func (c *CircuitBreaker) record(err error, elapsed time.Duration) {
+	failed := err != nil && c.cfg.IsFailure(err)
+	slow := c.cfg.SlowThreshold > 0 && elapsed >= c.cfg.SlowThreshold
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics.Requests++
+	if failed {
+		c.metrics.Failures++
+	}
+	if slow {
+		c.metrics.Slow++
+	}
+	now := time.Now()
+	c.advance(now)
+	switch c.state {
+	case CircuitHalfOpen:
+		if failed || slow {
+			c.openedAt = now
+			c.setState(CircuitOpen)
+			return
+		}
+		if c.probeOK++; c.probeOK >= c.cfg.HalfOpenProbes {
+			c.setState(CircuitClosed)
+		}
+	case CircuitClosed:
+		c.requests++
+		if failed {
+			c.failures++
+		}
+		if slow {
+			c.slow++
+		}
+		if c.requests < c.cfg.MinRequests {
+			return
+		}
+		if float64(c.failures)/float64(c.requests) >= c.cfg.ErrorRate ||
+			c.cfg.SlowThreshold > 0 && c.cfg.SlowRate > 0 && float64(c.slow)/float64(c.requests) >= c.cfg.SlowRate {
+			c.openedAt = now
+			c.setState(CircuitOpen)
+		}
+	}
+}
+
+const (
+	circuitSettingKey = "gormx:circuit_breaker"
+	circuitStartKey   = "gormx:circuit_start"
+)
+
+// WithCircuitBreaker 为repo的操作开启熔断，需要注册 CircuitBreakerPlugin
+func WithCircuitBreaker(cb *CircuitBreaker) Option {
+	return func(o *options) {
+		o.circuitBreaker = cb
+	}
+}
+
+// CircuitBreakerPlugin gorm插件，Breaker 不为空时作用于该db上的所有语句，
+// 开启了 WithCircuitBreaker 的repo使用repo自己的熔断器：
+//
+//	cb := gormx.NewCircuitBreaker(gormx.CircuitBreakerConfig{SlowThreshold: time.Second, SlowRate: 0.5})
+//	db.Use(gormx.CircuitBreakerPlugin{Breaker: cb})
+//
+// 开启事务（BEGIN）不经过gorm的回调，不受熔断限制，事务内的语句受限制
+type CircuitBreakerPlugin struct {
+	Breaker *CircuitBreaker
+}
+
+func (CircuitBreakerPlugin) Name() string {
+	return "gormx:circuit_breaker"
+}
+
+func (p CircuitBreakerPlugin) Initialize(db *gorm.DB) error {
+	return registerAround(db, "gormx:circuit_breaker", p.before, p.after)
+}
+
+func (p CircuitBreakerPlugin) breaker(db *gorm.DB) *CircuitBreaker {
+	if v, ok := db.Get(circuitSettingKey); ok {
+		return v.(*CircuitBreaker)
+	}
+	return p.Breaker
+}
+
+func (p CircuitBreakerPlugin) before(db *gorm.DB) {
+	cb := p.breaker(db)
+	if cb == nil || db.Error != nil || db.DryRun {
+		return
+	}
+	if !cb.allow() {
+		_ = db.AddError(ErrCircuitOpen)
+		return
+	}
+	db.InstanceSet(circuitStartKey, time.Now())
+}
+
+func (p CircuitBreakerPlugin) after(db *gorm.DB) {
+	start, ok := db.InstanceGet(circuitStartKey)
+	if !ok {
+		return
+	}
+	if cb := p.breaker(db); cb != nil {
+		cb.record(db.Error, time.Since(start.(time.Time)))
+	}
+}
@@ -12,6 +12,9 @@ const (
 	Deleted
 )
 
+// softDeleteColumn ModelBaseInfo 中软删除标记的列名
+const softDeleteColumn = "deleted"
+
 type ModelBaseInfo struct {
 	CreateAt time.Time          `gorm:"column:create_at;default:CURRENT_TIMESTAMP;NOT NULL" json:"create_at"`                             // 创建时间
 	UpdateAt time.Time          `gorm:"column:update_at;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP;NOT NULL" json:"update_at"` // 最后修改时间
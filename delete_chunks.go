@@ -0,0 +1,56 @@
+package gormx
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DeleteByMapInChunks 按主键顺序每次删除chunkSize条满足条件的记录，直到全部删除，返回删除的总行数
+// 每批之间等待interval（为0时不等待），避免大量删除时长时间持有锁、产生过大的binlog事务和主从延迟
+//
+// condition里的key兼容驼峰和蛇形
+// 注：每批是独立的语句，中途出错时已删除的批次不会回滚；ctx中有事务时所有批次在该事务中执行，失去分批的意义
+func (b *BaseRepo[T]) DeleteByMapInChunks(ctx context.Context, condition map[string]any, chunkSize int, interval time.Duration) (int64, error) {
+	if chunkSize <= 0 {
+		return 0, errors.Errorf("db: delete %s in chunks error, invalid chunk size: %d", b.StructName, chunkSize)
+	}
+	var (
+		m     T
+		total int64
+		last  any
+	)
+	c := b.columnKeys(condition)
+	for {
+		var pks []any
+		db := b.withTransactionCtx(ctx)
+		pk := db.Statement.Quote(b.PrimaryKey)
+		query := db.Model(&m).Scopes(whereCond(c))
+		if last != nil {
+			query = query.Where(pk+" > ?", last)
+		}
+		if err := query.Order(pk).Limit(chunkSize).Pluck(b.PrimaryKey, &pks).Error; err != nil {
+			return total, errors.Wrapf(err, "db: delete %s in chunks error, select pks after %v, condition: %v", b.StructName, last, condition)
+		}
+		if len(pks) == 0 {
+			return total, nil
+		}
+		n, err := b.DeleteByPK(ctx, pks)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if len(pks) < chunkSize {
+			return total, nil
+		}
+		last = pks[len(pks)-1]
+		if interval > 0 {
+			select {
+			case <-ctx.Done():
+				return total, errors.Wrapf(ctx.Err(), "db: delete %s in chunks canceled, deleted: %d", b.StructName, total)
+			case <-time.After(interval):
+			}
+		}
+	}
+}
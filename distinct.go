@@ -0,0 +1,30 @@
+package gormx
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// SelectDistinct 查询满足条件的记录中columns列去重后的组合，返回的记录只填充了columns对应的字段
+// columns兼容驼峰和蛇形，不存在的列返回 ErrUnknownColumn；condition里的key兼容驼峰和蛇形
+func (b *BaseRepo[T]) SelectDistinct(ctx context.Context, columns []string, condition map[string]any) ([]*T, error) {
+	if len(columns) == 0 {
+		return nil, errors.Errorf("db: select distinct %s error, columns is empty", b.StructName)
+	}
+	cols := make([]any, 0, len(columns))
+	for _, name := range columns {
+		f, err := b.lookupColumn(name)
+		if err != nil {
+			return nil, errors.WithMessage(err, "select distinct")
+		}
+		cols = append(cols, f.DBName)
+	}
+	var m T
+	c := b.columnKeys(condition)
+	res, err := b.find(b.readDB(ctx).Model(&m).Distinct(cols...).Scopes(b.notDeleted).Scopes(whereCond(c)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: select distinct %s error, columns: %v, condition: %v", b.StructName, columns, condition)
+	}
+	return res, nil
+}
@@ -0,0 +1,96 @@
+package gormx
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DryRunStatement DryRun 记录的一条SQL
+type DryRunStatement struct {
+	SQL  string
+	Vars []any
+}
+
+// DryRunRecorder 记录 DryRun ctx内生成的SQL
+type DryRunRecorder struct {
+	mu    sync.Mutex
+	stmts []DryRunStatement
+}
+
+type contextDryRunKey struct{}
+
+// DryRun 返回的ctx传给repo的方法时只生成SQL，不在数据库执行，生成的SQL按顺序记录在 DryRunRecorder 中：
+//
+//	ctx, rec := gormx.DryRun(ctx)
+//	_, _ = repo.UpdateByMap(ctx, condition, updateData)
+//	for _, s := range rec.Statements() {
+//		fmt.Println(s.SQL, s.Vars)
+//	}
+//
+// 查询返回空结果，写操作影响行数为0，依赖查询结果的方法（例如 Save、FirstOrCreate）会按“不存在”继续执行；
+// ctx内不开启真正的事务，也不读写缓存；基于 Rows 的方法（SelectChan、RawSelect等）返回 gorm.ErrDryRunModeUnsupported
+func DryRun(ctx context.Context) (context.Context, *DryRunRecorder) {
+	rec := &DryRunRecorder{}
+	return context.WithValue(ctx, contextDryRunKey{}, rec), rec
+}
+
+func dryRunFromCtx(ctx context.Context) *DryRunRecorder {
+	rec, _ := ctx.Value(contextDryRunKey{}).(*DryRunRecorder)
+	return rec
+}
+
+// Statements 已记录的SQL
+func (r *DryRunRecorder) Statements() []DryRunStatement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]DryRunStatement(nil), r.stmts...)
+}
+
+// String 每条SQL一行
+func (r *DryRunRecorder) String() string {
+	stmts := r.Statements()
+	lines := make([]string, 0, len(stmts))
+	for _, s := range stmts {
+		lines = append(lines, s.SQL)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *DryRunRecorder) record(sql string, vars []any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stmts = append(r.stmts, DryRunStatement{SQL: sql, Vars: append([]any(nil), vars...)})
+}
+
+// dryRunLogger 通过 gorm 的 ParamsFilter 取得每条语句的SQL和参数
+type dryRunLogger struct {
+	logger.Interface
+	rec *DryRunRecorder
+}
+
+func (l dryRunLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return dryRunLogger{Interface: l.Interface.LogMode(level), rec: l.rec}
+}
+
+func (l dryRunLogger) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	fc()
+}
+
+func (l dryRunLogger) ParamsFilter(_ context.Context, sql string, params ...any) (string, []any) {
+	l.rec.record(sql, params)
+	return sql, params
+}
+
+// withDryRun ctx为 DryRun 时把db切换为只生成SQL的会话
+func withDryRun(ctx context.Context, db *gorm.DB) *gorm.DB {
+	rec := dryRunFromCtx(ctx)
+	if rec == nil || db.DryRun {
+		return db
+	}
+	return db.Session(&gorm.Session{DryRun: true, Logger: dryRunLogger{Interface: db.Logger, rec: rec}})
+}
@@ -0,0 +1,483 @@
+package gormx
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Codec 字段加解密，通过 EncryptionPlugin 按 `gormx:"encrypt:名称"` 标签使用
+type Codec interface {
+	Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+	// Deterministic 相同明文是否总是得到相同密文，只有确定性加密的字段可以作为等值查询的条件
+	Deterministic() bool
+}
+
+// KeyProvider 密钥来源，KMS 等外部密钥服务实现此接口即可，建议在实现中缓存数据密钥
+type KeyProvider interface {
+	// CurrentKey 加密使用的密钥及其ID，ID会写入密文，用于轮换密钥后解密旧数据
+	CurrentKey(ctx context.Context) (id string, key []byte, err error)
+	// Key 按ID取解密使用的密钥
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// EnvKeyProvider 从环境变量读取密钥，格式为 "id1:base64密钥,id2:base64密钥"，第一个为加密使用的密钥
+// 密钥长度为16、24或32字节，对应 AES-128、AES-192、AES-256；
+// 环境变量在第一次成功读取后缓存，修改环境变量后需要重启进程
+type EnvKeyProvider struct {
+	Var string
+
+	mu     sync.Mutex
+	ids    []string
+	parsed map[string][]byte
+}
+
+// keys 解析环境变量，解析失败时不缓存，下次调用重新读取
+func (p *EnvKeyProvider) keys() ([]string, map[string][]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.parsed != nil {
+		return p.ids, p.parsed, nil
+	}
+	raw := os.Getenv(p.Var)
+	if raw == "" {
+		return nil, nil, errors.Errorf("db: encryption key env %s is empty", p.Var)
+	}
+	var ids []string
+	keys := make(map[string][]byte)
+	for _, item := range strings.Split(raw, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok {
+			return nil, nil, errors.Errorf("db: invalid encryption key in env %s, want id:base64", p.Var)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "db: invalid encryption key %s in env %s", id, p.Var)
+		}
+		ids = append(ids, id)
+		keys[id] = key
+	}
+	p.ids, p.parsed = ids, keys
+	return ids, keys, nil
+}
+
+func (p *EnvKeyProvider) CurrentKey(context.Context) (string, []byte, error) {
+	ids, keys, err := p.keys()
+	if err != nil {
+		return "", nil, err
+	}
+	return ids[0], keys[ids[0]], nil
+}
+
+func (p *EnvKeyProvider) Key(_ context.Context, id string) ([]byte, error) {
+	_, keys, err := p.keys()
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keys[id]
+	if !ok {
+		return nil, errors.Errorf("db: encryption key %s not found in env %s", id, p.Var)
+	}
+	return key, nil
+}
+
+// encryptedPrefix 密文前缀，没有此前缀的值视为未加密的旧数据，读取时原样返回
+const encryptedPrefix = "gx1:"
+
+type aesGCM struct {
+	keys          KeyProvider
+	deterministic bool
+}
+
+// NewAESGCM AES-GCM 加密，密文为 gx1:密钥ID:base64(nonce+密文)
+// deterministic为true时nonce由明文的HMAC生成（HMAC密钥由加密密钥经HKDF派生），相同明文得到相同密文，
+// 可以用于等值查询，但会暴露哪些记录的值相同；
+// 轮换密钥后用旧密钥加密的记录无法再通过等值查询匹配，需要重新加密
+func NewAESGCM(keys KeyProvider, deterministic bool) Codec {
+	return aesGCM{keys: keys, deterministic: deterministic}
+}
+
+func (c aesGCM) Deterministic() bool {
+	return c.deterministic
+}
+
+func (c aesGCM) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	id, key, err := c.keys.CurrentKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if c.deterministic {
+		mac := hmac.New(sha256.New, nonceKey(key))
+		mac.Write(plaintext)
+		copy(nonce, mac.Sum(nil))
+	} else if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return []byte(encryptedPrefix + id + ":" + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+func (c aesGCM) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(string(ciphertext), encryptedPrefix), ":")
+	if !ok {
+		return nil, errors.New("db: invalid ciphertext, missing key id")
+	}
+	key, err := c.keys.Key(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "db: invalid ciphertext")
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("db: invalid ciphertext, too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+}
+
+// nonceKeyInfo HKDF 派生确定性nonce密钥时使用的info
+const nonceKeyInfo = "gormx aes-gcm deterministic nonce"
+
+// nonceKey 确定性nonce的HMAC密钥，由加密密钥经 HKDF-SHA256（RFC 5869，salt为空）派生，不与AES-GCM共用同一个密钥
+func nonceKey(key []byte) []byte {
+	extract := hmac.New(sha256.New, nil)
+	extract.Write(key)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte(nonceKeyInfo))
+	expand.Write([]byte{1})
+	return expand.Sum(nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "db: invalid encryption key")
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptionPlugin gorm插件，对带有 `gormx:"encrypt:名称"` 标签的 string 或 []byte 字段在写入时加密、读取时解密，
+// 名称对应Codecs中的 Codec：
+//
+//	keys := &gormx.EnvKeyProvider{Var: "DB_ENCRYPTION_KEYS"}
+//	db.Use(gormx.EncryptionPlugin{Codecs: map[string]gormx.Codec{
+//		"aes-gcm":     gormx.NewAESGCM(keys, false),
+//		"aes-gcm-det": gormx.NewAESGCM(keys, true),
+//	}})
+//
+//	type User struct {
+//		IDCard string `gormx:"encrypt:aes-gcm"`
+//		Phone  string `gormx:"encrypt:aes-gcm-det"` // 可以作为 SelectByMap 等的等值条件
+//	}
+//
+// 写入后调用方的结构体和map中仍是明文；非确定性加密的字段不能作为查询条件；
+// 带有加密字段的模型不使用 WithCache 等缓存，避免明文写入缓存；Raw 查询和 LIKE 等条件不做处理
+type EncryptionPlugin struct {
+	Codecs map[string]Codec
+}
+
+func (EncryptionPlugin) Name() string {
+	return "gormx:encryption"
+}
+
+func (p EncryptionPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	name := "gormx:encryption"
+	if err := cb.Create().Before("gorm:create").Register(name+":before", p.beforeWrite); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Register(name+":after", p.restore); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register(name+":before", p.beforeWrite); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register(name+":after", p.restore); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register(name+":before", p.encryptWhere); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register(name+":before", p.encryptWhere); err != nil {
+		return err
+	}
+	return cb.Query().After("gorm:query").Register(name+":after", p.afterQuery)
+}
+
+var encryptedFieldsCache sync.Map // *schema.Schema -> map[string]*encryptedField
+
+type encryptedField struct {
+	field *schema.Field
+	codec string
+}
+
+// encryptedFields 模型中需要加密的字段，key为列名
+func encryptedFields(s *schema.Schema) map[string]*encryptedField {
+	if v, ok := encryptedFieldsCache.Load(s); ok {
+		return v.(map[string]*encryptedField)
+	}
+	fields := make(map[string]*encryptedField)
+	for _, field := range s.Fields {
+		if codec, ok := gormxTagSettings(field)["ENCRYPT"]; ok && field.DBName != "" {
+			fields[field.DBName] = &encryptedField{field: field, codec: codec}
+		}
+	}
+	encryptedFieldsCache.Store(s, fields)
+	return fields
+}
+
+func (p EncryptionPlugin) codec(f *encryptedField) (Codec, error) {
+	c, ok := p.Codecs[f.codec]
+	if !ok {
+		return nil, errors.Errorf("db: codec %s of field %s is not registered", f.codec, f.field.Name)
+	}
+	return c, nil
+}
+
+// encryptValue 加密string或[]byte，返回与原值相同的类型
+func (p EncryptionPlugin) encryptValue(ctx context.Context, f *encryptedField, v any) (any, error) {
+	c, err := p.codec(f)
+	if err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case string:
+		out, err := c.Encrypt(ctx, []byte(v))
+		return string(out), err
+	case []byte:
+		return c.Encrypt(ctx, v)
+	default:
+		return nil, errors.Errorf("db: encrypted field %s must be string or []byte, got %T", f.field.Name, v)
+	}
+}
+
+func (p EncryptionPlugin) decryptValue(ctx context.Context, f *encryptedField, v any) (any, error) {
+	var raw []byte
+	switch v := v.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return v, nil
+	}
+	if !strings.HasPrefix(string(raw), encryptedPrefix) {
+		return v, nil
+	}
+	c, err := p.codec(f)
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.Decrypt(ctx, raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: decrypt field %s error", f.field.Name)
+	}
+	if _, ok := v.(string); ok {
+		return string(out), nil
+	}
+	return out, nil
+}
+
+const encryptionRestoreKey = "gormx:encryption_restore"
+
+// beforeWrite 把结构体和map中的明文替换为密文，原值在 restore 中恢复
+func (p EncryptionPlugin) beforeWrite(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	fields := encryptedFields(stmt.Schema)
+	if len(fields) == 0 {
+		return
+	}
+	var restores []func()
+	defer func() { db.InstanceSet(encryptionRestoreKey, restores) }()
+
+	if dest, ok := stmt.Dest.(map[string]any); ok {
+		for key, v := range dest {
+			f, ok := fields[key]
+			if !ok {
+				if field := stmt.Schema.LookUpField(key); field != nil {
+					f, ok = fields[field.DBName]
+				}
+			}
+			if !ok || v == nil {
+				continue
+			}
+			if _, isExpr := v.(clause.Expression); isExpr {
+				continue
+			}
+			enc, err := p.encryptValue(stmt.Context, f, v)
+			if err != nil {
+				_ = db.AddError(err)
+				return
+			}
+			key, orig := key, v
+			dest[key] = enc
+			restores = append(restores, func() { dest[key] = orig })
+		}
+	}
+	forEachRow(stmt.ReflectValue, func(rv reflect.Value) {
+		for _, f := range fields {
+			v, zero := f.field.ValueOf(stmt.Context, rv)
+			if zero {
+				continue
+			}
+			enc, err := p.encryptValue(stmt.Context, f, v)
+			if err == nil {
+				err = f.field.Set(stmt.Context, rv, enc)
+			}
+			if err != nil {
+				_ = db.AddError(err)
+				return
+			}
+			field, orig := f.field, v
+			restores = append(restores, func() { _ = field.Set(stmt.Context, rv, orig) })
+		}
+	})
+	p.encryptWhere(db)
+}
+
+func (EncryptionPlugin) restore(db *gorm.DB) {
+	v, ok := db.InstanceGet(encryptionRestoreKey)
+	if !ok {
+		return
+	}
+	for _, fn := range v.([]func()) {
+		fn()
+	}
+}
+
+// encryptWhere 把条件中确定性加密字段的 = 、IN 的值加密
+func (p EncryptionPlugin) encryptWhere(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	fields := encryptedFields(stmt.Schema)
+	c, ok := stmt.Clauses["WHERE"]
+	if len(fields) == 0 || !ok {
+		return
+	}
+	where, ok := c.Expression.(clause.Where)
+	if !ok {
+		return
+	}
+	lookup := func(column any) (*encryptedField, error) {
+		var name string
+		switch col := column.(type) {
+		case string:
+			name = col
+		case clause.Column:
+			name = col.Name
+		}
+		f, ok := fields[name]
+		if !ok {
+			return nil, nil
+		}
+		codec, err := p.codec(f)
+		if err != nil {
+			return nil, err
+		}
+		if !codec.Deterministic() {
+			return nil, errors.Errorf("db: field %s uses non-deterministic encryption and cannot be used in conditions", f.field.Name)
+		}
+		return f, nil
+	}
+	exprs := make([]clause.Expression, len(where.Exprs))
+	copy(exprs, where.Exprs)
+	for i, expr := range exprs {
+		var err error
+		switch e := expr.(type) {
+		case clause.Eq:
+			var f *encryptedField
+			if f, err = lookup(e.Column); f != nil {
+				e.Value, err = p.encryptValue(stmt.Context, f, e.Value)
+				exprs[i] = e
+			}
+		case clause.IN:
+			var f *encryptedField
+			if f, err = lookup(e.Column); f != nil {
+				values := make([]any, len(e.Values))
+				for j, v := range e.Values {
+					if values[j], err = p.encryptValue(stmt.Context, f, v); err != nil {
+						break
+					}
+				}
+				e.Values = values
+				exprs[i] = e
+			}
+		}
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+	}
+	c.Expression = clause.Where{Exprs: exprs}
+	stmt.Clauses["WHERE"] = c
+}
+
+func (p EncryptionPlugin) afterQuery(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	fields := encryptedFields(stmt.Schema)
+	if len(fields) == 0 {
+		return
+	}
+	forEachRow(stmt.ReflectValue, func(rv reflect.Value) {
+		for _, f := range fields {
+			v, zero := f.field.ValueOf(stmt.Context, rv)
+			if zero {
+				continue
+			}
+			dec, err := p.decryptValue(stmt.Context, f, v)
+			if err == nil {
+				err = f.field.Set(stmt.Context, rv, dec)
+			}
+			if err != nil {
+				_ = db.AddError(err)
+				return
+			}
+		}
+	})
+}
+
+// forEachRow 对结构体或结构体切片中的每条记录执行fn
+func forEachRow(rv reflect.Value, fn func(rv reflect.Value)) {
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if row := reflect.Indirect(rv.Index(i)); row.Kind() == reflect.Struct {
+				fn(row)
+			}
+		}
+	case reflect.Struct:
+		fn(rv)
+	}
+}
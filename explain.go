@@ -0,0 +1,217 @@
+package gormx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// PlanNode 执行计划中的一个节点
+type PlanNode struct {
+	Table string // 表
+	Type  string // mysql 的访问类型（ALL、ref、range等）或 postgres 的节点类型（Seq Scan、Index Scan等）
+	Key   string // 使用的索引
+	Rows  int64  // 预估扫描的行数
+	Extra string // mysql 的 Extra 列；postgres 为空
+	Depth int    // 节点深度，postgres 子节点的深度比父节点大1；mysql 均为0
+}
+
+// Plan 结构化的执行计划，支持 mysql 和 postgres
+type Plan struct {
+	Nodes []PlanNode
+}
+
+// FullScan 是否有全表扫描的节点
+func (p Plan) FullScan() bool {
+	for _, n := range p.Nodes {
+		if n.Type == "ALL" || n.Type == "Seq Scan" {
+			return true
+		}
+	}
+	return false
+}
+
+// String 每个节点一行，例如 "orders ref key=idx_user_id rows=12"
+func (p Plan) String() string {
+	lines := make([]string, 0, len(p.Nodes))
+	for _, n := range p.Nodes {
+		line := strings.Repeat("  ", n.Depth) + strings.TrimSpace(n.Table+" "+n.Type)
+		if n.Key != "" {
+			line += " key=" + n.Key
+		}
+		line += " rows=" + strconv.FormatInt(n.Rows, 10)
+		if n.Extra != "" {
+			line += " (" + n.Extra + ")"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LogValue 实现 slog.LogValuer
+func (p Plan) LogValue() slog.Value {
+	return slog.StringValue(p.String())
+}
+
+// ExplainSelectByMap 返回 SelectByMap（page不为nil时为按条件的分页查询）的执行计划，不执行查询本身
+// ctx中的 WithIndexHint 等查询选项同样生效
+func (b *BaseRepo[T]) ExplainSelectByMap(ctx context.Context, condition map[string]any, page *PageParam) (Plan, error) {
+	var (
+		m   T
+		res []*T
+	)
+	query := b.readDB(ctx).Model(&m).Scopes(b.notDeleted).Scopes(whereCond(b.columnKeys(condition)))
+	if page != nil {
+		query = query.Offset(int(page.PageNo-1) * int(page.PageSize)).Limit(int(page.PageSize))
+		if page.OrderBy != "" {
+			query = query.Order(page.OrderBy)
+		}
+	}
+	stmt := withIndexHints(ctx, query).Session(&gorm.Session{DryRun: true}).Find(&res).Statement
+	if err := stmt.Error; err != nil {
+		return Plan{}, errors.Wrapf(err, "db: explain %s error, condition: %v", b.StructName, condition)
+	}
+	plan, err := explain(b.readDB(ctx), stmt.SQL.String(), stmt.Vars)
+	if err != nil {
+		return Plan{}, errors.Wrapf(err, "db: explain %s error, condition: %v", b.StructName, condition)
+	}
+	return plan, nil
+}
+
+// explain 在db上执行 EXPLAIN 并解析结果
+func explain(db *gorm.DB, sql string, vars []any) (Plan, error) {
+	db = db.Session(&gorm.Session{NewDB: true})
+	switch name := db.Dialector.Name(); name {
+	case "mysql":
+		var rows []map[string]any
+		if err := db.Raw("EXPLAIN "+sql, vars...).Scan(&rows).Error; err != nil {
+			return Plan{}, err
+		}
+		var plan Plan
+		for _, row := range rows {
+			rowsExamined, _ := strconv.ParseInt(planText(row["rows"]), 10, 64)
+			plan.Nodes = append(plan.Nodes, PlanNode{
+				Table: planText(row["table"]),
+				Type:  planText(row["type"]),
+				Key:   planText(row["key"]),
+				Rows:  rowsExamined,
+				Extra: planText(row["Extra"]),
+			})
+		}
+		return plan, nil
+	case "postgres":
+		var out string
+		if err := db.Raw("EXPLAIN (FORMAT JSON) "+sql, vars...).Row().Scan(&out); err != nil {
+			return Plan{}, err
+		}
+		var doc []struct {
+			Plan pgPlanNode `json:"Plan"`
+		}
+		if err := json.Unmarshal([]byte(out), &doc); err != nil {
+			return Plan{}, errors.Wrap(err, "parse postgres plan")
+		}
+		var plan Plan
+		for _, d := range doc {
+			d.Plan.flatten(&plan, 0)
+		}
+		return plan, nil
+	default:
+		return Plan{}, errors.Errorf("explain is not supported by %s", name)
+	}
+}
+
+type pgPlanNode struct {
+	NodeType     string       `json:"Node Type"`
+	RelationName string       `json:"Relation Name"`
+	IndexName    string       `json:"Index Name"`
+	PlanRows     float64      `json:"Plan Rows"`
+	Plans        []pgPlanNode `json:"Plans"`
+}
+
+func (n pgPlanNode) flatten(plan *Plan, depth int) {
+	plan.Nodes = append(plan.Nodes, PlanNode{
+		Table: n.RelationName,
+		Type:  n.NodeType,
+		Key:   n.IndexName,
+		Rows:  int64(n.PlanRows),
+		Depth: depth,
+	})
+	for _, child := range n.Plans {
+		child.flatten(plan, depth+1)
+	}
+}
+
+// planText mysql 驱动以 []byte 返回 EXPLAIN 的列，NULL 为空字符串
+func planText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+const explainStartKey = "gormx:explain_start"
+
+// ExplainPlugin gorm插件，SELECT 的耗时超过Threshold时执行 EXPLAIN 并把执行计划记录到日志，用于排查慢查询
+//
+//	db.Use(gormx.ExplainPlugin{Threshold: 200 * time.Millisecond, Logger: slog.Default()})
+//
+// EXPLAIN 会在同一个连接（事务）上再执行一次，只应在排查问题时开启
+type ExplainPlugin struct {
+	Threshold time.Duration
+	Logger    *slog.Logger // 为nil时使用 slog.Default()
+}
+
+func (ExplainPlugin) Name() string {
+	return "gormx:explain"
+}
+
+func (p ExplainPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback().Query()
+	if err := cb.Before("gorm:query").Register("gormx:explain:before", p.before); err != nil {
+		return err
+	}
+	return cb.After("gorm:query").Register("gormx:explain:after", p.after)
+}
+
+func (ExplainPlugin) before(db *gorm.DB) {
+	db.InstanceSet(explainStartKey, time.Now())
+}
+
+func (p ExplainPlugin) after(db *gorm.DB) {
+	start, ok := db.InstanceGet(explainStartKey)
+	if !ok || db.Error != nil || db.DryRun {
+		return
+	}
+	elapsed := time.Since(start.(time.Time))
+	if elapsed < p.Threshold {
+		return
+	}
+	sql := db.Statement.SQL.String()
+	if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(sql)), "SELECT") {
+		return
+	}
+	logger := p.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	ctx := db.Statement.Context
+	plan, err := explain(db, sql, db.Statement.Vars)
+	if err != nil {
+		logger.WarnContext(ctx, "gormx: explain slow query error", "sql", sql, "elapsed", elapsed, "error", err)
+		return
+	}
+	logger.WarnContext(ctx, "gormx: slow query plan", "sql", sql, "elapsed", elapsed, "full_scan", plan.FullScan(), "plan", plan)
+}
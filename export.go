@@ -0,0 +1,254 @@
+package gormx
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm/schema"
+)
+
+// ExportOptions ExportCSV、ExportXLSX 的选项
+//
+// 表头默认为列名，可以通过 `gormx:"export:姓名"` 指定，`gormx:"export:-"` 的字段不导出
+type ExportOptions struct {
+	// 导出的列（字段名或列名），按顺序输出，为空时为所有列
+	Columns []string
+	// 每批读取的条数，为0时为500
+	BatchSize int
+	// 时间的格式，为空时为 time.DateTime
+	TimeFormat string
+	// 默认按 MaskingPlugin 的规则脱敏，模型有脱敏字段而db没有注册 MaskingPlugin 时返回错误；
+	// 内部任务等需要原值时设置为true
+	Unmasked bool
+	// CSV 开头写入 UTF-8 BOM，Excel 打开中文不乱码
+	BOM bool
+}
+
+type exportColumn struct {
+	field  *schema.Field
+	header string
+}
+
+func (b *BaseRepo[T]) exportColumns(opts ExportOptions) ([]exportColumn, error) {
+	s, err := b.gormSchema()
+	if err != nil {
+		return nil, err
+	}
+	header := func(f *schema.Field) (string, bool) {
+		h, ok := gormxTagSettings(f)["EXPORT"]
+		if !ok || h == "" {
+			return f.DBName, true
+		}
+		return h, h != "-"
+	}
+	var columns []exportColumn
+	if len(opts.Columns) == 0 {
+		for _, f := range s.Fields {
+			if f.DBName == "" {
+				continue
+			}
+			if h, ok := header(f); ok {
+				columns = append(columns, exportColumn{field: f, header: h})
+			}
+		}
+		return columns, nil
+	}
+	for _, name := range opts.Columns {
+		f, err := b.lookupColumn(name)
+		if err != nil {
+			return nil, errors.WithMessage(err, "export")
+		}
+		h, _ := header(f)
+		if h == "-" {
+			h = f.DBName
+		}
+		columns = append(columns, exportColumn{field: f, header: h})
+	}
+	return columns, nil
+}
+
+// exportRows 分批读取满足条件的记录，按列转换为字符串后交给write，返回导出的条数
+func (b *BaseRepo[T]) exportRows(ctx context.Context, condition map[string]any, opts ExportOptions,
+	write func(record []string) error) (int64, error) {
+	columns, err := b.exportColumns(opts)
+	if err != nil {
+		return 0, err
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.TimeFormat == "" {
+		opts.TimeFormat = time.DateTime
+	}
+	if !opts.Unmasked {
+		if err = b.checkMasking(); err != nil {
+			return 0, err
+		}
+		ctx = WithMasking(ctx)
+	}
+	record := make([]string, len(columns))
+	for i, c := range columns {
+		record[i] = c.header
+	}
+	if err = write(record); err != nil {
+		return 0, err
+	}
+	var n int64
+	err = b.SelectEach(ctx, condition, opts.BatchSize, func(rows []*T) error {
+		for _, row := range rows {
+			rv := indirectModel(reflect.ValueOf(row))
+			for i, c := range columns {
+				record[i] = exportValue(c.field.ReflectValueOf(ctx, rv), opts.TimeFormat)
+			}
+			if err := write(record); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return n, errors.Wrapf(err, "db: export %s error", b.StructName)
+	}
+	return n, nil
+}
+
+// checkMasking 导出需要脱敏时确认 MaskingPlugin 已注册，避免静默导出原值
+func (b *BaseRepo[T]) checkMasking() error {
+	s, err := b.gormSchema()
+	if err != nil {
+		return err
+	}
+	if len(maskedFields(s)) == 0 {
+		return nil
+	}
+	if _, ok := maskingPlugin(b.GormDB); !ok {
+		return errors.Errorf("db: export %s error, masking plugin is not registered, register MaskingPlugin or set Unmasked", b.StructName)
+	}
+	return nil
+}
+
+func exportValue(v reflect.Value, timeFormat string) string {
+	v = Indirect(v)
+	if !v.IsValid() {
+		return ""
+	}
+	switch x := v.Interface().(type) {
+	case time.Time:
+		if x.IsZero() {
+			return ""
+		}
+		return x.Format(timeFormat)
+	case []byte:
+		return escapeFormula(string(x))
+	case fmt.Stringer:
+		return escapeFormula(x.String())
+	}
+	if v.Kind() == reflect.String {
+		return escapeFormula(v.String())
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// escapeFormula 以 = + - @ 等开头的文本在Excel中会被当作公式执行（CSV注入），前面加上 ' 按文本显示；
+// 数值列不经过这里，负数不受影响
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// ExportCSV 以CSV格式导出满足条件的记录，第一行为表头，按主键顺序分批读取，不会一次加载到内存：
+//
+//	w.Header().Set("Content-Type", "text/csv")
+//	n, err := repo.ExportCSV(ctx, w, condition, gormx.ExportOptions{BOM: true})
+func (b *BaseRepo[T]) ExportCSV(ctx context.Context, w io.Writer, condition map[string]any, opts ExportOptions) (int64, error) {
+	if opts.BOM {
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return 0, errors.Wrapf(err, "db: export %s error", b.StructName)
+		}
+	}
+	cw := csv.NewWriter(w)
+	n, err := b.exportRows(ctx, condition, opts, cw.Write)
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	return n, err
+}
+
+// xlsx 中除工作表外的固定文件
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// ExportXLSX 与 ExportCSV 相同，输出只有一个工作表的xlsx文件，所有单元格为文本
+func (b *BaseRepo[T]) ExportXLSX(ctx context.Context, w io.Writer, condition map[string]any, opts ExportOptions) (int64, error) {
+	zw := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		pw, err := zw.Create(part.name)
+		if err == nil {
+			_, err = io.WriteString(pw, part.content)
+		}
+		if err != nil {
+			return 0, errors.Wrapf(err, "db: export %s error", b.StructName)
+		}
+	}
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return 0, errors.Wrapf(err, "db: export %s error", b.StructName)
+	}
+	bw := bufio.NewWriter(sheet)
+	_, _ = bw.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	n, err := b.exportRows(ctx, condition, opts, func(record []string) error {
+		_, _ = bw.WriteString("<row>")
+		for _, cell := range record {
+			_, _ = bw.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(bw, []byte(cell)); err != nil {
+				return err
+			}
+			_, _ = bw.WriteString("</t></is></c>")
+		}
+		_, err := bw.WriteString("</row>")
+		return err
+	})
+	if err != nil {
+		return n, err
+	}
+	_, _ = bw.WriteString("</sheetData></worksheet>")
+	if err = bw.Flush(); err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return n, errors.Wrapf(err, "db: export %s error", b.StructName)
+	}
+	return n, nil
+}
@@ -0,0 +1,169 @@
+package gormx
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExportProgress 导出任务的进度，Cursor为最后一条已处理记录主键的json
+type ExportProgress struct {
+	ID       int64     `gorm:"column:id;primaryKey;autoIncrement"`
+	Job      string    `gorm:"column:job;size:128;NOT NULL;uniqueIndex"`
+	Cursor   string    `gorm:"column:cursor;size:512"`
+	Exported int64     `gorm:"column:exported;NOT NULL"`
+	Done     bool      `gorm:"column:done;NOT NULL"`
+	UpdateAt time.Time `gorm:"column:update_at;NOT NULL"`
+}
+
+func (ExportProgress) TableName() string {
+	return "gormx_export_progress"
+}
+
+// ExportIterator 按主键顺序分批读取数据，通过 Commit 把游标保存到进度表，
+// 任务被中断（发布、故障）后用相同的job名称重新创建即可从上次提交的位置继续
+//
+// 示例：
+//
+//	it, err := repo.NewExportIterator(ctx, "export_orders_20240101", condition, 1000)
+//	for {
+//		rows, err := it.Next(ctx)
+//		if err != nil {
+//			return err
+//		}
+//		write(rows)
+//		if err = it.Commit(ctx); err != nil || len(rows) == 0 {
+//			return err
+//		}
+//	}
+type ExportIterator[T any] struct {
+	repo      *BaseRepo[T]
+	job       string
+	condition map[string]any
+	batchSize int
+
+	progress ExportProgress
+	cursor   any
+	pending  any
+	fetched  int64
+}
+
+// NewExportIterator 加载job的进度，进度表需要提前通过 MigrateExportProgress 创建
+func (b *BaseRepo[T]) NewExportIterator(ctx context.Context, job string, condition map[string]any, batchSize int) (*ExportIterator[T], error) {
+	it := &ExportIterator[T]{
+		repo:      b,
+		job:       job,
+		condition: b.columnKeys(condition),
+		batchSize: batchSize,
+		progress:  ExportProgress{Job: job},
+	}
+	var saved []ExportProgress
+	if err := b.withTransactionCtx(ctx).Where("job = ?", job).Limit(1).Find(&saved).Error; err != nil {
+		return nil, errors.Wrapf(err, "db: load export progress %s error", job)
+	}
+	if len(saved) > 0 {
+		it.progress = saved[0]
+		if it.progress.Cursor != "" {
+			cursor, err := b.decodeCursor(it.progress.Cursor)
+			if err != nil {
+				return nil, errors.Wrapf(err, "db: decode export cursor %s error, cursor: %s", job, it.progress.Cursor)
+			}
+			it.cursor = cursor
+		}
+	}
+	return it, nil
+}
+
+// Next 读取下一批数据，没有更多数据时返回空切片，此后 Commit 会把任务标记为完成
+// 连续调用 Next 而不调用 Commit 时，中断后会从上一次 Commit 的位置重新读取
+func (it *ExportIterator[T]) Next(ctx context.Context) ([]*T, error) {
+	if it.progress.Done {
+		return nil, nil
+	}
+	b := it.repo
+	cursor := it.cursor
+	if it.pending != nil {
+		cursor = it.pending
+	}
+	res, err := b.selectBatchAfter(ctx, it.condition, cursor, it.batchSize)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "export job: %s", it.job)
+	}
+	if len(res) == 0 {
+		it.progress.Done = true
+		return nil, nil
+	}
+	if last, ok := b.pkValue(ctx, res[len(res)-1]); ok {
+		it.pending = last
+	}
+	it.fetched += int64(len(res))
+	return res, nil
+}
+
+// Commit 保存已处理到的位置
+func (it *ExportIterator[T]) Commit(ctx context.Context) error {
+	if it.pending != nil {
+		raw, err := json.Marshal(it.pending)
+		if err != nil {
+			return errors.Wrapf(err, "db: encode export cursor %s error", it.job)
+		}
+		it.progress.Cursor = string(raw)
+		it.cursor = it.pending
+		it.pending = nil
+	}
+	it.progress.Exported += it.fetched
+	it.fetched = 0
+	it.progress.UpdateAt = time.Now()
+	if err := it.repo.withTransactionCtx(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "job"}},
+		DoUpdates: clause.AssignmentColumns([]string{"cursor", "exported", "done", "update_at"}),
+	}).Create(&it.progress).Error; err != nil {
+		return errors.Wrapf(err, "db: save export progress %s error", it.job)
+	}
+	return nil
+}
+
+// Progress 当前已提交的进度
+func (it *ExportIterator[T]) Progress() ExportProgress {
+	return it.progress
+}
+
+// Reset 清空进度，下次 Next 从头开始
+func (it *ExportIterator[T]) Reset(ctx context.Context) error {
+	if err := it.repo.withTransactionCtx(ctx).Where("job = ?", it.job).Delete(&ExportProgress{}).Error; err != nil {
+		return errors.Wrapf(err, "db: reset export progress %s error", it.job)
+	}
+	it.progress = ExportProgress{Job: it.job}
+	it.cursor, it.pending, it.fetched = nil, nil, 0
+	return nil
+}
+
+// decodeCursor 按主键字段的类型解析游标，避免数字被解析为float64
+func (b *BaseRepo[T]) decodeCursor(raw string) (any, error) {
+	s, err := b.gormSchema()
+	if err != nil {
+		return nil, err
+	}
+	field := s.LookUpField(b.PrimaryKey)
+	if field == nil {
+		return nil, errors.Errorf("primary key %s not found", b.PrimaryKey)
+	}
+	v := reflect.New(field.FieldType)
+	if err = json.Unmarshal([]byte(raw), v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
+
+// MigrateExportProgress 创建导出进度表
+func MigrateExportProgress(db *gorm.DB) error {
+	if err := db.AutoMigrate(&ExportProgress{}); err != nil {
+		return errors.Wrap(err, "db: migrate export progress error")
+	}
+	return nil
+}
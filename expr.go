@@ -0,0 +1,126 @@
+package gormx
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm/clause"
+)
+
+var ErrUnknownColumn = errors.New("db: unknown column")
+
+// UpdateExpr UpdateByMap 中作为更新值的SQL表达式，通过 Expr 构造
+type UpdateExpr struct {
+	SQL  string
+	Vars []any
+}
+
+// Expr 用于 UpdateByMap 的SQL表达式，表达式中引用的列在执行前会按模型校验，例如：
+//
+//	repo.UpdateByMap(ctx, condition, map[string]any{
+//		"price": gormx.Expr("price * ?", 1.1),
+//		"stock": gormx.Expr("stock - ?", 1),
+//	})
+//
+// 也可以直接使用 gorm.Expr，但不会校验列名
+func Expr(sql string, vars ...any) UpdateExpr {
+	return UpdateExpr{SQL: sql, Vars: vars}
+}
+
+// exprKeywords 表达式中允许出现的非列名标识符
+var exprKeywords = map[string]struct{}{
+	"AND": {}, "OR": {}, "NOT": {}, "NULL": {}, "IS": {}, "IN": {}, "LIKE": {}, "BETWEEN": {},
+	"CASE": {}, "WHEN": {}, "THEN": {}, "ELSE": {}, "END": {}, "TRUE": {}, "FALSE": {},
+	"DIV": {}, "MOD": {}, "INTERVAL": {}, "AS": {}, "DISTINCT": {},
+	"SECOND": {}, "MINUTE": {}, "HOUR": {}, "DAY": {}, "WEEK": {}, "MONTH": {}, "YEAR": {},
+}
+
+// resolveUpdateExprs 校验updateData中 UpdateExpr 引用的列，并转换为gorm可以识别的表达式
+// 返回新的map，不修改updateData
+func (b *BaseRepo[T]) resolveUpdateExprs(updateData map[string]any) (map[string]any, error) {
+	var hasExpr bool
+	for _, v := range updateData {
+		if _, ok := v.(UpdateExpr); ok {
+			hasExpr = true
+			break
+		}
+	}
+	if !hasExpr {
+		return updateData, nil
+	}
+	s, err := b.gormSchema()
+	if err != nil {
+		return nil, err
+	}
+	resolved := make(map[string]any, len(updateData))
+	for k, v := range updateData {
+		e, ok := v.(UpdateExpr)
+		if !ok {
+			resolved[k] = v
+			continue
+		}
+		for _, col := range exprColumns(e.SQL) {
+			if s.LookUpField(col) == nil {
+				return nil, errors.Wrapf(ErrUnknownColumn, "db: update %s expression error, column: %s, expression: %s", b.StructName, col, e.SQL)
+			}
+		}
+		resolved[k] = clause.Expr{SQL: e.SQL, Vars: e.Vars}
+	}
+	return resolved, nil
+}
+
+// exprColumns 提取表达式中引用的列名，忽略字符串、数字、关键字和函数名
+func exprColumns(sql string) []string {
+	var cols []string
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"':
+			// 字符串字面量
+			j := i + 1
+			for j < len(sql) && sql[j] != c {
+				if sql[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			i = j + 1
+		case c == '`':
+			j := strings.IndexByte(sql[i+1:], '`')
+			if j < 0 {
+				return append(cols, sql[i+1:])
+			}
+			cols = append(cols, sql[i+1:i+1+j])
+			i += j + 2
+		case isIdentStart(c):
+			j := i
+			for j < len(sql) && (isIdentStart(sql[j]) || sql[j] >= '0' && sql[j] <= '9') {
+				j++
+			}
+			word := sql[i:j]
+			k := j
+			for k < len(sql) && sql[k] == ' ' {
+				k++
+			}
+			_, keyword := exprKeywords[strings.ToUpper(word)]
+			isFunc := k < len(sql) && sql[k] == '('
+			// table.column 只校验列名
+			if !keyword && !isFunc && (j >= len(sql) || sql[j] != '.') {
+				cols = append(cols, word)
+			}
+			i = j
+		case c >= '0' && c <= '9':
+			// 数字，包括 1.5、1e3
+			for i < len(sql) && (isIdentStart(sql[i]) || sql[i] == '.' || sql[i] >= '0' && sql[i] <= '9') {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	return cols
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
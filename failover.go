@@ -0,0 +1,340 @@
+package gormx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// ErrPrimaryUnavailable 已切换到备库且备库未提升为主库，写操作无法执行
+var ErrPrimaryUnavailable = errors.New("db: primary is unavailable")
+
+// errStandbyPromoted Promote 切换到备库时传给 OnStateChange 的原因
+var errStandbyPromoted = errors.New("db: standby is promoted")
+
+// FailoverState 当前使用的数据源
+type FailoverState int8
+
+const (
+	FailoverPrimary FailoverState = iota
+	FailoverStandby
+)
+
+func (s FailoverState) String() string {
+	if s == FailoverStandby {
+		return "standby"
+	}
+	return "primary"
+}
+
+// FailoverConfig 主备切换配置，零值字段使用括号中的默认值
+type FailoverConfig struct {
+	// 探测主库的间隔（5s）和单次探测的超时（1s）
+	ProbeInterval time.Duration
+	ProbeTimeout  time.Duration
+	// 主库连续 FailureThreshold（3）次连接错误或探测失败后切换到备库
+	FailureThreshold int
+	// 切换后主库连续 RecoverThreshold（3）次探测成功后切回主库，备库可写时不切回
+	RecoverThreshold int
+	// 备库已提升为主库（例如由MHA、Patroni等切换），切换后写操作也发送到备库且不再切回旧主库，避免两边都有写入；
+	// 为false时切换后写操作返回 ErrPrimaryUnavailable，也可以在运行时通过 Failover.Promote 开启
+	StandbyWritable bool
+	// 切换时回调，cause 为切换到备库的原因，切回主库时为nil；回调在切换完成后同步执行，不持有锁
+	OnStateChange func(from, to FailoverState, cause error)
+}
+
+// Failover 主备数据源，主库不可用时读操作透明地切换到备库，主库恢复后切回：
+//
+//	standby, _ := sql.Open("mysql", standbyDSN)
+//	f := gormx.NewFailover(standby, gormx.FailoverConfig{OnStateChange: alert})
+//	db.Use(gormx.FailoverPlugin{Failover: f})
+//	defer f.Close()
+//
+// 连接断开等错误由 database/sql 在下一次取连接时重新连接，切换只决定语句发送到哪个数据源。
+// 读取结果过程中断开的连接不切换；已经开启的事务留在原数据源，不会在中途切换
+type Failover struct {
+	cfg     FailoverConfig
+	standby *sql.DB
+	primary *sql.DB
+
+	mu        sync.Mutex
+	state     FailoverState
+	failures  int
+	successes int
+
+	writable  atomic.Bool
+	started   atomic.Bool
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewFailover 创建主备切换，standby 为备库的连接池，主库为注册 FailoverPlugin 的db
+func NewFailover(standby *sql.DB, cfg FailoverConfig) *Failover {
+	if cfg.ProbeInterval <= 0 {
+		cfg.ProbeInterval = 5 * time.Second
+	}
+	if cfg.ProbeTimeout <= 0 {
+		cfg.ProbeTimeout = time.Second
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 3
+	}
+	if cfg.RecoverThreshold <= 0 {
+		cfg.RecoverThreshold = 3
+	}
+	f := &Failover{cfg: cfg, standby: standby, stop: make(chan struct{})}
+	f.writable.Store(cfg.StandbyWritable)
+	return f
+}
+
+// State 当前使用的数据源
+func (f *Failover) State() FailoverState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state
+}
+
+// Promote 备库已提升为主库：立即切换到备库，写操作发送到备库，停止探测且不再切回旧主库
+func (f *Failover) Promote() {
+	f.writable.Store(true)
+	f.mu.Lock()
+	from := f.state
+	f.state, f.failures, f.successes = FailoverStandby, 0, 0
+	f.mu.Unlock()
+	f.Close()
+	if from != FailoverStandby {
+		f.notify(from, FailoverStandby, errStandbyPromoted)
+	}
+}
+
+// Close 停止探测，不关闭主库和备库的连接池
+func (f *Failover) Close() {
+	f.closeOnce.Do(func() { close(f.stop) })
+}
+
+// observe 记录主库上的执行结果，连续的连接错误达到阈值时切换到备库
+func (f *Failover) observe(err error) {
+	if err != nil && !IsTransientConnError(err) {
+		return
+	}
+	f.mu.Lock()
+	if f.state != FailoverPrimary {
+		f.mu.Unlock()
+		return
+	}
+	if err == nil {
+		f.failures = 0
+		f.mu.Unlock()
+		return
+	}
+	f.failures++
+	if f.failures < f.cfg.FailureThreshold {
+		f.mu.Unlock()
+		return
+	}
+	f.state, f.failures, f.successes = FailoverStandby, 0, 0
+	f.mu.Unlock()
+	f.notify(FailoverPrimary, FailoverStandby, err)
+}
+
+// recovered 切换后记录主库的探测结果，连续成功达到阈值时切回主库；备库可写时可能已有写入，不切回
+func (f *Failover) recovered(err error) {
+	if f.writable.Load() {
+		return
+	}
+	f.mu.Lock()
+	if f.state != FailoverStandby {
+		f.mu.Unlock()
+		return
+	}
+	if err != nil {
+		f.successes = 0
+		f.mu.Unlock()
+		return
+	}
+	f.successes++
+	if f.successes < f.cfg.RecoverThreshold {
+		f.mu.Unlock()
+		return
+	}
+	f.state, f.failures, f.successes = FailoverPrimary, 0, 0
+	f.mu.Unlock()
+	f.notify(FailoverStandby, FailoverPrimary, nil)
+}
+
+func (f *Failover) notify(from, to FailoverState, cause error) {
+	if f.cfg.OnStateChange != nil {
+		f.cfg.OnStateChange(from, to, cause)
+	}
+}
+
+// probe 定期探测主库，没有请求时主库故障也能切换
+func (f *Failover) probe() {
+	ticker := time.NewTicker(f.cfg.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+		}
+		if f.writable.Load() && f.State() == FailoverStandby {
+			// 已切换到可写的备库，不会再切回，停止探测
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), f.cfg.ProbeTimeout)
+		err := f.primary.PingContext(ctx)
+		cancel()
+		if err != nil {
+			// 探测超时等任何错误都视为主库不可用
+			err = errors.Wrapf(driver.ErrBadConn, "ping primary: %v", err)
+		}
+		if f.State() == FailoverStandby {
+			f.recovered(err)
+		} else {
+			f.observe(err)
+		}
+	}
+}
+
+// FailoverPlugin gorm插件，为db开启主备切换，db本身的连接池为主库
+//
+// 同一个 Failover 只能注册到一个db上；db.DB() 返回当前使用的数据源的连接池
+type FailoverPlugin struct {
+	Failover *Failover
+}
+
+func (FailoverPlugin) Name() string {
+	return "gormx:failover"
+}
+
+func (p FailoverPlugin) Initialize(db *gorm.DB) error {
+	if p.Failover == nil || p.Failover.standby == nil {
+		return errors.New("db: failover plugin error, standby is nil")
+	}
+	primary, err := db.DB()
+	if err != nil {
+		return errors.Wrap(err, "db: failover plugin error")
+	}
+	if !p.Failover.started.CompareAndSwap(false, true) {
+		return errors.New("db: failover plugin error, failover is already used by another db")
+	}
+	p.Failover.primary = primary
+	pool := &failoverPool{ConnPool: db.ConnPool, f: p.Failover}
+	db.ConnPool = pool
+	db.Statement.ConnPool = pool
+	go p.Failover.probe()
+	return nil
+}
+
+// failoverPool 包装主库的连接池，按当前状态选择数据源
+type failoverPool struct {
+	gorm.ConnPool
+	f *Failover
+}
+
+// onStandby 语句是否应发送到备库，write 为true且备库不可写时返回 ErrPrimaryUnavailable
+func (p *failoverPool) onStandby(write bool) (bool, error) {
+	if p.f.State() == FailoverPrimary {
+		return false, nil
+	}
+	if write && !p.f.writable.Load() {
+		return false, ErrPrimaryUnavailable
+	}
+	return true, nil
+}
+
+func (p *failoverPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	standby, err := p.onStandby(!isReadSQL(query))
+	if err != nil {
+		return nil, err
+	}
+	if standby {
+		return p.f.standby.PrepareContext(ctx, query)
+	}
+	stmt, err := p.ConnPool.PrepareContext(ctx, query)
+	p.f.observe(err)
+	return stmt, err
+}
+
+func (p *failoverPool) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	standby, err := p.onStandby(!isReadSQL(query))
+	if err != nil {
+		return nil, err
+	}
+	if standby {
+		return p.f.standby.ExecContext(ctx, query, args...)
+	}
+	res, err := p.ConnPool.ExecContext(ctx, query, args...)
+	p.f.observe(err)
+	return res, err
+}
+
+func (p *failoverPool) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	read := isReadSQL(query)
+	standby, err := p.onStandby(!read)
+	if err != nil {
+		return nil, err
+	}
+	if standby {
+		return p.f.standby.QueryContext(ctx, query, args...)
+	}
+	rows, err := p.ConnPool.QueryContext(ctx, query, args...)
+	p.f.observe(err)
+	if err != nil && read && p.f.State() == FailoverStandby {
+		// 这次失败触发了切换，读操作直接在备库重新执行
+		return p.f.standby.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+func (p *failoverPool) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	read := isReadSQL(query)
+	if standby, err := p.onStandby(!read); err == nil && standby {
+		return p.f.standby.QueryRowContext(ctx, query, args...)
+	}
+	// 备库不可写时仍发送到主库，由主库返回错误
+	row := p.ConnPool.QueryRowContext(ctx, query, args...)
+	err := row.Err()
+	p.f.observe(err)
+	if err != nil && read && p.f.State() == FailoverStandby {
+		return p.f.standby.QueryRowContext(ctx, query, args...)
+	}
+	return row
+}
+
+// BeginTx 实现 gorm.ConnPoolBeginner，切换后只读事务或备库可写时在备库开启
+func (p *failoverPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	standby, err := p.onStandby(opts == nil || !opts.ReadOnly)
+	if err != nil {
+		return nil, err
+	}
+	if standby {
+		return p.f.standby.BeginTx(ctx, opts)
+	}
+	var tx gorm.ConnPool
+	switch beginner := p.ConnPool.(type) {
+	case gorm.TxBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	case gorm.ConnPoolBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	default:
+		return nil, gorm.ErrInvalidTransaction
+	}
+	p.f.observe(err)
+	return tx, err
+}
+
+// GetDBConn 实现 gorm.GetDBConnector，返回当前使用的数据源的 *sql.DB
+func (p *failoverPool) GetDBConn() (*sql.DB, error) {
+	if p.f.State() == FailoverStandby {
+		return p.f.standby, nil
+	}
+	return p.f.primary, nil
+}
@@ -0,0 +1,193 @@
+package gormx
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm/schema"
+)
+
+// fakeLike 按 LikeCondition 的模式匹配，! 为转义字符
+func fakeLike(got any, c LikeCondition) bool {
+	v := Indirect(reflect.ValueOf(got))
+	if !v.IsValid() || v.Kind() != reflect.String {
+		return false
+	}
+	var expr strings.Builder
+	if c.fold {
+		expr.WriteString("(?i)")
+	}
+	expr.WriteString("(?s)^")
+	escaped := false
+	for _, ch := range c.pattern {
+		switch {
+		case escaped:
+			expr.WriteString(regexp.QuoteMeta(string(ch)))
+			escaped = false
+		case string(ch) == likeEscape:
+			escaped = true
+		case ch == '%':
+			expr.WriteString(".*")
+		case ch == '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	return err == nil && re.MatchString(v.String())
+}
+
+// fakeArray 按 ArrayCondition 匹配切片字段，@> 为包含所有值，&& 为有交集
+func fakeArray(got any, c ArrayCondition) bool {
+	v := Indirect(reflect.ValueOf(got))
+	if !v.IsValid() || v.Kind() != reflect.Slice {
+		return false
+	}
+	contains := func(want any) bool {
+		for i := 0; i < v.Len(); i++ {
+			if fakeEqual(v.Index(i).Interface(), want) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, want := range c.values {
+		if contains(want) != (c.op == "@>") {
+			return c.op != "@>"
+		}
+	}
+	return c.op == "@>"
+}
+
+// fakeCompareCondition 按 CompareCondition 比较，与SQL一致NULL与任何值比较都不成立
+func fakeCompareCondition(got any, c CompareCondition) bool {
+	if fakeIsNull(got) || c.value == nil {
+		return false
+	}
+	if c.op == "<>" {
+		return !fakeEqual(got, c.value)
+	}
+	cmp := fakeCompare(got, c.value)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// fakeCompare 比较两个值的大小，NULL 最小，与 mysql 的升序一致
+func fakeCompare(a, b any) int {
+	av, bv := Indirect(reflect.ValueOf(a)), Indirect(reflect.ValueOf(b))
+	switch {
+	case !av.IsValid() && !bv.IsValid():
+		return 0
+	case !av.IsValid():
+		return -1
+	case !bv.IsValid():
+		return 1
+	}
+	if at, ok := av.Interface().(time.Time); ok {
+		if bt, ok := bv.Interface().(time.Time); ok {
+			return at.Compare(bt)
+		}
+	}
+	switch {
+	case isIntKind(av.Kind()) && isIntKind(bv.Kind()):
+		return compareOrdered(av.Int(), bv.Int())
+	case isUintKind(av.Kind()) && isUintKind(bv.Kind()):
+		return compareOrdered(av.Uint(), bv.Uint())
+	case isNumberKind(av.Kind()) && isNumberKind(bv.Kind()):
+		return compareOrdered(toFloat(av), toFloat(bv))
+	case av.Kind() == reflect.String && bv.Kind() == reflect.String:
+		return strings.Compare(av.String(), bv.String())
+	case av.Kind() == reflect.Bool && bv.Kind() == reflect.Bool:
+		return compareOrdered(boolInt(av.Bool()), boolInt(bv.Bool()))
+	}
+	return 0
+}
+
+func compareOrdered[V int64 | uint64 | float64](a, b V) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// fakeOrderBy 解析 PageParam.OrderBy，只支持 "列 [ASC|DESC], ..." 的形式，列兼容字段名和列名，可以带引号
+func fakeOrderBy(s *schema.Schema, orderBy string) ([]*schema.Field, []bool, error) {
+	var (
+		fields []*schema.Field
+		desc   []bool
+	)
+	for _, item := range strings.Split(orderBy, ",") {
+		parts := strings.Fields(item)
+		if len(parts) == 0 {
+			continue
+		}
+		if len(parts) > 2 {
+			return nil, nil, errors.Wrapf(ErrFakeUnsupported, "db: order by %s", item)
+		}
+		name := strings.Trim(parts[0], "`\"")
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			name = strings.Trim(name[i+1:], "`\"")
+		}
+		f := schemaColumn(s, name)
+		if f == nil {
+			return nil, nil, errors.Wrapf(ErrUnknownColumn, "db: %s order by column: %s", s.Name, name)
+		}
+		d := false
+		if len(parts) == 2 {
+			switch strings.ToUpper(parts[1]) {
+			case "ASC":
+			case "DESC":
+				d = true
+			default:
+				return nil, nil, errors.Wrapf(ErrFakeUnsupported, "db: order by %s", item)
+			}
+		}
+		fields = append(fields, f)
+		desc = append(desc, d)
+	}
+	return fields, desc, nil
+}
+
+// sortRows 按 orderBy 稳定排序，相等的记录保持插入顺序
+func (r *FakeRepo[T]) sortRows(ctx context.Context, rows []*T, orderBy string) error {
+	fields, desc, err := fakeOrderBy(r.schema, orderBy)
+	if err != nil || len(fields) == 0 {
+		return err
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		ri, rj := reflect.ValueOf(rows[i]).Elem(), reflect.ValueOf(rows[j]).Elem()
+		for k, f := range fields {
+			if c := fakeCompare(fakeValue(ctx, f, ri), fakeValue(ctx, f, rj)); c != 0 {
+				return (c < 0) != desc[k]
+			}
+		}
+		return false
+	})
+	return nil
+}
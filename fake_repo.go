@@ -0,0 +1,574 @@
+package gormx
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrFakeUnsupported FakeRepo 不支持的条件或参数，例如SQL字符串、子查询
+var ErrFakeUnsupported = errors.New("db: not supported by fake repo")
+
+// FakeRepo 内存中的 Repo 实现，用于业务代码的单元测试，不需要数据库：
+//
+//	repo := gormx.NewFakeRepo[Order]()
+//	_ = repo.Insert(ctx, &Order{UserID: 1})
+//	orders, err := repo.SelectByMap(ctx, map[string]any{"user_id": 1})
+//
+// 整数主键为零值时自增，主键重复时返回 gorm.ErrDuplicatedKey；map条件的key兼容字段名和列名，
+// 值支持等值、切片（IN）、nil（IS NULL）以及 Like、Prefix、Contains、ArrayContains、Field 的比较等条件；写入和返回的都是记录的副本。
+// PageSelect 支持按列排序和分页；InTx 在fn返回错误时回滚到开始时的快照（写时复制，开销与记录数无关）。
+// 软删除与 BaseRepo 一致：查询过滤 deleted 为 Deleted 的记录，使用 gorm.DeletedAt 的模型删除时设置删除时间，其他模型直接删除
+type FakeRepo[T any] struct {
+	mu     sync.Mutex
+	schema *schema.Schema
+	err    error
+	rows   []*T
+	nextID int64
+}
+
+// NewFakeRepo T需要是struct，解析失败时所有方法返回错误
+func NewFakeRepo[T any]() *FakeRepo[T] {
+	r := &FakeRepo[T]{}
+	r.schema, r.err = schema.Parse(new(T), &sync.Map{}, schema.NamingStrategy{})
+	if r.err == nil && r.schema.PrioritizedPrimaryField == nil {
+		r.err = errors.Errorf("db: fake repo %s has no primary key", r.schema.Name)
+	}
+	return r
+}
+
+func (r *FakeRepo[T]) pkField() *schema.Field {
+	return r.schema.PrioritizedPrimaryField
+}
+
+func (r *FakeRepo[T]) copyRow(m *T) *T {
+	cp := *m
+	return &cp
+}
+
+// fakeValue 字段的Go值，不经过 serializer 等转换
+func fakeValue(ctx context.Context, f *schema.Field, rv reflect.Value) any {
+	return f.ReflectValueOf(ctx, rv).Interface()
+}
+
+// visible 记录是否未被软删除
+func (r *FakeRepo[T]) visible(ctx context.Context, row *T) bool {
+	f, gormDeletedAt := softDeleteField(r.schema)
+	if f == nil {
+		return true
+	}
+	v, _ := f.ValueOf(ctx, reflect.ValueOf(row).Elem())
+	if gormDeletedAt {
+		at, _ := v.(gorm.DeletedAt)
+		return !at.Valid
+	}
+	return !fakeEqual(v, Deleted)
+}
+
+// writable 更新、删除是否作用于该记录，与gorm一致只有 gorm.DeletedAt 的模型会排除已删除的记录
+func (r *FakeRepo[T]) writable(ctx context.Context, row *T) bool {
+	if _, gormDeletedAt := softDeleteField(r.schema); gormDeletedAt {
+		return r.visible(ctx, row)
+	}
+	return true
+}
+
+// match 记录是否满足条件，condition为 map[string]any、*T 或nil
+func (r *FakeRepo[T]) match(ctx context.Context, row *T, condition any) (bool, error) {
+	rv := reflect.ValueOf(row).Elem()
+	switch c := condition.(type) {
+	case nil:
+		return true, nil
+	case map[string]any:
+		for k, want := range c {
+			if cc, ok := want.(CompareCondition); ok {
+				// 比较条件的key包含操作符，列取自条件本身
+				k = cc.column
+			}
+			f := schemaColumn(r.schema, k)
+			if f == nil {
+				return false, errors.Wrapf(ErrUnknownColumn, "db: %s column: %s", r.schema.Name, k)
+			}
+			got := fakeValue(ctx, f, rv)
+			ok, err := fakeMatchValue(got, want)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case *T:
+		if c == nil {
+			return true, nil
+		}
+		cv := reflect.ValueOf(c).Elem()
+		for _, f := range r.schema.Fields {
+			if f.DBName == "" {
+				continue
+			}
+			if _, zero := f.ValueOf(ctx, cv); zero {
+				continue
+			}
+			if !fakeEqual(fakeValue(ctx, f, rv), fakeValue(ctx, f, cv)) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	return false, errors.Wrapf(ErrFakeUnsupported, "db: condition %T", condition)
+}
+
+// fakeMatchValue map条件的一个值：nil为 IS NULL，切片为 IN，LikeCondition、ArrayCondition、CompareCondition 按对应的操作符匹配，其余为等值
+func fakeMatchValue(got, want any) (bool, error) {
+	switch w := want.(type) {
+	case nil:
+		return fakeIsNull(got), nil
+	case []byte:
+		return fakeEqual(got, w), nil
+	case LikeCondition:
+		return fakeLike(got, w), nil
+	case ArrayCondition:
+		return fakeArray(got, w), nil
+	case CompareCondition:
+		return fakeCompareCondition(got, w), nil
+	case SubQuery, *gorm.DB, condExpr, columnCond:
+		return false, errors.Wrapf(ErrFakeUnsupported, "db: condition value %T", want)
+	}
+	if rv := reflect.ValueOf(want); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		for _, v := range Interface2Array(want) {
+			if fakeEqual(got, v) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return fakeEqual(got, want), nil
+}
+
+func fakeIsNull(v any) bool {
+	if valuer, ok := v.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		return err == nil && dv == nil
+	}
+	return !Indirect(reflect.ValueOf(v)).IsValid()
+}
+
+// fakeEqual 按数据库的语义比较两个值：不同的整数类型、字符串的自定义类型、time.Time 按值比较
+func fakeEqual(a, b any) bool {
+	av, bv := Indirect(reflect.ValueOf(a)), Indirect(reflect.ValueOf(b))
+	if !av.IsValid() || !bv.IsValid() {
+		return !av.IsValid() && !bv.IsValid()
+	}
+	if at, ok := av.Interface().(time.Time); ok {
+		bt, ok := bv.Interface().(time.Time)
+		return ok && at.Equal(bt)
+	}
+	switch {
+	case isIntKind(av.Kind()) && isIntKind(bv.Kind()):
+		return av.Int() == bv.Int()
+	case isUintKind(av.Kind()) && isUintKind(bv.Kind()):
+		return av.Uint() == bv.Uint()
+	case isIntKind(av.Kind()) && isUintKind(bv.Kind()):
+		return av.Int() >= 0 && uint64(av.Int()) == bv.Uint()
+	case isUintKind(av.Kind()) && isIntKind(bv.Kind()):
+		return bv.Int() >= 0 && av.Uint() == uint64(bv.Int())
+	case isNumberKind(av.Kind()) && isNumberKind(bv.Kind()):
+		return toFloat(av) == toFloat(bv)
+	case av.Kind() == reflect.String && bv.Kind() == reflect.String:
+		return av.String() == bv.String()
+	case av.Kind() == reflect.Bool && bv.Kind() == reflect.Bool:
+		return av.Bool() == bv.Bool()
+	}
+	return reflect.DeepEqual(av.Interface(), bv.Interface())
+}
+
+func isIntKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUintKind(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uintptr
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	return isIntKind(k) || isUintKind(k) || k == reflect.Float32 || k == reflect.Float64
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch {
+	case isIntKind(v.Kind()):
+		return float64(v.Int())
+	case isUintKind(v.Kind()):
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
+
+// find 满足条件且未删除的记录，返回的是存储中的记录本身
+func (r *FakeRepo[T]) find(ctx context.Context, condition any) ([]*T, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	var res []*T
+	for _, row := range r.rows {
+		if !r.visible(ctx, row) {
+			continue
+		}
+		ok, err := r.match(ctx, row, condition)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res = append(res, row)
+		}
+	}
+	return res, nil
+}
+
+func (r *FakeRepo[T]) copies(rows []*T) []*T {
+	res := make([]*T, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, r.copyRow(row))
+	}
+	return res
+}
+
+func (r *FakeRepo[T]) pkCond(pk any) map[string]any {
+	return map[string]any{r.pkField().DBName: pk}
+}
+
+func (r *FakeRepo[T]) insert(ctx context.Context, m *T) error {
+	row := r.copyRow(m)
+	rv := reflect.ValueOf(row).Elem()
+	pk := r.pkField()
+	if v, zero := pk.ValueOf(ctx, rv); zero {
+		if k := pk.IndirectFieldType.Kind(); isIntKind(k) || isUintKind(k) {
+			r.nextID++
+			if err := pk.Set(ctx, rv, r.nextID); err != nil {
+				return err
+			}
+			// 回填主键
+			if err := pk.Set(ctx, reflect.ValueOf(m).Elem(), r.nextID); err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, existing := range r.rows {
+			if got, _ := pk.ValueOf(ctx, reflect.ValueOf(existing).Elem()); fakeEqual(got, v) {
+				return errors.Wrapf(gorm.ErrDuplicatedKey, "db: insert %s error, duplicate pk: %v", r.schema.Name, v)
+			}
+		}
+		if n, ok := v.(int64); ok && n > r.nextID {
+			r.nextID = n
+		}
+	}
+	r.rows = append(r.rows, row)
+	return nil
+}
+
+// Insert 插入单条记录，整数主键为零值时自增并回填到m
+func (r *FakeRepo[T]) Insert(ctx context.Context, m *T) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	return r.insert(ctx, m)
+}
+
+// BatchInsert 批量插入，有一条失败时之前的记录已经插入
+func (r *FakeRepo[T]) BatchInsert(ctx context.Context, m []*T, _ int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return 0, r.err
+	}
+	for i, row := range m {
+		if err := r.insert(ctx, row); err != nil {
+			return int64(i), err
+		}
+	}
+	return int64(len(m)), nil
+}
+
+func (r *FakeRepo[T]) delete(ctx context.Context, condition map[string]any) (int64, error) {
+	f, gormDeletedAt := softDeleteField(r.schema)
+	if gormDeletedAt {
+		return r.modify(ctx, condition, func(row *T) error {
+			return f.Set(ctx, reflect.ValueOf(row).Elem(), gorm.DeletedAt{Time: time.Now(), Valid: true})
+		})
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	kept := make([]*T, 0, len(r.rows))
+	for _, row := range r.rows {
+		ok, err := r.match(ctx, row, condition)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			kept = append(kept, row)
+		}
+	}
+	n := int64(len(r.rows) - len(kept))
+	r.rows = kept
+	return n, nil
+}
+
+// DeleteByPK 根据主键删除，支持单个主键或者一个主键数组
+func (r *FakeRepo[T]) DeleteByPK(ctx context.Context, pks any) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.delete(ctx, r.pkCond(pks))
+}
+
+// DeleteByMap 根据条件删除
+func (r *FakeRepo[T]) DeleteByMap(ctx context.Context, condition map[string]any) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.delete(ctx, condition)
+}
+
+// set 按updateData修改记录
+func (r *FakeRepo[T]) set(ctx context.Context, row *T, updateData map[string]any) error {
+	rv := reflect.ValueOf(row).Elem()
+	for k, v := range updateData {
+		f := schemaColumn(r.schema, k)
+		if f == nil {
+			return errors.Wrapf(ErrUnknownColumn, "db: %s column: %s", r.schema.Name, k)
+		}
+		switch v.(type) {
+		case UpdateExpr, clause.Expression:
+			return errors.Wrapf(ErrFakeUnsupported, "db: update value %T", v)
+		}
+		if err := f.Set(ctx, rv, v); err != nil {
+			return errors.Wrapf(err, "db: set %s.%s error", r.schema.Name, f.Name)
+		}
+	}
+	return nil
+}
+
+// modify 对满足条件的记录执行fn，写时复制：fn修改的是记录的副本，成功后替换存储中的记录，
+// 已经返回给调用方的记录和事务的快照不受影响
+func (r *FakeRepo[T]) modify(ctx context.Context, condition map[string]any, fn func(row *T) error) (int64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	var n int64
+	for i, row := range r.rows {
+		if !r.writable(ctx, row) {
+			continue
+		}
+		ok, err := r.match(ctx, row, condition)
+		if err != nil {
+			return n, err
+		}
+		if !ok {
+			continue
+		}
+		cp := r.copyRow(row)
+		if err = fn(cp); err != nil {
+			return n, err
+		}
+		r.rows[i] = cp
+		n++
+	}
+	return n, nil
+}
+
+func (r *FakeRepo[T]) update(ctx context.Context, condition map[string]any, updateData map[string]any) (int64, error) {
+	return r.modify(ctx, condition, func(row *T) error {
+		return r.set(ctx, row, updateData)
+	})
+}
+
+// UpdateByPK 根据主键更新非零值的字段
+func (r *FakeRepo[T]) UpdateByPK(ctx context.Context, t *T) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return 0, r.err
+	}
+	rv := reflect.ValueOf(t).Elem()
+	pk, zero := r.pkField().ValueOf(ctx, rv)
+	if zero {
+		return 0, errors.Wrapf(gorm.ErrMissingWhereClause, "db: update %s by pk error, pk is empty", r.schema.Name)
+	}
+	data := make(map[string]any)
+	for _, f := range r.schema.Fields {
+		if f.DBName == "" || f.PrimaryKey {
+			continue
+		}
+		if _, zero := f.ValueOf(ctx, rv); !zero {
+			data[f.DBName] = fakeValue(ctx, f, rv)
+		}
+	}
+	return r.update(ctx, r.pkCond(pk), data)
+}
+
+// UpdateByPKWithMap 根据主键更新，支持零值
+func (r *FakeRepo[T]) UpdateByPKWithMap(ctx context.Context, pk any, updateData map[string]any) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.update(ctx, r.pkCond(pk), updateData)
+}
+
+// UpdateByMap 根据条件更新，支持零值，更新值不支持 Expr 表达式
+func (r *FakeRepo[T]) UpdateByMap(ctx context.Context, condition map[string]any, updateData map[string]any) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.update(ctx, condition, updateData)
+}
+
+// IncrementByPK 把主键为pk的记录的column加上delta
+func (r *FakeRepo[T]) IncrementByPK(ctx context.Context, pk any, column string, delta int64) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return 0, r.err
+	}
+	f := schemaColumn(r.schema, column)
+	if f == nil {
+		return 0, errors.Wrapf(ErrUnknownColumn, "db: %s column: %s", r.schema.Name, column)
+	}
+	return r.modify(ctx, r.pkCond(pk), func(row *T) error {
+		rv := reflect.ValueOf(row).Elem()
+		cur := Indirect(reflect.ValueOf(fakeValue(ctx, f, rv)))
+		var next any
+		switch {
+		case !cur.IsValid():
+			next = delta
+		case isIntKind(cur.Kind()):
+			next = cur.Int() + delta
+		case isUintKind(cur.Kind()):
+			next = int64(cur.Uint()) + delta
+		case cur.Kind() == reflect.Float32 || cur.Kind() == reflect.Float64:
+			next = cur.Float() + float64(delta)
+		default:
+			return errors.Errorf("db: increment %s.%s error, not a number", r.schema.Name, f.Name)
+		}
+		return f.Set(ctx, rv, next)
+	})
+}
+
+func (r *FakeRepo[T]) selectOne(ctx context.Context, condition any) (*T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rows, err := r.find(ctx, condition)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	if len(rows) > 1 {
+		return nil, errors.Errorf("db: select one %s error, result must be one, now it is %d, condition %+v", r.schema.Name, len(rows), condition)
+	}
+	return r.copyRow(rows[0]), nil
+}
+
+func (r *FakeRepo[T]) selectAll(ctx context.Context, condition any) ([]*T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rows, err := r.find(ctx, condition)
+	if err != nil {
+		return nil, err
+	}
+	return r.copies(rows), nil
+}
+
+// SelectOne 根据非零值的字段查找
+func (r *FakeRepo[T]) SelectOne(ctx context.Context, condition *T) (*T, error) {
+	return r.selectOne(ctx, condition)
+}
+
+// SelectOneByPK 根据主键查找
+func (r *FakeRepo[T]) SelectOneByPK(ctx context.Context, pk any) (*T, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.selectOne(ctx, r.pkCond(pk))
+}
+
+// SelectOneByMap 根据条件查找，支持零值
+func (r *FakeRepo[T]) SelectOneByMap(ctx context.Context, condition map[string]any) (*T, error) {
+	return r.selectOne(ctx, condition)
+}
+
+// Select 根据非零值的字段查询
+func (r *FakeRepo[T]) Select(ctx context.Context, condition *T) ([]*T, error) {
+	return r.selectAll(ctx, condition)
+}
+
+// SelectAll 查询所有
+func (r *FakeRepo[T]) SelectAll(ctx context.Context) ([]*T, error) {
+	return r.selectAll(ctx, nil)
+}
+
+// SelectByPK 根据主键查找，支持单个主键或者一个主键数组
+func (r *FakeRepo[T]) SelectByPK(ctx context.Context, pks any) ([]*T, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.selectAll(ctx, r.pkCond(pks))
+}
+
+// SelectByMap 根据条件查找，支持零值
+func (r *FakeRepo[T]) SelectByMap(ctx context.Context, condition map[string]any) ([]*T, error) {
+	return r.selectAll(ctx, condition)
+}
+
+// PageSelect 分页查询，query只支持 map[string]any、*T 和nil，不支持SQL字符串；OrderBy 只支持 "列 [ASC|DESC], ..." 的形式
+func (r *FakeRepo[T]) PageSelect(ctx context.Context, page *PageParam, query any, args ...any) ([]*T, int32, error) {
+	if len(args) > 0 {
+		return nil, 0, errors.Wrapf(ErrFakeUnsupported, "db: page select with args")
+	}
+	rows, err := r.selectAll(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := int32(len(rows))
+	if page != nil {
+		if err = r.sortRows(ctx, rows, page.OrderBy); err != nil {
+			return nil, 0, err
+		}
+		start := min(int(page.PageNo-1)*int(page.PageSize), len(rows))
+		end := min(start+int(page.PageSize), len(rows))
+		rows = rows[max(start, 0):end]
+	}
+	return rows, total, nil
+}
+
+// InTx 执行fn，fn返回错误或panic时回滚到执行前的状态，支持嵌套调用（相当于 SAVEPOINT）
+// 写入都是写时复制，快照只需要复制记录指针；没有隔离，事务期间其他goroutine的写入在回滚时同样会被撤销
+func (r *FakeRepo[T]) InTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	r.mu.Lock()
+	rows, nextID := slices.Clone(r.rows), r.nextID
+	r.mu.Unlock()
+	rollback := func() {
+		r.mu.Lock()
+		r.rows, r.nextID = rows, nextID
+		r.mu.Unlock()
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+	if err = fn(ctx); err != nil {
+		rollback()
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package gormx
+
+import (
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// Field 类型化的列引用，V为列的Go类型，值为列名，通常由 gen 生成：
+//
+//	var UserFields = struct {
+//		Name gormx.Field[string]
+//		Age  gormx.Field[int32]
+//	}{Name: "name", Age: "age"}
+//
+//	users, err := repo.SelectByMap(ctx, gormx.Conds(UserFields.Age.Gte(18), UserFields.Name.Like(gormx.Prefix("zhang"))))
+//	page := &gormx.PageParam{PageNo: 1, PageSize: 20, OrderBy: gormx.OrderBy(UserFields.Age.Desc())}
+//
+// 字段改名或改类型后重新生成，引用它的代码在编译时报错，而不是运行时生成错误的SQL
+type Field[V any] string
+
+// Column 列名
+func (f Field[V]) Column() string {
+	return string(f)
+}
+
+// Eq column = v
+func (f Field[V]) Eq(v V) Cond {
+	return Cond{Key: string(f), Value: v}
+}
+
+// In column IN (values)
+func (f Field[V]) In(values ...V) Cond {
+	return Cond{Key: string(f), Value: values}
+}
+
+// IsNull column IS NULL
+func (f Field[V]) IsNull() Cond {
+	return Cond{Key: string(f), Value: nil}
+}
+
+// Neq column <> v
+func (f Field[V]) Neq(v V) Cond {
+	return f.compare("<>", v)
+}
+
+// Gt column > v
+func (f Field[V]) Gt(v V) Cond {
+	return f.compare(">", v)
+}
+
+// Gte column >= v
+func (f Field[V]) Gte(v V) Cond {
+	return f.compare(">=", v)
+}
+
+// Lt column < v
+func (f Field[V]) Lt(v V) Cond {
+	return f.compare("<", v)
+}
+
+// Lte column <= v
+func (f Field[V]) Lte(v V) Cond {
+	return f.compare("<=", v)
+}
+
+// Like LIKE 条件，通过 Like、Prefix、Suffix、Contains 构造
+func (f Field[V]) Like(c LikeCondition) Cond {
+	return Cond{Key: string(f), Value: c}
+}
+
+func (f Field[V]) compare(op string, v V) Cond {
+	return compareCond(string(f), op, v)
+}
+
+// compareCond key包含操作符，同一列的多个比较条件（例如范围）不会互相覆盖
+func compareCond(column, op string, v any) Cond {
+	return Cond{Key: column + " " + op, Value: CompareCondition{column: column, op: op, value: v}}
+}
+
+// Asc 升序，用于 OrderBy
+func (f Field[V]) Asc() Order {
+	return Order{column: string(f)}
+}
+
+// Desc 降序，用于 OrderBy
+func (f Field[V]) Desc() Order {
+	return Order{column: string(f), desc: true}
+}
+
+// Cond 一个map条件，通过 Conds 合并为 SelectByMap 等方法的condition
+type Cond struct {
+	Key   string
+	Value any
+}
+
+// Conds 合并为map条件，各条件之间为 AND
+func Conds(conds ...Cond) map[string]any {
+	m := make(map[string]any, len(conds))
+	for _, c := range conds {
+		m[c.Key] = c.Value
+	}
+	return m
+}
+
+// Columns 列名，用于 SelectDistinct、GroupBy、BatchUpdateByPK 等需要列名的方法
+func Columns(fields ...interface{ Column() string }) []string {
+	columns := make([]string, 0, len(fields))
+	for _, f := range fields {
+		columns = append(columns, f.Column())
+	}
+	return columns
+}
+
+// Order 排序，通过 Field.Asc、Field.Desc 构造
+type Order struct {
+	column string
+	desc   bool
+}
+
+// OrderBy 生成 PageParam.OrderBy
+func OrderBy(orders ...Order) string {
+	items := make([]string, 0, len(orders))
+	for _, o := range orders {
+		if o.desc {
+			items = append(items, o.column+" DESC")
+		} else {
+			items = append(items, o.column)
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+// CompareCondition 比较条件 column op value，通过 Field 的 Gt、Lt 等方法构造，作为map条件的值使用
+type CompareCondition struct {
+	column string
+	op     string
+	value  any
+}
+
+func (CompareCondition) condExpr() {}
+
+// Build 实现 clause.Expression
+func (c CompareCondition) Build(builder clause.Builder) {
+	builder.WriteQuoted(clause.Column{Name: c.column})
+	builder.WriteString(" " + c.op + " ")
+	builder.AddVar(builder, c.value)
+}
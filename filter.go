@@ -0,0 +1,157 @@
+package gormx
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// filterTag ConditionFromStruct 使用的tag
+const filterTag = "filter"
+
+// ConditionFromStruct 根据请求结构体中带 filter tag 的字段生成map条件，没有tag的字段忽略：
+//
+//	type ListUserReq struct {
+//		Name     string   `filter:"name,op=like"`      // name LIKE '%v%'
+//		Status   []int8   `filter:"status"`            // 切片为 IN
+//		MinAge   int      `filter:"age,op=gte"`        // age >= v
+//		MaxAge   int      `filter:"age,op=lte"`        // 同一列的多个比较条件不会互相覆盖
+//		Deleted  *bool    `filter:"is_deleted"`        // 指针不为nil时即使是零值也作为条件
+//		VIP      bool     `filter:"vip,keepzero"`      // keepzero 零值也作为条件
+//		PageNo   int32 // 没有tag，忽略
+//	}
+//
+//	condition, err := gormx.ConditionFromStruct(&req)
+//	users, err := repo.SelectByMap(ctx, condition)
+//
+// 列名为空时为字段名的蛇形；零值、nil指针、空切片默认跳过；嵌入的匿名结构体会展开。
+// op 支持 eq（默认）、ne、gt、gte、lt、lte、in、like（包含）、ilike（包含，忽略大小写）、prefix、suffix
+func ConditionFromStruct(req any) (map[string]any, error) {
+	rv := Indirect(reflect.ValueOf(req))
+	if !rv.IsValid() {
+		return map[string]any{}, nil
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, errors.Errorf("db: condition from struct error, %T is not a struct", req)
+	}
+	condition := make(map[string]any)
+	if err := appendFilterConds(condition, rv); err != nil {
+		return nil, err
+	}
+	return condition, nil
+}
+
+func appendFilterConds(condition map[string]any, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf, fv := rt.Field(i), rv.Field(i)
+		tag, tagged := sf.Tag.Lookup(filterTag)
+		if tag == "-" || !sf.IsExported() {
+			continue
+		}
+		if !tagged {
+			if inner := Indirect(fv); sf.Anonymous && inner.Kind() == reflect.Struct {
+				if err := appendFilterConds(condition, inner); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		column, op, keepZero := parseFilterTag(tag)
+		if column == "" {
+			column = Camel2Snake(sf.Name)
+		}
+		value, ok := filterValue(fv, keepZero)
+		if !ok {
+			continue
+		}
+		c, err := filterCond(column, op, value)
+		if err != nil {
+			return errors.WithMessagef(err, "db: condition from struct error, field %s", sf.Name)
+		}
+		condition[c.Key] = c.Value
+	}
+	return nil
+}
+
+func parseFilterTag(tag string) (column, op string, keepZero bool) {
+	parts := strings.Split(tag, ",")
+	column, op = strings.TrimSpace(parts[0]), "eq"
+	for _, p := range parts[1:] {
+		p = strings.TrimSpace(p)
+		switch {
+		case strings.HasPrefix(p, "op="):
+			op = strings.ToLower(strings.TrimPrefix(p, "op="))
+		case p == "keepzero":
+			keepZero = true
+		}
+	}
+	return column, op, keepZero
+}
+
+// filterValue 字段的值，零值（keepZero时除外）、nil指针、空切片返回false
+func filterValue(fv reflect.Value, keepZero bool) (any, bool) {
+	switch fv.Kind() {
+	case reflect.Ptr:
+		if fv.IsNil() {
+			return nil, false
+		}
+		// 指针表示调用方明确传了值，零值也作为条件
+		return fv.Elem().Interface(), true
+	case reflect.Slice, reflect.Array:
+		if fv.Len() == 0 {
+			return nil, false
+		}
+		return fv.Interface(), true
+	}
+	if fv.IsZero() && !keepZero {
+		return nil, false
+	}
+	return fv.Interface(), true
+}
+
+func filterCond(column, op string, value any) (Cond, error) {
+	isSlice := false
+	if k := reflect.ValueOf(value).Kind(); k == reflect.Slice || k == reflect.Array {
+		_, isBytes := value.([]byte)
+		isSlice = !isBytes
+	}
+	if isSlice && op != "eq" && op != "in" {
+		return Cond{}, errors.Errorf("op %s does not support slice", op)
+	}
+	switch op {
+	case "eq", "in":
+		return Cond{Key: column, Value: value}, nil
+	case "ne":
+		return compareCond(column, "<>", value), nil
+	case "gt":
+		return compareCond(column, ">", value), nil
+	case "gte":
+		return compareCond(column, ">=", value), nil
+	case "lt":
+		return compareCond(column, "<", value), nil
+	case "lte":
+		return compareCond(column, "<=", value), nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		if v := reflect.ValueOf(value); v.Kind() == reflect.String {
+			s, ok = v.String(), true
+		}
+	}
+	if !ok {
+		return Cond{}, errors.Errorf("op %s requires a string, got %T", op, value)
+	}
+	switch op {
+	case "like":
+		return Cond{Key: column, Value: Contains(s)}, nil
+	case "ilike":
+		return Cond{Key: column, Value: Contains(s).IgnoreCase()}, nil
+	case "prefix":
+		return Cond{Key: column, Value: Prefix(s)}, nil
+	case "suffix":
+		return Cond{Key: column, Value: Suffix(s)}, nil
+	}
+	return Cond{}, errors.Errorf("unknown op %s", op)
+}
@@ -0,0 +1,120 @@
+package gormx
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// IsDuplicateKey 判断是否为唯一键冲突，支持 MySQL 1062、Postgres 23505 以及开启 TranslateError 时的 gorm.ErrDuplicatedKey
+func IsDuplicateKey(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	msg := err.Error()
+	if strings.Contains(msg, "Error 1062") || strings.Contains(msg, "SQLSTATE 23505") {
+		return true
+	}
+	return matchDBError(err, map[string]struct{}{"23505": {}}, map[uint64]struct{}{1062: {}})
+}
+
+// FirstOrInit 查找满足条件的记录，不存在时返回以defaults为基础、并填充了条件字段的新对象（不写入数据库）
+// condition里的key兼容驼峰和蛇形，defaults可以为nil
+func (b *BaseRepo[T]) FirstOrInit(ctx context.Context, condition map[string]any, defaults *T) (*T, error) {
+	res, err := b.SelectOneByMap(WithConsistency(ctx, Strong), condition)
+	if err != nil || res != nil {
+		return res, err
+	}
+	return b.initWith(ctx, condition, defaults)
+}
+
+// FirstOrCreate 查找满足条件的记录，不存在时按 FirstOrInit 的规则创建，created表示是否新建
+//
+// 并发创建依赖条件列上的唯一索引：插入发生唯一键冲突时重新查询并返回已存在的记录；
+// 没有唯一索引时并发调用可能创建多条记录，需要使用 FirstOrCreateForUpdate
+func (b *BaseRepo[T]) FirstOrCreate(ctx context.Context, condition map[string]any, defaults *T) (*T, bool, error) {
+	ctx = WithConsistency(ctx, Strong)
+	res, err := b.SelectOneByMap(ctx, condition)
+	if err != nil || res != nil {
+		return res, false, err
+	}
+	return b.createOrReselect(ctx, condition, defaults, b.SelectOneByMap)
+}
+
+// FirstOrCreateForUpdate 与 FirstOrCreate 相同，但通过 SELECT ... FOR UPDATE 查找并锁定记录，只能在 InTx 内调用
+// mysql 在记录不存在时会锁定间隙，阻止其他事务插入相同条件的记录
+func (b *BaseRepo[T]) FirstOrCreateForUpdate(ctx context.Context, condition map[string]any, defaults *T) (*T, bool, error) {
+	selectForUpdate := func(ctx context.Context, condition map[string]any) (*T, error) {
+		return b.SelectOneByMapForUpdate(ctx, condition)
+	}
+	res, err := selectForUpdate(ctx, condition)
+	if err != nil || res != nil {
+		return res, false, err
+	}
+	return b.createOrReselect(ctx, condition, defaults, selectForUpdate)
+}
+
+// createOrReselect 插入新记录，唯一键冲突时通过reselect返回已存在的记录
+// 在事务内时插入在SAVEPOINT中执行，冲突后postgres事务仍然可用
+func (b *BaseRepo[T]) createOrReselect(ctx context.Context, condition map[string]any, defaults *T,
+	reselect func(context.Context, map[string]any) (*T, error)) (*T, bool, error) {
+	m, err := b.initWith(ctx, condition, defaults)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, inTx := ctx.Value(contextTxKey{}).(*gorm.DB); inTx {
+		err = b.InTx(ctx, func(ctx context.Context) error {
+			return b.Insert(ctx, m)
+		})
+	} else {
+		err = b.Insert(ctx, m)
+	}
+	if err == nil {
+		return m, true, nil
+	}
+	if !IsDuplicateKey(err) {
+		return nil, false, err
+	}
+	res, err := reselect(ctx, condition)
+	if err != nil {
+		return nil, false, err
+	}
+	if res == nil {
+		return nil, false, errors.Errorf("db: first or create %s error, duplicate key but record not found, condition: %+v", b.StructName, condition)
+	}
+	return res, false, nil
+}
+
+// initWith 复制defaults并把condition中的值写入对应字段
+func (b *BaseRepo[T]) initWith(ctx context.Context, condition map[string]any, defaults *T) (*T, error) {
+	m := new(T)
+	if defaults != nil {
+		*m = *defaults
+	}
+	s, err := b.gormSchema()
+	if err != nil {
+		return nil, err
+	}
+	rv := indirectModel(reflect.ValueOf(m))
+	for k, v := range b.columnKeys(condition) {
+		// 子查询和表达式条件无法确定字段值
+		switch v.(type) {
+		case SubQuery, condExpr, columnCond:
+			continue
+		}
+		field := s.LookUpField(k)
+		if field == nil {
+			return nil, errors.Wrapf(ErrUnknownColumn, "db: init %s error, column: %s", b.StructName, k)
+		}
+		if err = field.Set(ctx, rv, v); err != nil {
+			return nil, errors.Wrapf(err, "db: init %s error, column: %s, value: %v", b.StructName, k, v)
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,226 @@
+package gormx
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// FixtureUnmarshalers 按扩展名解析fixture文件，默认只支持 .json，YAML 需要注册解析函数，例如：
+//
+//	gormx.FixtureUnmarshalers[".yaml"] = yaml.Unmarshal
+var FixtureUnmarshalers = map[string]func(data []byte, v any) error{
+	".json": json.Unmarshal,
+}
+
+// fixtureRefKey 记录的引用名
+const fixtureRefKey = "_ref"
+
+// Fixtures LoadFixtures 插入的记录，key为引用名，value为指向模型的指针，主键等数据库生成的值已经回填
+type Fixtures map[string]any
+
+// FixtureOf 引用名为ref的记录，不存在或类型不是T时返回nil
+func FixtureOf[T any](f Fixtures, ref string) *T {
+	m, _ := f[ref].(*T)
+	return m
+}
+
+type fixtureRow struct {
+	file   string
+	schema *schema.Schema
+	ref    string
+	values map[string]any
+	// 插入后的记录
+	model any
+}
+
+// LoadFixtures 读取path（文件或目录，目录下按文件名顺序读取所有已注册扩展名的文件）中的数据并在一个事务内插入，
+// 文件的格式为 表名 -> 记录数组，models 为这些表对应的模型：
+//
+//	{
+//	  "users":  [{"_ref": "alice", "name": "Alice"}],
+//	  "orders": [{"user_id": "$alice", "amount": 10}, {"user_id": "$alice.id", "remark": "$$literal"}]
+//	}
+//
+// 记录的key兼容字段名和列名；_ref 为引用名，其他记录用 "$引用名" 引用它的主键、"$引用名.列" 引用它的列，
+// 可以跨文件引用，被引用的记录先插入，"$$" 开头的字符串表示以 "$" 开头的字面值。
+// ctx 在 InTx 内时作为SAVEPOINT执行，外层事务回滚时fixture也一起回滚，测试结束后不留下数据
+func LoadFixtures(ctx context.Context, db *gorm.DB, path string, models ...any) (Fixtures, error) {
+	tables := make(map[string]*schema.Schema, len(models))
+	for _, m := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(m); err != nil {
+			return nil, errors.Wrapf(err, "db: parse fixture model %T error", m)
+		}
+		tables[stmt.Schema.Table] = stmt.Schema
+	}
+	files, err := fixtureFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	var rows []*fixtureRow
+	refs := make(map[string]*fixtureRow)
+	for _, file := range files {
+		fileRows, err := readFixtureFile(file, tables)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range fileRows {
+			if row.ref == "" {
+				continue
+			}
+			if _, dup := refs[row.ref]; dup {
+				return nil, errors.Errorf("db: load fixtures error, duplicate ref %s in %s", row.ref, row.file)
+			}
+			refs[row.ref] = row
+		}
+		rows = append(rows, fileRows...)
+	}
+
+	fixtures := make(Fixtures, len(refs))
+	err = dbWithCtx(ctx, db).Transaction(func(tx *gorm.DB) error {
+		// 每一轮插入引用都已就绪的记录，直到全部插入；一轮没有进展说明存在循环引用
+		for len(rows) > 0 {
+			var pending []*fixtureRow
+			for _, row := range rows {
+				values, ready, err := resolveFixtureRefs(ctx, row, refs)
+				if err != nil {
+					return err
+				}
+				if !ready {
+					pending = append(pending, row)
+					continue
+				}
+				m, err := insertFixture(ctx, tx, row, values)
+				if err != nil {
+					return err
+				}
+				row.model = m
+				if row.ref != "" {
+					fixtures[row.ref] = m
+				}
+			}
+			if len(pending) == len(rows) {
+				return errors.Errorf("db: load fixtures error, circular refs, first pending row in %s: %+v", pending[0].file, pending[0].values)
+			}
+			rows = pending
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fixtures, nil
+}
+
+func fixtureFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: load fixtures error, path: %s", path)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: load fixtures error, path: %s", path)
+	}
+	var files []string
+	for _, e := range entries {
+		if _, ok := FixtureUnmarshalers[strings.ToLower(filepath.Ext(e.Name()))]; ok && !e.IsDir() {
+			files = append(files, filepath.Join(path, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+func readFixtureFile(file string, tables map[string]*schema.Schema) ([]*fixtureRow, error) {
+	unmarshal, ok := FixtureUnmarshalers[strings.ToLower(filepath.Ext(file))]
+	if !ok {
+		return nil, errors.Errorf("db: load fixtures error, no unmarshaler for %s", file)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: load fixtures error, file: %s", file)
+	}
+	content := make(map[string][]map[string]any)
+	if err = unmarshal(data, &content); err != nil {
+		return nil, errors.Wrapf(err, "db: load fixtures error, file: %s", file)
+	}
+	var rows []*fixtureRow
+	// 同一文件内按表名顺序插入，保证结果稳定
+	for _, table := range SortedKeys(content) {
+		s, ok := tables[table]
+		if !ok {
+			return nil, errors.Errorf("db: load fixtures error, table %s in %s has no model", table, file)
+		}
+		for _, values := range content[table] {
+			row := &fixtureRow{file: file, schema: s, values: values}
+			if ref, ok := values[fixtureRefKey]; ok {
+				row.ref, _ = ref.(string)
+				delete(values, fixtureRefKey)
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// resolveFixtureRefs 把 "$ref"、"$ref.column" 替换为已插入记录的值，引用的记录还未插入时ready为false
+func resolveFixtureRefs(ctx context.Context, row *fixtureRow, refs map[string]*fixtureRow) (map[string]any, bool, error) {
+	values := make(map[string]any, len(row.values))
+	for _, k := range SortedKeys(row.values) {
+		v := row.values[k]
+		str, ok := v.(string)
+		if !ok || !strings.HasPrefix(str, "$") {
+			values[k] = v
+			continue
+		}
+		if strings.HasPrefix(str, "$$") {
+			values[k] = str[1:]
+			continue
+		}
+		ref, column, _ := strings.Cut(str[1:], ".")
+		target, ok := refs[ref]
+		if !ok {
+			return nil, false, errors.Errorf("db: load fixtures error, unknown ref %s in %s", ref, row.file)
+		}
+		if target.model == nil {
+			return nil, false, nil
+		}
+		f := target.schema.PrioritizedPrimaryField
+		if column != "" {
+			f = schemaColumn(target.schema, column)
+		}
+		if f == nil {
+			return nil, false, errors.Wrapf(ErrUnknownColumn, "db: load fixtures error, ref %s in %s", str, row.file)
+		}
+		values[k] = f.ReflectValueOf(ctx, reflect.ValueOf(target.model).Elem()).Interface()
+	}
+	return values, true, nil
+}
+
+func insertFixture(ctx context.Context, tx *gorm.DB, row *fixtureRow, values map[string]any) (any, error) {
+	rv := reflect.New(row.schema.ModelType)
+	for k, v := range values {
+		f := schemaColumn(row.schema, k)
+		if f == nil {
+			return nil, errors.Wrapf(ErrUnknownColumn, "db: load fixtures error, %s column %s in %s", row.schema.Table, k, row.file)
+		}
+		if err := f.Set(ctx, rv.Elem(), v); err != nil {
+			return nil, errors.Wrapf(err, "db: load fixtures error, %s column %s in %s", row.schema.Table, k, row.file)
+		}
+	}
+	m := rv.Interface()
+	if err := tx.Create(m).Error; err != nil {
+		return nil, errors.Wrapf(err, "db: load fixtures error, insert %s in %s", row.schema.Table, row.file)
+	}
+	return m, nil
+}
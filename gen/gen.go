@@ -0,0 +1,352 @@
+// Package gen 根据数据库的表结构生成模型、列名常量和 BaseRepo 构造函数
+//
+// gormx 不依赖任何数据库驱动，命令行工具需要在自己的项目中用对应的驱动创建：
+//
+//	// cmd/gormx/main.go
+//	func main() {
+//		gen.Main(mysql.Open)
+//	}
+//
+//	go run ./cmd/gormx gen -dsn "user:pass@tcp(127.0.0.1:3306)/db?parseTime=true" -pkg model -out ./model
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/jinzhu/inflection"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Options 生成选项
+type Options struct {
+	// 生成的代码的包名，为空时为 model
+	Package string
+	// 需要生成的表，为空时为数据库中的所有表
+	Tables []string
+}
+
+// Field 模型的一个字段
+type Field struct {
+	Name    string
+	Type    string
+	Column  string
+	Tag     string
+	Comment string
+}
+
+// Model 一张表对应的模型
+type Model struct {
+	Name  string
+	Table string
+	// 表中有 create_at、update_at、deleted 三列时嵌入 gormx.ModelBaseInfo，Fields 中不再包括这三列
+	BaseInfo bool
+	Fields   []Field
+}
+
+// baseInfoColumns gormx.ModelBaseInfo 的列
+var baseInfoColumns = []string{"create_at", "update_at", "deleted"}
+
+// Inspect 读取表结构
+func Inspect(db *gorm.DB, opts Options) ([]Model, error) {
+	migrator := db.Migrator()
+	tables := opts.Tables
+	if len(tables) == 0 {
+		var err error
+		if tables, err = migrator.GetTables(); err != nil {
+			return nil, errors.Wrap(err, "gen: get tables error")
+		}
+		sort.Strings(tables)
+	}
+	models := make([]Model, 0, len(tables))
+	for _, table := range tables {
+		columnTypes, err := migrator.ColumnTypes(table)
+		if err != nil {
+			return nil, errors.Wrapf(err, "gen: get columns of %s error", table)
+		}
+		models = append(models, newModel(table, columnTypes))
+	}
+	return models, nil
+}
+
+func newModel(table string, columnTypes []gorm.ColumnType) Model {
+	m := Model{Name: GoName(inflection.Singular(table)), Table: table}
+	columns := make(map[string]struct{}, len(columnTypes))
+	for _, ct := range columnTypes {
+		columns[strings.ToLower(ct.Name())] = struct{}{}
+	}
+	m.BaseInfo = true
+	for _, c := range baseInfoColumns {
+		if _, ok := columns[c]; !ok {
+			m.BaseInfo = false
+		}
+	}
+	// 生成的代码中已经占用的名称：TableName 方法，以及嵌入的 ModelBaseInfo 和它的字段
+	used := map[string]struct{}{"TableName": {}}
+	if m.BaseInfo {
+		for _, name := range []string{"ModelBaseInfo", "CreateAt", "UpdateAt", "Deleted"} {
+			used[name] = struct{}{}
+		}
+	}
+	for _, ct := range columnTypes {
+		column := ct.Name()
+		if m.BaseInfo && isBaseInfoColumn(column) {
+			continue
+		}
+		tag := "column:" + column
+		pk, _ := ct.PrimaryKey()
+		if pk {
+			tag += ";primaryKey"
+		}
+		if autoIncrement, ok := ct.AutoIncrement(); ok && pk && !autoIncrement {
+			tag += ";autoIncrement:false"
+		}
+		comment, _ := ct.Comment()
+		m.Fields = append(m.Fields, Field{
+			Name:    uniqueName(GoName(column), used),
+			Type:    goType(ct, pk),
+			Column:  column,
+			Tag:     fmt.Sprintf("`gorm:\"%s\" json:\"%s\"`", tag, column),
+			Comment: strings.ReplaceAll(comment, "\n", " "),
+		})
+	}
+	return m
+}
+
+// uniqueName 与已占用的名称重名时加上后缀 Col，例如 table_name 列生成 TableNameCol
+func uniqueName(name string, used map[string]struct{}) string {
+	res := name
+	for i := 1; ; i++ {
+		if _, ok := used[res]; !ok {
+			used[res] = struct{}{}
+			return res
+		}
+		if i == 1 {
+			res = name + "Col"
+		} else {
+			res = fmt.Sprintf("%sCol%d", name, i)
+		}
+	}
+}
+
+func isBaseInfoColumn(column string) bool {
+	for _, c := range baseInfoColumns {
+		if strings.EqualFold(c, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// goType 按数据库类型名映射Go类型，允许NULL的列（主键除外）使用指针
+func goType(ct gorm.ColumnType, pk bool) string {
+	name := strings.ToLower(ct.DatabaseTypeName())
+	unsigned := strings.Contains(name, "unsigned")
+	name = strings.TrimSpace(strings.ReplaceAll(name, "unsigned", ""))
+	var t string
+	switch {
+	case name == "tinyint" && isTinyBool(ct):
+		t = "bool"
+	case name == "tinyint":
+		t = "int8"
+	case name == "smallint" || name == "int2" || name == "smallserial":
+		t = "int16"
+	case name == "bigint" || name == "int8" || name == "bigserial":
+		t = "int64"
+	case name == "int" || name == "integer" || name == "mediumint" || name == "int4" || name == "serial":
+		t = "int32"
+	case name == "float" || name == "real" || name == "float4":
+		t = "float32"
+	case name == "double" || name == "double precision" || name == "float8":
+		t = "float64"
+	case name == "bool" || name == "boolean" || name == "bit":
+		t = "bool"
+	case name == "date" || strings.Contains(name, "datetime") || strings.HasPrefix(name, "timestamp"):
+		// postgres 为 timestamp、timestamptz、timestamp with time zone
+		t = "time.Time"
+	case strings.HasSuffix(name, "blob") || name == "binary" || name == "varbinary" || name == "bytea":
+		return "[]byte"
+	default:
+		// 字符串、decimal（避免精度损失）、json、time、interval 等
+		t = "string"
+	}
+	if unsigned && strings.HasPrefix(t, "int") {
+		t = "u" + t
+	}
+	if nullable, ok := ct.Nullable(); ok && nullable && !pk {
+		t = "*" + t
+	}
+	return t
+}
+
+// isTinyBool mysql 的 tinyint(1) 通常表示bool
+func isTinyBool(ct gorm.ColumnType) bool {
+	columnType, _ := ct.ColumnType()
+	return strings.HasPrefix(strings.ToLower(columnType), "tinyint(1)")
+}
+
+// commonInitialisms 转换为Go名称时整体大写的缩写，与gorm的命名规则一致
+var commonInitialisms = map[string]struct{}{
+	"API": {}, "ASCII": {}, "CPU": {}, "CSS": {}, "DNS": {}, "EOF": {}, "GUID": {}, "HTML": {}, "HTTP": {},
+	"HTTPS": {}, "ID": {}, "IP": {}, "JSON": {}, "LHS": {}, "QPS": {}, "RAM": {}, "RHS": {}, "RPC": {},
+	"SLA": {}, "SMTP": {}, "SSH": {}, "TLS": {}, "TTL": {}, "UID": {}, "UI": {}, "UUID": {}, "URI": {},
+	"URL": {}, "UTF8": {}, "VM": {}, "XML": {}, "XSRF": {}, "XSS": {},
+}
+
+// GoName 蛇形转Go的导出名称，缩写整体大写：user_id -> UserID，http_status -> HTTPStatus
+func GoName(s string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' || r == ' ' }) {
+		upper := strings.ToUpper(part)
+		if _, ok := commonInitialisms[upper]; ok {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + strings.ToLower(part[1:]))
+	}
+	name := b.String()
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "X" + name
+	}
+	return name
+}
+
+var fileTemplate = template.Must(template.New("model").Funcs(template.FuncMap{
+	"trimPtr": func(t string) string { return strings.TrimPrefix(t, "*") },
+}).Parse(`// Code generated by gormx gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- if .Time}}
+	"time"
+{{end}}
+	"github/flandersRin/gormx"
+	"gorm.io/gorm"
+)
+
+// {{.Name}} 表 {{.Table}}
+type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}} {{.Tag}}{{if .Comment}} // {{.Comment}}{{end}}
+{{- end}}
+{{- if .BaseInfo}}
+	gormx.ModelBaseInfo
+{{- end}}
+}
+
+func ({{.Name}}) TableName() string {
+	return "{{.Table}}"
+}
+
+// {{.Name}}Cols {{.Name}} 的列名，用作map条件的key
+var {{.Name}}Cols = struct {
+{{- range .Fields}}
+	{{.Name}} string
+{{- end}}
+{{- if .BaseInfo}}
+	CreateAt string
+	UpdateAt string
+	Deleted  string
+{{- end}}
+}{
+{{- range .Fields}}
+	{{.Name}}: "{{.Column}}",
+{{- end}}
+{{- if .BaseInfo}}
+	CreateAt: "create_at",
+	UpdateAt: "update_at",
+	Deleted:  "deleted",
+{{- end}}
+}
+
+// {{.Name}}Fields {{.Name}} 的类型化列引用，用于条件和排序
+var {{.Name}}Fields = struct {
+{{- range .Fields}}
+	{{.Name}} gormx.Field[{{trimPtr .Type}}]
+{{- end}}
+{{- if .BaseInfo}}
+	CreateAt gormx.Field[time.Time]
+	UpdateAt gormx.Field[time.Time]
+	Deleted  gormx.Field[gormx.DataBaseSoftDelete]
+{{- end}}
+}{
+{{- range .Fields}}
+	{{.Name}}: "{{.Column}}",
+{{- end}}
+{{- if .BaseInfo}}
+	CreateAt: "create_at",
+	UpdateAt: "update_at",
+	Deleted:  "deleted",
+{{- end}}
+}
+
+// New{{.Name}}Repo {{.Name}} 的repo
+func New{{.Name}}Repo(db *gorm.DB, opts ...gormx.Option) gormx.BaseRepo[{{.Name}}] {
+	return gormx.NewBaseRepo[{{.Name}}](db, opts...)
+}
+`))
+
+// Render 生成model的Go代码，已经过 gofmt
+func Render(pkg string, m Model) ([]byte, error) {
+	if pkg == "" {
+		pkg = "model"
+	}
+	useTime := m.BaseInfo
+	for _, f := range m.Fields {
+		if strings.HasSuffix(f.Type, "time.Time") {
+			useTime = true
+		}
+	}
+	var buf bytes.Buffer
+	err := fileTemplate.Execute(&buf, struct {
+		Model
+		Package string
+		Time    bool
+	}{Model: m, Package: pkg, Time: useTime})
+	if err != nil {
+		return nil, errors.Wrapf(err, "gen: render %s error", m.Table)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, errors.Wrapf(err, "gen: format %s error", m.Table)
+	}
+	return src, nil
+}
+
+// FileName 表对应的文件名：非字母数字的字符替换为 _；
+// 以 _test 或 GOOS、GOARCH 结尾的表名会被go视为测试文件或带有构建约束，加上后缀 _gen
+func FileName(table string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, table)
+	// go 忽略以 _ 或 . 开头的文件
+	name = strings.TrimLeft(name, "_")
+	if name == "" {
+		name = "table"
+	}
+	parts := strings.Split(strings.ToLower(name), "_")
+	if last := parts[len(parts)-1]; len(parts) > 1 && (last == "test" || knownOSArch[last]) {
+		name += "_gen"
+	}
+	return name + ".go"
+}
+
+// knownOSArch go 文件名后缀识别的 GOOS 和 GOARCH
+var knownOSArch = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true, "hurd": true, "illumos": true,
+	"ios": true, "js": true, "linux": true, "nacl": true, "netbsd": true, "openbsd": true, "plan9": true,
+	"solaris": true, "wasip1": true, "windows": true, "zos": true,
+	"386": true, "amd64": true, "amd64p32": true, "arm": true, "armbe": true, "arm64": true, "arm64be": true,
+	"loong64": true, "mips": true, "mipsle": true, "mips64": true, "mips64le": true, "mips64p32": true,
+	"mips64p32le": true, "ppc": true, "ppc64": true, "ppc64le": true, "riscv": true, "riscv64": true,
+	"s390": true, "s390x": true, "sparc": true, "sparc64": true, "wasm": true,
+}
@@ -0,0 +1,69 @@
+package gen
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Main 命令行入口，open为数据库驱动的 Open，例如 mysql.Open，支持的命令：
+//
+//	gormx gen -dsn DSN [-tables users,orders] [-pkg model] [-out ./model]
+//
+// 每张表生成一个 表名.go 文件（见 FileName），已存在的文件会被覆盖
+func Main(open func(dsn string) gorm.Dialector) {
+	if err := run(open, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(open func(dsn string) gorm.Dialector, args []string) error {
+	if len(args) == 0 || args[0] != "gen" {
+		return errors.New("usage: gormx gen -dsn DSN [-tables t1,t2] [-pkg model] [-out dir]")
+	}
+	fs := flag.NewFlagSet("gen", flag.ContinueOnError)
+	dsn := fs.String("dsn", "", "数据库连接")
+	tables := fs.String("tables", "", "需要生成的表，逗号分隔，为空时为所有表")
+	pkg := fs.String("pkg", "model", "生成的代码的包名")
+	out := fs.String("out", ".", "输出目录")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *dsn == "" {
+		return errors.New("gen: -dsn is required")
+	}
+	db, err := gorm.Open(open(*dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return errors.Wrap(err, "gen: open db error")
+	}
+	opts := Options{Package: *pkg}
+	if *tables != "" {
+		opts.Tables = strings.Split(*tables, ",")
+	}
+	models, err := Inspect(db, opts)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(*out, 0o755); err != nil {
+		return errors.Wrapf(err, "gen: create %s error", *out)
+	}
+	for _, m := range models {
+		src, err := Render(opts.Package, m)
+		if err != nil {
+			return err
+		}
+		file := filepath.Join(*out, FileName(m.Table))
+		if err = os.WriteFile(file, src, 0o644); err != nil {
+			return errors.Wrapf(err, "gen: write %s error", file)
+		}
+		fmt.Println(file)
+	}
+	return nil
+}
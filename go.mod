@@ -3,12 +3,23 @@ module github/flandersRin/gormx
 go 1.23.2
 
 require (
+	github.com/glebarez/sqlite v1.11.0
+	github.com/jinzhu/inflection v1.0.0
 	github.com/pkg/errors v0.9.1
 	gorm.io/gorm v1.25.12
 )
 
 require (
-	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/glebarez/go-sqlite v1.21.2 // indirect
+	github.com/google/uuid v1.3.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
+	github.com/mattn/go-isatty v0.0.17 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/sys v0.7.0 // indirect
 	golang.org/x/text v0.15.0 // indirect
+	modernc.org/libc v1.22.5 // indirect
+	modernc.org/mathutil v1.5.0 // indirect
+	modernc.org/memory v1.5.0 // indirect
+	modernc.org/sqlite v1.23.1 // indirect
 )
@@ -0,0 +1,156 @@
+package gormxtest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github/flandersRin/gormx"
+)
+
+// AssertTableOptions AssertTableState 的选项
+type AssertTableOptions struct {
+	Ctx context.Context
+	// 过滤条件，为空时比较整张表
+	Condition map[string]any
+	// 排序，为空时按主键排序；实际数据按此顺序与expected逐行比较
+	OrderBy string
+	// 时间比较的精度，为空时为秒，用于忽略数据库与Go之间的精度差异
+	TimePrecision time.Duration
+	// 包含已软删除的记录，包括 gorm.DeletedAt 软删除的记录
+	WithDeleted bool
+}
+
+// AssertTableState 查询表中的数据与expected逐行比较，不一致时通过t输出列级别的差异
+//
+// 只比较expected中出现的列，所以不写id、create_at等自增或自动生成的列即可忽略它们；
+// 列名可以使用驼峰或下划线，值按字符串形式比较，int与int64、[]byte与string视为相同，时间统一转换为UTC；
+// opts.Ctx 中有事务时在事务中查询
+//
+//	gormxtest.AssertTableState(t, &repo, []map[string]any{
+//		{"Name": "a", "Stock": 1},
+//		{"Name": "b", "Stock": 0},
+//	}, gormxtest.AssertTableOptions{Condition: map[string]any{"shop_id": 1}})
+func AssertTableState[T any](t testing.TB, repo *gormx.BaseRepo[T], expected []map[string]any, opts AssertTableOptions) bool {
+	t.Helper()
+	if opts.Ctx == nil {
+		opts.Ctx = context.Background()
+	}
+	if opts.TimePrecision <= 0 {
+		opts.TimePrecision = time.Second
+	}
+	var (
+		m      T
+		actual []map[string]any
+	)
+	db := repo.DB(opts.Ctx)
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&m); err != nil {
+		t.Errorf("gormxtest: assert table state %s error, parse schema: %v", repo.StructName, err)
+		return false
+	}
+	query := db.Model(&m)
+	ms := repo.Schema()
+	switch {
+	case opts.WithDeleted:
+		// gorm.DeletedAt 的模型会自动追加 deleted_at IS NULL
+		query = query.Unscoped()
+	case ms.SoftDeleteColumn != "" && !ms.GormDeletedAt:
+		query = query.Where(db.Statement.Quote(ms.SoftDeleteColumn)+" != ?", gormx.Deleted)
+	}
+	if len(opts.Condition) > 0 {
+		query = query.Where(columnKeys(stmt.Schema, opts.Condition))
+	}
+	order := opts.OrderBy
+	if order == "" {
+		order = db.Statement.Quote(repo.PrimaryKey)
+	}
+	if err := query.Order(order).Find(&actual).Error; err != nil {
+		t.Errorf("gormxtest: assert table state %s error, select: %v", repo.StructName, err)
+		return false
+	}
+
+	var diff []string
+	for i := 0; i < max(len(expected), len(actual)); i++ {
+		switch {
+		case i >= len(actual):
+			diff = append(diff, fmt.Sprintf("row %d: missing, expected %s", i, formatRow(columnKeys(stmt.Schema, expected[i]), opts)))
+		case i >= len(expected):
+			diff = append(diff, fmt.Sprintf("row %d: unexpected %s", i, formatRow(actual[i], opts)))
+		default:
+			want := columnKeys(stmt.Schema, expected[i])
+			for _, col := range gormx.SortedKeys(want) {
+				got, ok := actual[i][col]
+				if !ok {
+					diff = append(diff, fmt.Sprintf("row %d: column %s not found", i, col))
+					continue
+				}
+				w, g := normalizeCell(want[col], opts), normalizeCell(got, opts)
+				if w != g {
+					diff = append(diff, fmt.Sprintf("row %d: %s: expected %s, got %s", i, col, w, g))
+				}
+			}
+		}
+	}
+	if len(diff) > 0 {
+		t.Errorf("table %s state mismatch (expected %d rows, got %d):\n  %s",
+			stmt.Schema.Table, len(expected), len(actual), strings.Join(diff, "\n  "))
+		return false
+	}
+	return true
+}
+
+// columnKeys 把字段名或驼峰形式的key转换为列名
+func columnKeys(s *schema.Schema, row map[string]any) map[string]any {
+	res := make(map[string]any, len(row))
+	for k, v := range row {
+		column := gormx.Camel2Snake(k)
+		if f := s.LookUpField(k); f != nil && f.DBName != "" {
+			column = f.DBName
+		} else if f = s.LookUpField(column); f != nil && f.DBName != "" {
+			column = f.DBName
+		}
+		res[column] = v
+	}
+	return res
+}
+
+// formatRow 按列名排序输出一行
+func formatRow(row map[string]any, opts AssertTableOptions) string {
+	columns := gormx.SortedKeys(row)
+	parts := make([]string, 0, len(columns))
+	for _, col := range columns {
+		parts = append(parts, col+"="+normalizeCell(row[col], opts))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// normalizeCell 把单元格的值转换为可比较的字符串
+func normalizeCell(v any, opts AssertTableOptions) string {
+	rv := gormx.Indirect(reflect.ValueOf(v))
+	if !rv.IsValid() {
+		return "NULL"
+	}
+	switch x := rv.Interface().(type) {
+	case time.Time:
+		return x.UTC().Truncate(opts.TimePrecision).Format(time.RFC3339Nano)
+	case []byte:
+		return fmt.Sprintf("%q", x)
+	case string:
+		return fmt.Sprintf("%q", x)
+	case bool:
+		// mysql tinyint(1)
+		if x {
+			return "1"
+		}
+		return "0"
+	default:
+		return fmt.Sprint(x)
+	}
+}
@@ -0,0 +1,94 @@
+package gormxtest_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+
+	"github/flandersRin/gormx"
+	"github/flandersRin/gormx/gormxtest"
+)
+
+func TestMain(m *testing.M) {
+	gormxtest.SQLite = sqlite.Open
+	os.Exit(m.Run())
+}
+
+type assertItem struct {
+	ID        int64 `gorm:"primaryKey"`
+	Name      string
+	DeletedAt gorm.DeletedAt
+}
+
+type assertStock struct {
+	ID     int64 `gorm:"primaryKey"`
+	ShopID int64
+	Stock  int
+	gormx.ModelBaseInfo
+}
+
+// recorder 记录断言失败的输出
+type recorder struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(string, ...any) { r.failed = true }
+
+func TestAssertTableState(t *testing.T) {
+	ctx := context.Background()
+	items := gormxtest.NewRepo[assertItem](t)
+	for _, name := range []string{"a", "b"} {
+		if err := items.Insert(ctx, &assertItem{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := items.DeleteByPK(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	stocks := gormxtest.NewRepo[assertStock](t)
+	for i, stock := range []int{1, 0} {
+		if err := stocks.Insert(ctx, &assertStock{ShopID: 1, Stock: stock}); err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 {
+			if _, err := stocks.DeleteByPK(ctx, 2); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		assert func(t testing.TB) bool
+		want   bool
+	}{
+		{"deleted_at hidden", func(t testing.TB) bool {
+			return gormxtest.AssertTableState(t, &items, []map[string]any{{"Name": "a"}}, gormxtest.AssertTableOptions{})
+		}, true},
+		{"deleted_at with deleted", func(t testing.TB) bool {
+			return gormxtest.AssertTableState(t, &items, []map[string]any{{"Name": "a"}, {"name": "b"}},
+				gormxtest.AssertTableOptions{WithDeleted: true})
+		}, true},
+		{"condition", func(t testing.TB) bool {
+			return gormxtest.AssertTableState(t, &stocks, []map[string]any{{"Stock": 1}},
+				gormxtest.AssertTableOptions{Condition: map[string]any{"ShopID": 1}})
+		}, true},
+		{"mismatch", func(t testing.TB) bool {
+			return gormxtest.AssertTableState(t, &items, []map[string]any{{"Name": "b"}}, gormxtest.AssertTableOptions{})
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			if got := tt.assert(r); got != tt.want || r.failed == tt.want {
+				t.Errorf("got %v, failed %v, want %v", got, r.failed, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package gormxtest
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Container 测试用的数据库容器，gormxtest 不依赖 testcontainers-go，通过实现该接口适配，例如：
+//
+//	type mysqlContainer struct{ *mysql.MySQLContainer }
+//
+//	func (c mysqlContainer) Dialector(ctx context.Context) (gorm.Dialector, error) {
+//		dsn, err := c.ConnectionString(ctx, "parseTime=true")
+//		return gormmysql.Open(dsn), err
+//	}
+//
+//	func (c mysqlContainer) Terminate(ctx context.Context) error { return c.MySQLContainer.Terminate(ctx) }
+type Container interface {
+	// Dialector 连接容器内数据库的 gorm.Dialector
+	Dialector(ctx context.Context) (gorm.Dialector, error)
+	// Terminate 停止并删除容器
+	Terminate(ctx context.Context) error
+}
+
+type sharedContainer struct {
+	once      sync.Once
+	container Container
+	db        *gorm.DB
+	err       error
+}
+
+var sharedContainers sync.Map // name -> *sharedContainer
+
+// NewContainerDB 返回name对应容器的连接并迁移models，同一个测试包内相同name的容器只启动一次，所有测试共用连接
+// start 只在第一次调用时执行，例如 mysql.Run(ctx, "mysql:8.0")；容器需要在 TestMain 中调用 TerminateContainers 停止：
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		gormxtest.TerminateContainers(context.Background())
+//		os.Exit(code)
+//	}
+//
+// 共用同一个数据库时测试之间的数据互相可见，需要隔离时在 InTx 内执行并在最后返回错误回滚
+func NewContainerDB(t testing.TB, name string, start func(ctx context.Context) (Container, error), models ...any) *gorm.DB {
+	t.Helper()
+	v, _ := sharedContainers.LoadOrStore(name, &sharedContainer{})
+	sc := v.(*sharedContainer)
+	sc.once.Do(func() {
+		ctx := context.Background()
+		if sc.container, sc.err = start(ctx); sc.err != nil {
+			sc.err = errors.Wrapf(sc.err, "gormxtest: start container %s error", name)
+			return
+		}
+		dialector, err := sc.container.Dialector(ctx)
+		if err != nil {
+			sc.err = errors.Wrapf(err, "gormxtest: connect container %s error", name)
+			return
+		}
+		if sc.db, err = gorm.Open(dialector, &gorm.Config{Logger: logger.Discard}); err != nil {
+			sc.err = errors.Wrapf(err, "gormxtest: open container %s error", name)
+		}
+	})
+	if sc.err != nil {
+		t.Fatal(sc.err)
+	}
+	if err := Migrate(sc.db, models...); err != nil {
+		t.Fatal(err)
+	}
+	return sc.db
+}
+
+// TerminateContainers 关闭 NewContainerDB 打开的连接并停止所有容器，返回第一个停止失败的错误
+func TerminateContainers(ctx context.Context) error {
+	var firstErr error
+	sharedContainers.Range(func(name, v any) bool {
+		sharedContainers.Delete(name)
+		sc := v.(*sharedContainer)
+		if sc.db != nil {
+			if sqlDB, err := sc.db.DB(); err == nil {
+				_ = sqlDB.Close()
+			}
+		}
+		if sc.container != nil {
+			if err := sc.container.Terminate(ctx); err != nil && firstErr == nil {
+				firstErr = errors.Wrapf(err, "gormxtest: terminate container %v error", name)
+			}
+		}
+		return true
+	})
+	return firstErr
+}
@@ -0,0 +1,76 @@
+// Package gormxtest 测试辅助：打开迁移好表结构的数据库，直接得到可用的 gorm.DB、BaseRepo
+package gormxtest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github/flandersRin/gormx"
+)
+
+// SQLite 打开sqlite的 gorm.Dialector，gormx 不依赖任何sqlite驱动，使用 NewSQLite 前需要设置，例如在 TestMain 中：
+//
+//	gormxtest.SQLite = sqlite.Open // gorm.io/driver/sqlite 或 github.com/glebarez/sqlite
+var SQLite func(dsn string) gorm.Dialector
+
+var sqliteSeq atomic.Int64
+
+// onUpdateRe mysql 的 ON UPDATE 子句，sqlite 不支持
+var onUpdateRe = regexp.MustCompile(`(?i)\s+ON\s+UPDATE\s+.*$`)
+
+// NewSQLite 打开一个内存sqlite并迁移models，测试结束时自动关闭，每次调用都是独立的数据库
+// ModelBaseInfo 等模型中 mysql 风格的默认值（CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP）会转换为sqlite可以执行的形式
+func NewSQLite(t testing.TB, models ...any) *gorm.DB {
+	t.Helper()
+	if SQLite == nil {
+		t.Fatal("gormxtest: SQLite dialector is not set, e.g. gormxtest.SQLite = sqlite.Open")
+	}
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	dsn := fmt.Sprintf("file:gormxtest_%s_%d?mode=memory&cache=shared", name, sqliteSeq.Add(1))
+	db, err := gorm.Open(SQLite(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("gormxtest: open sqlite error: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("gormxtest: open sqlite error: %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	if err = Migrate(db, models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// NewRepo 在 NewSQLite 打开的数据库上创建T的 BaseRepo，T的表已经迁移好
+func NewRepo[T any](t testing.TB, opts ...gormx.Option) gormx.BaseRepo[T] {
+	t.Helper()
+	return gormx.NewBaseRepo[T](NewSQLite(t, new(T)), opts...)
+}
+
+// Migrate 迁移models，迁移前去掉默认值中当前数据库不支持的 ON UPDATE 子句（只有mysql支持）
+func Migrate(db *gorm.DB, models ...any) error {
+	for _, m := range models {
+		if db.Dialector.Name() != "mysql" {
+			stmt := &gorm.Statement{DB: db}
+			if err := stmt.Parse(m); err != nil {
+				return errors.Wrapf(err, "gormxtest: parse %T error", m)
+			}
+			// 修改的是db缓存中的schema，迁移和之后的插入都使用去掉 ON UPDATE 后的默认值
+			for _, f := range stmt.Schema.Fields {
+				f.DefaultValue = onUpdateRe.ReplaceAllString(f.DefaultValue, "")
+			}
+		}
+		if err := db.AutoMigrate(m); err != nil {
+			return errors.Wrapf(err, "gormxtest: migrate %T error", m)
+		}
+	}
+	return nil
+}
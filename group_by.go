@@ -0,0 +1,150 @@
+package gormx
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Aggregate 聚合列，通过 Count、CountDistinct、Sum、Avg、Min、Max 构造
+type Aggregate struct {
+	Func   string
+	Column string
+	Alias  string
+}
+
+// Count COUNT(*) AS alias
+func Count(alias string) Aggregate {
+	return Aggregate{Func: "COUNT", Alias: alias}
+}
+
+// CountDistinct COUNT(DISTINCT column) AS alias
+func CountDistinct(column, alias string) Aggregate {
+	return Aggregate{Func: "COUNT DISTINCT", Column: column, Alias: alias}
+}
+
+// Sum SUM(column) AS alias
+func Sum(column, alias string) Aggregate {
+	return Aggregate{Func: "SUM", Column: column, Alias: alias}
+}
+
+// Avg AVG(column) AS alias
+func Avg(column, alias string) Aggregate {
+	return Aggregate{Func: "AVG", Column: column, Alias: alias}
+}
+
+// Min MIN(column) AS alias
+func Min(column, alias string) Aggregate {
+	return Aggregate{Func: "MIN", Column: column, Alias: alias}
+}
+
+// Max MAX(column) AS alias
+func Max(column, alias string) Aggregate {
+	return Aggregate{Func: "MAX", Column: column, Alias: alias}
+}
+
+var aliasPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// GroupBy 按groupColumns分组聚合，结果的key为分组列名和聚合别名，按分组列排序
+// having为空时不过滤，可以引用聚合别名，例如 "cnt > ?"；列名兼容驼峰和蛇形，不存在的列返回 ErrUnknownColumn
+//
+//	rows, err := repo.GroupBy(ctx, []string{"status"}, []gormx.Aggregate{gormx.Count("cnt"), gormx.Sum("amount", "total")},
+//		map[string]any{"shop_id": 1}, "cnt > ?", 10)
+func (b *BaseRepo[T]) GroupBy(ctx context.Context, groupColumns []string, aggregates []Aggregate, condition map[string]any,
+	having string, havingArgs ...any) ([]map[string]any, error) {
+	var res []map[string]any
+	query, err := b.groupByQuery(ctx, groupColumns, aggregates, condition, having, havingArgs)
+	if err != nil {
+		return nil, err
+	}
+	if err = query.Find(&res).Error; err != nil {
+		return nil, errors.Wrapf(err, "db: group %s by %v error, condition: %v", b.StructName, groupColumns, condition)
+	}
+	for _, row := range res {
+		for k, v := range row {
+			// mysql 驱动以 []byte 返回 DECIMAL 和未知类型的列
+			if bs, ok := v.([]byte); ok {
+				row[k] = string(bs)
+			}
+		}
+	}
+	return res, nil
+}
+
+// GroupByTo 与 BaseRepo.GroupBy 相同，结果扫描到R，R的字段对应分组列名和聚合别名
+func GroupByTo[R, T any](ctx context.Context, repo *BaseRepo[T], groupColumns []string, aggregates []Aggregate, condition map[string]any,
+	having string, havingArgs ...any) ([]R, error) {
+	var res []R
+	query, err := repo.groupByQuery(ctx, groupColumns, aggregates, condition, having, havingArgs)
+	if err != nil {
+		return nil, err
+	}
+	if err = query.Scan(&res).Error; err != nil {
+		return nil, errors.Wrapf(err, "db: group %s by %v error, condition: %v", repo.StructName, groupColumns, condition)
+	}
+	return res, nil
+}
+
+func (b *BaseRepo[T]) groupByQuery(ctx context.Context, groupColumns []string, aggregates []Aggregate, condition map[string]any,
+	having string, havingArgs []any) (*gorm.DB, error) {
+	db := b.readDB(ctx)
+	quote := func(s string) string { return db.Statement.Quote(s) }
+	column := func(name string) (string, error) {
+		f, err := b.lookupColumn(name)
+		if err != nil {
+			return "", errors.WithMessage(err, "group by")
+		}
+		return f.DBName, nil
+	}
+
+	groups := make([]clause.Column, 0, len(groupColumns))
+	orders := make([]string, 0, len(groupColumns))
+	selects := make([]string, 0, len(groupColumns)+len(aggregates))
+	for _, name := range groupColumns {
+		col, err := column(name)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, clause.Column{Name: col})
+		orders = append(orders, quote(col))
+		selects = append(selects, quote(col))
+	}
+	for _, agg := range aggregates {
+		if !aliasPattern.MatchString(agg.Alias) {
+			return nil, errors.Errorf("db: group %s error, invalid alias: %q", b.StructName, agg.Alias)
+		}
+		expr := "*"
+		if agg.Column != "" {
+			col, err := column(agg.Column)
+			if err != nil {
+				return nil, err
+			}
+			expr = quote(col)
+		}
+		fn := agg.Func
+		if fn == "COUNT DISTINCT" {
+			fn, expr = "COUNT", "DISTINCT "+expr
+		}
+		switch fn {
+		case "COUNT", "SUM", "AVG", "MIN", "MAX":
+		default:
+			return nil, errors.Errorf("db: group %s error, unsupported aggregate: %s", b.StructName, agg.Func)
+		}
+		selects = append(selects, fn+"("+expr+") AS "+quote(agg.Alias))
+	}
+
+	var m T
+	query := db.Model(&m).Select(strings.Join(selects, ", ")).
+		Scopes(b.notDeleted).Scopes(whereCond(b.columnKeys(condition)))
+	if len(groups) > 0 {
+		query = query.Clauses(clause.GroupBy{Columns: groups}).Order(strings.Join(orders, ", "))
+	}
+	if having != "" {
+		query = query.Having(having, havingArgs...)
+	}
+	return query, nil
+}
@@ -0,0 +1,180 @@
+package gormx
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	historySettingKey = "gormx:history"
+	historyPluginName = "gormx:history"
+	// historyValidTo 历史表中记录被更新或删除的时间，即该版本失效的时间
+	historyValidTo = "gormx_valid_to"
+	// historyOperation 历史表中使该版本失效的操作，update 或 delete
+	historyOperation = "gormx_operation"
+)
+
+// EnableHistory 开启行历史：通过repo更新、删除记录前，把记录的完整旧版本写入影子表 <表名>_history，
+// 历史表需要先通过 MigrateHistory 创建，可以用 SelectAsOf 查询某个时间点的记录
+//
+// 旧版本与写操作在同一个事务中写入；直接执行SQL的方法（BatchUpdateByPK、RawExec等）不记录
+func (b *BaseRepo[T]) EnableHistory() error {
+	if _, ok := b.GormDB.Config.Plugins[historyPluginName]; !ok {
+		if err := b.GormDB.Use(historyPlugin{}); err != nil {
+			return errors.Wrapf(err, "db: enable %s history error", b.StructName)
+		}
+	}
+	b.opts.history = true
+	return nil
+}
+
+func (b *BaseRepo[T]) historyTable() string {
+	return b.tableName() + "_history"
+}
+
+// MigrateHistory 创建历史表，列与模型的表相同，另外有 gormx_valid_to（版本失效时间）和 gormx_operation 两列
+// 历史表已存在时不做修改，模型的表增加列后需要自行给历史表增加相同的列
+func (b *BaseRepo[T]) MigrateHistory(ctx context.Context) error {
+	db := b.GormDB.WithContext(ctx)
+	table := b.historyTable()
+	if db.Migrator().HasTable(table) {
+		return nil
+	}
+	timeType := "TIMESTAMP"
+	switch db.Dialector.Name() {
+	case "mysql":
+		timeType = "DATETIME(6)"
+	case "postgres":
+		timeType = "TIMESTAMPTZ"
+	}
+	quote := func(s string) string { return db.Statement.Quote(s) }
+	stmts := []string{
+		"CREATE TABLE " + quote(table) + " AS SELECT * FROM " + quote(b.tableName()) + " WHERE 1 = 0",
+		"ALTER TABLE " + quote(table) + " ADD COLUMN " + quote(historyValidTo) + " " + timeType,
+		"ALTER TABLE " + quote(table) + " ADD COLUMN " + quote(historyOperation) + " VARCHAR(16)",
+		"CREATE INDEX " + quote("idx_"+table+"_as_of") + " ON " + quote(table) +
+			" (" + quote(b.PrimaryKey) + ", " + quote(historyValidTo) + ")",
+	}
+	for _, sql := range stmts {
+		if err := db.Exec(sql).Error; err != nil {
+			return errors.Wrapf(err, "db: migrate %s history error, sql: %s", b.StructName, sql)
+		}
+	}
+	return nil
+}
+
+// SelectAsOf 查询主键为pk的记录在t时刻的状态，t时刻记录还未创建、已删除（包括软删除）时返回nil
+func (b *BaseRepo[T]) SelectAsOf(ctx context.Context, pk any, t time.Time) (*T, error) {
+	s, err := b.gormSchema()
+	if err != nil {
+		return nil, err
+	}
+	db := b.readDB(ctx)
+	var (
+		m    T
+		res  []*T
+		cols = make([]string, 0, len(s.DBNames))
+	)
+	for _, name := range s.DBNames {
+		cols = append(cols, db.Statement.Quote(name))
+	}
+	// t之后第一次被更新或删除前的版本就是t时刻的状态
+	res, err = b.find(db.Session(&gorm.Session{NewDB: true}).Table(b.historyTable()).Select(cols).
+		Where(map[string]any{b.PrimaryKey: pk}).Where(clause.Gt{Column: historyValidTo, Value: t}).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: historyValidTo}}).Limit(1))
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: select %s as of %s error, pk: %v", b.StructName, t, pk)
+	}
+	if len(res) == 0 {
+		// t之后没有变更，为当前的状态
+		if res, err = b.find(db.Model(&m).Where(map[string]any{b.PrimaryKey: pk})); err != nil {
+			return nil, errors.Wrapf(err, "db: select %s as of %s error, pk: %v", b.StructName, t, pk)
+		}
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	row := res[0]
+	if !b.existedAt(ctx, row, t) {
+		return nil, nil
+	}
+	return row, nil
+}
+
+// existedAt 记录的版本在t时刻是否存在：已创建且未软删除
+func (b *BaseRepo[T]) existedAt(ctx context.Context, row *T, t time.Time) bool {
+	s, err := b.gormSchema()
+	if err != nil {
+		return true
+	}
+	rv := indirectModel(reflect.ValueOf(row))
+	if f := s.LookUpField("create_at"); f != nil {
+		if v, _ := f.ValueOf(ctx, rv); v != nil {
+			if createAt, ok := v.(time.Time); ok && createAt.After(t) {
+				return false
+			}
+		}
+	}
+	if f, gormDeletedAt := softDeleteField(s); f != nil {
+		v, _ := f.ValueOf(ctx, rv)
+		if gormDeletedAt {
+			if at, ok := v.(gorm.DeletedAt); ok && at.Valid && !at.Time.After(t) {
+				return false
+			}
+		} else if v == Deleted {
+			return false
+		}
+	}
+	return true
+}
+
+// historyPlugin 更新、删除前把受影响的记录复制到历史表
+type historyPlugin struct{}
+
+func (historyPlugin) Name() string {
+	return historyPluginName
+}
+
+func (p historyPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Update().After("gorm:begin_transaction").Before("gorm:update").Register(historyPluginName, p.before(AuditUpdate)); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:begin_transaction").Before("gorm:delete").Register(historyPluginName, p.before(AuditDelete))
+}
+
+func (historyPlugin) before(op string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || db.DryRun || db.Statement.Schema == nil {
+			return
+		}
+		if v, ok := db.Get(historySettingKey); !ok || v != true {
+			return
+		}
+		stmt := db.Statement
+		where, ok := statementWhere(stmt)
+		if !ok {
+			return
+		}
+		cols := make([]clause.Column, 0, len(stmt.Schema.DBNames)+2)
+		quoted := make([]string, 0, len(stmt.Schema.DBNames))
+		for _, name := range stmt.Schema.DBNames {
+			cols = append(cols, clause.Column{Name: name})
+			quoted = append(quoted, stmt.Quote(name))
+		}
+		selects := db.Session(&gorm.Session{NewDB: true}).Table(stmt.Table).
+			Select(strings.Join(quoted, ", ")+", ?, ?", time.Now(), op).Clauses(where)
+		cols = append(cols, clause.Column{Name: historyValidTo}, clause.Column{Name: historyOperation})
+		err := db.Session(&gorm.Session{NewDB: true}).
+			Exec("INSERT INTO ? (?) ?", clause.Table{Name: stmt.Table + "_history"}, cols, selects).Error
+		if err != nil {
+			_ = db.AddError(errors.Wrapf(err, "db: copy %s history error", stmt.Table))
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package gormx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// IDGenerator 主键生成器，开启 WithIDGenerator 后 Insert、BatchInsert 等插入前给主键为零值的记录生成主键
+type IDGenerator interface {
+	NextID(ctx context.Context) (any, error)
+}
+
+// IDGeneratorFunc 函数形式的 IDGenerator
+type IDGeneratorFunc func(ctx context.Context) (any, error)
+
+func (f IDGeneratorFunc) NextID(ctx context.Context) (any, error) {
+	return f(ctx)
+}
+
+// WithIDGenerator 插入时主键为零值的记录由gen生成主键，已经赋值的主键不会被覆盖；生成的值需要能赋给主键字段，
+// 例如 Snowflake 对应整数主键，UUIDv4、UUIDv7、ULID 对应字符串主键
+func WithIDGenerator(gen IDGenerator) Option {
+	return func(o *options) {
+		o.idGenerator = gen
+	}
+}
+
+// assignIDs 给主键为零值的记录生成主键
+func (b *BaseRepo[T]) assignIDs(ctx context.Context, rows ...*T) error {
+	gen := b.opts.idGenerator
+	if gen == nil || len(rows) == 0 {
+		return nil
+	}
+	f, err := b.lookupColumn(b.PrimaryKey)
+	if err != nil {
+		return errors.WithMessage(err, "generate id")
+	}
+	for _, row := range rows {
+		if row == nil {
+			continue
+		}
+		rv := indirectModel(reflect.ValueOf(row))
+		if _, zero := f.ValueOf(ctx, rv); !zero {
+			continue
+		}
+		id, err := gen.NextID(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "db: generate %s id error", b.StructName)
+		}
+		if err = f.Set(ctx, rv, id); err != nil {
+			return errors.Wrapf(err, "db: set %s id error, id: %v", b.StructName, id)
+		}
+	}
+	return nil
+}
+
+// snowflake 各部分的位数：41位毫秒时间戳，10位节点，12位序号
+const (
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	snowflakeMaxNode  = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSeq   = 1<<snowflakeSeqBits - 1
+)
+
+// snowflakeEpoch 时间戳的起点 2020-01-01 00:00:00 UTC
+var snowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+// Snowflake 生成int64的雪花ID，同一毫秒内序号用完时等待下一毫秒；时钟回拨时返回错误
+type Snowflake struct {
+	mu   sync.Mutex
+	node int64
+	last int64
+	seq  int64
+}
+
+// NewSnowflake node为节点ID，取值 0~1023，同一张表的多个实例需要使用不同的node
+func NewSnowflake(node int64) (*Snowflake, error) {
+	if node < 0 || node > snowflakeMaxNode {
+		return nil, errors.Errorf("db: snowflake node must be between 0 and %d, got %d", snowflakeMaxNode, node)
+	}
+	return &Snowflake{node: node}, nil
+}
+
+func (s *Snowflake) NextID(context.Context) (any, error) {
+	return s.Next()
+}
+
+// Next 生成下一个ID
+func (s *Snowflake) Next() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UnixMilli() - snowflakeEpoch
+	if now < s.last {
+		return 0, errors.Errorf("db: snowflake clock moved backwards by %dms", s.last-now)
+	}
+	if now == s.last {
+		s.seq = (s.seq + 1) & snowflakeMaxSeq
+		if s.seq == 0 {
+			for now <= s.last {
+				time.Sleep(100 * time.Microsecond)
+				now = time.Now().UnixMilli() - snowflakeEpoch
+			}
+		}
+	} else {
+		s.seq = 0
+	}
+	s.last = now
+	return now<<(snowflakeNodeBits+snowflakeSeqBits) | s.node<<snowflakeSeqBits | s.seq, nil
+}
+
+// UUIDv4 生成随机的 UUID 字符串，例如 0f8fad5b-d9cb-469f-a165-70867728950e
+var UUIDv4 IDGenerator = IDGeneratorFunc(func(context.Context) (any, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return nil, err
+	}
+	return formatUUID(u, 4), nil
+})
+
+// UUIDv7 生成以毫秒时间戳开头的 UUID 字符串，按生成时间大致有序，作为索引时比 UUIDv4 的写入性能好
+var UUIDv7 IDGenerator = IDGeneratorFunc(func(context.Context) (any, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[6:]); err != nil {
+		return nil, err
+	}
+	ms := uint64(time.Now().UnixMilli())
+	u[0], u[1], u[2], u[3], u[4], u[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	return formatUUID(u, 7), nil
+})
+
+func formatUUID(u [16]byte, version byte) string {
+	u[6] = u[6]&0x0f | version<<4
+	u[8] = u[8]&0x3f | 0x80 // RFC 4122 variant
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// crockford ULID 使用的 Crockford Base32 字符集
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID 生成26个字符的 ULID：48位毫秒时间戳加80位随机数，按生成时间大致有序
+var ULID IDGenerator = IDGeneratorFunc(func(context.Context) (any, error) {
+	var u [16]byte
+	binary.BigEndian.PutUint64(u[:8], uint64(time.Now().UnixMilli())<<16)
+	if _, err := rand.Read(u[6:]); err != nil {
+		return nil, err
+	}
+	// 128位按5位一组编码，最高位补2个0共130位
+	hi, lo := binary.BigEndian.Uint64(u[:8]), binary.BigEndian.Uint64(u[8:])
+	var buf [26]byte
+	for i := 25; i >= 0; i-- {
+		buf[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:]), nil
+})
@@ -0,0 +1,280 @@
+package gormx
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ErrTooManyImportErrors 出错的行数超过 ImportOptions.MaxErrors，导入提前结束
+var ErrTooManyImportErrors = errors.New("db: too many import errors")
+
+// ImportOptions ImportCSV 的选项
+type ImportOptions struct {
+	// 表头到字段名或列名的映射，映射为 "-" 的列忽略；
+	// 未映射的表头依次按 `gormx:"export:姓名"`、字段名、列名匹配，与 ExportCSV 的输出可以直接导入
+	Columns map[string]string
+	// 每批插入的条数，为0时为500
+	BatchSize int
+	// 校验解析后的记录，返回错误时该行不插入并记录到 ImportResult.Errors；
+	// 模型实现了 Validate() error 时也会调用
+	Validate func(row any) error
+	// 出错的行数超过 MaxErrors 时停止导入并返回 ErrTooManyImportErrors，为0时不限制
+	MaxErrors int
+	// 唯一键冲突的行忽略，见 BatchInsertIgnore
+	IgnoreDuplicates bool
+	// Loader 不为空时通过 Loader 批量写入，例如 mysql 的 LOAD DATA LOCAL INFILE、postgres 的 COPY，
+	// 需要使用具体驱动的接口，由调用方实现；columns 为列名，records 为与 columns 对应的原始值，
+	// 记录仍然会先解析和校验，但不会生成ID，也不会触发gorm的hook
+	Loader func(ctx context.Context, db *gorm.DB, table string, columns []string, records [][]string) (int64, error)
+}
+
+// ImportRowError 一行的错误，Line 为CSV中的行号（表头为第1行）
+type ImportRowError struct {
+	Line int
+	Err  error
+}
+
+func (e ImportRowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// ImportResult ImportCSV 的结果
+type ImportResult struct {
+	// 读取的数据行数，不包括表头
+	Total int64
+	// 实际插入的行数
+	Inserted int64
+	Errors   []ImportRowError
+}
+
+type importRow[T any] struct {
+	line   int
+	model  *T
+	record []string
+}
+
+// ImportCSV 导入CSV，第一行为表头，按列映射到模型字段后分批插入，返回每一行的错误：
+//
+//	res, err := repo.ImportCSV(ctx, file, gormx.ImportOptions{Columns: map[string]string{"姓名": "name"}})
+//	for _, e := range res.Errors {
+//		log.Printf("line %d: %v", e.Line, e.Err)
+//	}
+//
+// 解析或校验失败的行不插入；一批插入失败时逐行重试，找出出错的行。
+// 每批在独立的事务中插入，ctx已经在事务中时为SAVEPOINT，失败的批次不影响已经插入的批次。
+// err 只在读取CSV失败、表头无法映射等导致整个导入无法继续时返回
+func (b *BaseRepo[T]) ImportCSV(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: import %s error, read header", b.StructName)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	fields, err := b.importFields(header, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ImportResult{}
+	var batch []importRow[T]
+	tooMany := func() error {
+		if opts.MaxErrors > 0 && len(res.Errors) > opts.MaxErrors {
+			return errors.Wrapf(ErrTooManyImportErrors, "db: import %s error, errors: %d", b.StructName, len(res.Errors))
+		}
+		return nil
+	}
+	flush := func() error {
+		if len(batch) > 0 {
+			b.importBatch(ctx, batch, fields, opts, res)
+			batch = batch[:0]
+		}
+		return tooMany()
+	}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if !errors.As(err, &pe) {
+				return res, errors.Wrapf(err, "db: import %s error", b.StructName)
+			}
+			// 格式错误的行跳过，继续读取后面的行
+			res.Total++
+			res.Errors = append(res.Errors, ImportRowError{Line: pe.StartLine, Err: err})
+			if err = tooMany(); err != nil {
+				return res, err
+			}
+			continue
+		}
+		res.Total++
+		// 带引号的值可以跨行，行号以读取器记录的为准
+		line, _ := cr.FieldPos(0)
+		m, err := b.importModel(ctx, record, fields, opts)
+		if err != nil {
+			res.Errors = append(res.Errors, ImportRowError{Line: line, Err: err})
+		} else {
+			batch = append(batch, importRow[T]{line: line, model: m, record: record})
+		}
+		if err = tooMany(); err != nil {
+			return res, err
+		}
+		if len(batch) >= opts.BatchSize {
+			if err = flush(); err != nil {
+				return res, err
+			}
+		}
+	}
+	return res, flush()
+}
+
+// importFields 每一列对应的字段，忽略的列为nil
+func (b *BaseRepo[T]) importFields(header []string, opts ImportOptions) ([]*schema.Field, error) {
+	s, err := b.gormSchema()
+	if err != nil {
+		return nil, err
+	}
+	byExport := make(map[string]*schema.Field)
+	for _, f := range s.Fields {
+		if h := gormxTagSettings(f)["EXPORT"]; h != "" && h != "-" && f.DBName != "" {
+			byExport[h] = f
+		}
+	}
+	fields := make([]*schema.Field, len(header))
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		name, ok := opts.Columns[h]
+		if name == "-" {
+			continue
+		}
+		if !ok {
+			if f := byExport[h]; f != nil {
+				fields[i] = f
+				continue
+			}
+			name = h
+		}
+		f, err := b.lookupColumn(name)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "import header %q", h)
+		}
+		fields[i] = f
+	}
+	return fields, nil
+}
+
+// importModel 把一行解析为模型并校验，空字符串为字段的零值（指针为nil）
+func (b *BaseRepo[T]) importModel(ctx context.Context, record []string, fields []*schema.Field, opts ImportOptions) (*T, error) {
+	if len(record) != len(fields) {
+		return nil, errors.Errorf("expected %d columns, got %d", len(fields), len(record))
+	}
+	m := new(T)
+	rv := indirectModel(reflect.ValueOf(m))
+	for i, f := range fields {
+		if f == nil || record[i] == "" {
+			continue
+		}
+		if err := f.Set(ctx, rv, record[i]); err != nil {
+			return nil, errors.Errorf("column %s: invalid value %q", f.DBName, record[i])
+		}
+	}
+	if v, ok := any(m).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Validate != nil {
+		if err := opts.Validate(m); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// importBatch 插入一批记录，失败时逐行重试
+func (b *BaseRepo[T]) importBatch(ctx context.Context, batch []importRow[T], fields []*schema.Field, opts ImportOptions, res *ImportResult) {
+	if opts.Loader != nil {
+		n, err := b.importLoad(ctx, batch, fields, opts)
+		if err != nil {
+			for _, row := range batch {
+				res.Errors = append(res.Errors, ImportRowError{Line: row.line, Err: err})
+			}
+			return
+		}
+		res.Inserted += n
+		return
+	}
+	insert := func(ctx context.Context, rows []*T) (int64, error) {
+		if opts.IgnoreDuplicates {
+			return b.BatchInsertIgnore(ctx, rows, len(rows))
+		}
+		return b.BatchInsert(ctx, rows, len(rows))
+	}
+	rows := make([]*T, len(batch))
+	for i, row := range batch {
+		rows[i] = row.model
+	}
+	var n int64
+	err := b.InTx(ctx, func(ctx context.Context) (err error) {
+		n, err = insert(ctx, rows)
+		return err
+	})
+	if err == nil {
+		res.Inserted += n
+		return
+	}
+	for _, row := range batch {
+		// 批量插入失败时可能已经填充了自增主键，重新解析（已经校验过，不会出错）
+		m, _ := b.importModel(ctx, row.record, fields, ImportOptions{})
+		err := b.InTx(ctx, func(ctx context.Context) (err error) {
+			n, err = insert(ctx, []*T{m})
+			return err
+		})
+		if err != nil {
+			res.Errors = append(res.Errors, ImportRowError{Line: row.line, Err: err})
+			continue
+		}
+		res.Inserted += n
+	}
+}
+
+func (b *BaseRepo[T]) importLoad(ctx context.Context, batch []importRow[T], fields []*schema.Field, opts ImportOptions) (int64, error) {
+	var columns []string
+	var idx []int
+	for i, f := range fields {
+		if f != nil {
+			columns = append(columns, f.DBName)
+			idx = append(idx, i)
+		}
+	}
+	records := make([][]string, len(batch))
+	for i, row := range batch {
+		records[i] = make([]string, len(idx))
+		for j, k := range idx {
+			records[i][j] = row.record[k]
+		}
+	}
+	s, err := b.gormSchema()
+	if err != nil {
+		return 0, err
+	}
+	n, err := opts.Loader(ctx, b.withTransactionCtx(ctx), s.Table, columns, records)
+	if err != nil {
+		return n, errors.Wrapf(err, "db: load %s error", b.StructName)
+	}
+	return n, nil
+}
@@ -0,0 +1,26 @@
+package gormx
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// IncrementByPK 原子地把主键为pk的记录的column加上delta（delta为负数时为减），返回影响行数
+// 生成 UPDATE ... SET column = column + ? WHERE pk = ?，不需要先查询再更新
+func (b *BaseRepo[T]) IncrementByPK(ctx context.Context, pk any, column string, delta int64) (int64, error) {
+	if err := b.singlePK(); err != nil {
+		return 0, errors.WithMessage(err, "increment by pk")
+	}
+	return b.IncrementByMap(ctx, map[string]any{b.PrimaryKey: pk}, column, delta)
+}
+
+// IncrementByMap 原子地把满足条件的记录的column加上delta
+// condition和column兼容驼峰和蛇形
+// 注：condition只支持等值条件，库存扣减等需要校验下限时使用 RawExec 或在事务内 SelectOneByPKForUpdate
+func (b *BaseRepo[T]) IncrementByMap(ctx context.Context, condition map[string]any, column string, delta int64) (int64, error) {
+	column = Camel2Snake(column)
+	expr := gorm.Expr(b.GormDB.Statement.Quote(column)+" + ?", delta)
+	return b.UpdateByMap(ctx, condition, map[string]any{column: expr})
+}
@@ -0,0 +1,41 @@
+package gormx
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm/clause"
+)
+
+// InsertIgnore 插入单条记录，唯一键冲突时忽略，inserted表示是否实际插入
+// mysql 生成 ON DUPLICATE KEY UPDATE pk = pk，postgres 生成 ON CONFLICT DO NOTHING，
+// 与 INSERT IGNORE 不同，数据截断等其他错误仍然会返回
+func (b *BaseRepo[T]) InsertIgnore(ctx context.Context, m *T) (inserted bool, err error) {
+	if err = b.assignIDs(ctx, m); err != nil {
+		return false, err
+	}
+	tx := b.withTransactionCtx(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(m)
+	if err = tx.Error; err != nil {
+		return false, errors.Wrapf(err, "db: insert ignore %s error, param: %+v", b.StructName, m)
+	}
+	if tx.RowsAffected == 0 {
+		return false, nil
+	}
+	return true, b.invalidateInserted(ctx, m)
+}
+
+// BatchInsertIgnore 批量插入，忽略唯一键冲突的记录，返回实际插入的行数
+// 注：需要根据插入数据的大小来设置batchSize
+func (b *BaseRepo[T]) BatchInsertIgnore(ctx context.Context, m []*T, batchSize int) (int64, error) {
+	if err := b.assignIDs(ctx, m...); err != nil {
+		return 0, err
+	}
+	tx := b.withTransactionCtx(ctx).Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(m, batchSize)
+	if tx.Error != nil {
+		return 0, errors.Wrapf(tx.Error, "db: batch insert ignore %s error, param: %+v", b.StructName, m)
+	}
+	if tx.RowsAffected == 0 {
+		return 0, nil
+	}
+	return tx.RowsAffected, b.invalidateInserted(ctx, m...)
+}
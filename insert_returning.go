@@ -0,0 +1,41 @@
+package gormx
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm/clause"
+)
+
+// InsertReturning 插入单条记录并用数据库中的完整记录回填m，包括默认值、触发器和生成列的值
+// postgres、sqlite 使用 RETURNING * 一次完成；mysql 不支持 RETURNING，插入后按主键在主库上重新查询：
+// ctx在事务中时使用同一事务，否则可能是连接池中的另一个连接，插入已自动提交，同样能读到；
+// 两次查询之间记录可能被其他请求修改，需要严格一致时在事务中调用
+func (b *BaseRepo[T]) InsertReturning(ctx context.Context, m *T) error {
+	db := b.withTransactionCtx(ctx)
+	switch db.Dialector.Name() {
+	case "postgres", "sqlite":
+		if err := b.assignIDs(ctx, m); err != nil {
+			return err
+		}
+		if err := db.Clauses(clause.Returning{}).Create(m).Error; err != nil {
+			return errors.Wrapf(err, "db: insert returning %s error, param: %+v", b.StructName, m)
+		}
+		return b.invalidateInserted(ctx, m)
+	}
+
+	if err := b.Insert(ctx, m); err != nil {
+		return err
+	}
+	pk, ok := b.pkValue(ctx, m)
+	if !ok {
+		return errors.Errorf("db: insert returning %s error, primary key is empty after insert, param: %+v", b.StructName, m)
+	}
+	// 不走从库和缓存，避免读到旧数据；不在事务中时不保证与插入使用同一连接
+	var fresh T
+	if err := b.withTransactionCtx(ctx).Where(map[string]any{b.PrimaryKey: pk}).Take(&fresh).Error; err != nil {
+		return errors.Wrapf(err, "db: insert returning %s error, reload pk: %v", b.StructName, pk)
+	}
+	*m = fresh
+	return nil
+}
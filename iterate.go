@@ -0,0 +1,81 @@
+package gormx
+
+import (
+	"context"
+	"iter"
+
+	"github.com/pkg/errors"
+)
+
+// SelectEach 按主键顺序每次读取batchSize条满足条件的记录交给fn处理，内存占用与batchSize成正比
+// fn返回错误时停止并返回该错误；condition里的key兼容驼峰和蛇形
+//
+// 使用主键游标（WHERE pk > ? ORDER BY pk LIMIT ?）分页，不会像OFFSET那样越往后越慢
+func (b *BaseRepo[T]) SelectEach(ctx context.Context, condition map[string]any, batchSize int, fn func([]*T) error) error {
+	if batchSize <= 0 {
+		return errors.Errorf("db: select each %s error, invalid batch size: %d", b.StructName, batchSize)
+	}
+	c := b.columnKeys(condition)
+	var last any
+	for {
+		rows, err := b.selectBatchAfter(ctx, c, last, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		if err = fn(rows); err != nil {
+			return err
+		}
+		if len(rows) < batchSize {
+			return nil
+		}
+		pk, ok := b.pkValue(ctx, rows[len(rows)-1])
+		if !ok {
+			return errors.Errorf("db: select each %s error, primary key is empty", b.StructName)
+		}
+		last = pk
+	}
+}
+
+// Iterate 与 SelectEach 相同，以 range-over-func 的方式逐条返回记录，出错时返回 (nil, err) 并结束
+//
+//	for m, err := range repo.Iterate(ctx, condition, 1000) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (b *BaseRepo[T]) Iterate(ctx context.Context, condition map[string]any, batchSize int) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		errStop := errors.New("stop")
+		err := b.SelectEach(ctx, condition, batchSize, func(rows []*T) error {
+			for _, m := range rows {
+				if !yield(m, nil) {
+					return errStop
+				}
+			}
+			return nil
+		})
+		if err != nil && err != errStop {
+			yield(nil, err)
+		}
+	}
+}
+
+// selectBatchAfter 按主键顺序读取主键大于after的limit条记录，after为nil时从头读取
+func (b *BaseRepo[T]) selectBatchAfter(ctx context.Context, condition map[string]any, after any, limit int) ([]*T, error) {
+	var m T
+	db := b.readDB(ctx)
+	pk := db.Statement.Quote(b.PrimaryKey)
+	query := db.Model(&m).Scopes(b.notDeleted).Scopes(whereCond(condition))
+	if after != nil {
+		query = query.Where(pk+" > ?", after)
+	}
+	res, err := b.find(b.withQueryOptions(ctx, query.Order(pk).Limit(limit)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: select %s batch error, after: %v, condition: %v", b.StructName, after, condition)
+	}
+	return res, nil
+}
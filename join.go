@@ -0,0 +1,184 @@
+package gormx
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm/schema"
+)
+
+// JoinType 联表方式
+type JoinType string
+
+const (
+	InnerJoin JoinType = "INNER JOIN"
+	LeftJoin  JoinType = "LEFT JOIN"
+)
+
+// JoinSpec 联表条件，左表别名为 l，右表别名为 r
+type JoinSpec struct {
+	Type JoinType
+	// 左表列 -> 右表列，兼容驼峰和蛇形，例如 {"id": "user_id"}
+	On map[string]string
+	// 排序，为空时按左表主键排序，例如 "r.create_at DESC"
+	OrderBy string
+}
+
+// JoinRow 联表结果的一行，LEFT JOIN 右表没有匹配的记录时Right为nil
+type JoinRow[L, R any] struct {
+	Left  *L
+	Right *R
+}
+
+// Join 联表查询left和right，结果分别扫描到两个模型
+//
+// 两张表都会过滤软删除的记录，右表的软删除条件放在ON中，不影响 LEFT JOIN 的语义；
+// condition的key可以用 l. 或 r. 指定表，没有前缀时为左表，兼容驼峰和蛇形
+//
+//	rows, err := gormx.Join(ctx, &userRepo, &orderRepo,
+//		gormx.JoinSpec{Type: gormx.LeftJoin, On: map[string]string{"id": "user_id"}},
+//		map[string]any{"l.status": 1, "r.amount": []int{100, 200}})
+func Join[L, R any](ctx context.Context, left *BaseRepo[L], right *BaseRepo[R], spec JoinSpec, condition map[string]any) ([]JoinRow[L, R], error) {
+	ls, err := left.gormSchema()
+	if err != nil {
+		return nil, err
+	}
+	rs, err := right.gormSchema()
+	if err != nil {
+		return nil, err
+	}
+	if spec.Type == "" {
+		spec.Type = InnerJoin
+	}
+	if len(spec.On) == 0 {
+		return nil, errors.Errorf("db: join %s and %s error, join condition is empty", left.StructName, right.StructName)
+	}
+
+	db := left.readDB(ctx)
+	quote := func(s string) string { return db.Statement.Quote(s) }
+	// col 带表别名的列，例如 col("l", "id") 为 `l`.`id`
+	col := func(alias, name string) string { return quote(alias) + "." + quote(name) }
+	lFields, rFields := joinFields(ls), joinFields(rs)
+	columns := make([]string, 0, len(lFields)+len(rFields))
+	for _, f := range lFields {
+		columns = append(columns, col("l", f.DBName)+" AS "+quote("l__"+f.DBName))
+	}
+	for _, f := range rFields {
+		columns = append(columns, col("r", f.DBName)+" AS "+quote("r__"+f.DBName))
+	}
+
+	on := make([]string, 0, len(spec.On)+1)
+	for _, lc := range SortedKeys(spec.On) {
+		on = append(on, col("l", columnName(ls, lc))+" = "+col("r", columnName(rs, spec.On[lc])))
+	}
+	rCond, rArgs := notDeletedSQL(rs, quote, "r")
+	if rCond != "" {
+		on = append(on, rCond)
+	}
+
+	query := db.Table(quote(ls.Table)+" AS "+quote("l")).
+		Select(strings.Join(columns, ", ")).
+		Joins(fmt.Sprintf("%s %s AS %s ON %s", spec.Type, quote(rs.Table), quote("r"), strings.Join(on, " AND ")), rArgs...)
+	if lCond, lArgs := notDeletedSQL(ls, quote, "l"); lCond != "" {
+		query = query.Where(lCond, lArgs...)
+	}
+	for _, k := range SortedKeys(condition) {
+		alias, name, s := "l", k, ls
+		if strings.HasPrefix(k, "r.") {
+			alias, name, s = "r", k[2:], rs
+		} else {
+			name = strings.TrimPrefix(k, "l.")
+		}
+		c := col(alias, columnName(s, name))
+		switch v := condition[k]; {
+		case v == nil:
+			query = query.Where(c + " IS NULL")
+		case reflect.TypeOf(v).Kind() == reflect.Slice && !isBytes(v):
+			query = query.Where(c+" IN ?", v)
+		default:
+			query = query.Where(c+" = ?", v)
+		}
+	}
+	if spec.OrderBy != "" {
+		query = query.Order(spec.OrderBy)
+	} else {
+		query = query.Order(col("l", left.PrimaryKey))
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: join %s and %s error, on: %v, condition: %v", left.StructName, right.StructName, spec.On, condition)
+	}
+	defer rows.Close()
+
+	var res []JoinRow[L, R]
+	values := make([]any, len(columns))
+	for rows.Next() {
+		for i := range values {
+			values[i] = new(any)
+		}
+		if err = rows.Scan(values...); err != nil {
+			return nil, errors.Wrapf(err, "db: join %s and %s error, scan row", left.StructName, right.StructName)
+		}
+		row := JoinRow[L, R]{Left: new(L), Right: new(R)}
+		// L、R 为指针类型（例如 Join[*User, *Order]）时 indirectModel 会分配指向的结构体
+		if _, err = setJoinFields(ctx, indirectModel(reflect.ValueOf(row.Left)), lFields, values[:len(lFields)]); err != nil {
+			return nil, errors.Wrapf(err, "db: join %s and %s error, set %s", left.StructName, right.StructName, left.StructName)
+		}
+		matched, err := setJoinFields(ctx, indirectModel(reflect.ValueOf(row.Right)), rFields, values[len(lFields):])
+		if err != nil {
+			return nil, errors.Wrapf(err, "db: join %s and %s error, set %s", left.StructName, right.StructName, right.StructName)
+		}
+		if !matched {
+			row.Right = nil
+		}
+		res = append(res, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, "db: join %s and %s error", left.StructName, right.StructName)
+	}
+	return res, nil
+}
+
+// columnName 字段名或列名对应的列名，找不到时按蛇形转换
+func columnName(s *schema.Schema, name string) string {
+	if f := s.LookUpField(name); f != nil && f.DBName != "" {
+		return f.DBName
+	}
+	return Camel2Snake(name)
+}
+
+func isBytes(v any) bool {
+	_, ok := v.([]byte)
+	return ok
+}
+
+// joinFields 模型中对应数据库列的字段
+func joinFields(s *schema.Schema) []*schema.Field {
+	fields := make([]*schema.Field, 0, len(s.Fields))
+	for _, f := range s.Fields {
+		if f.DBName != "" && f.Readable {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// setJoinFields 把扫描到的值写入模型，所有值都为NULL时返回false（LEFT JOIN 没有匹配）
+func setJoinFields(ctx context.Context, rv reflect.Value, fields []*schema.Field, values []any) (bool, error) {
+	matched := false
+	for i, f := range fields {
+		v := *values[i].(*any)
+		if v == nil {
+			continue
+		}
+		matched = true
+		if err := f.Set(ctx, rv, v); err != nil {
+			return false, errors.Wrapf(err, "column: %s", f.DBName)
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,169 @@
+package gormx
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JSONCondition JSON列的条件，通过 JSONEq、JSONContains 构造，作为map条件的值使用，map的key只用于区分多个条件：
+//
+//	users, err := repo.SelectByMap(ctx, map[string]any{
+//		"status":   1,
+//		"meta_vip": gormx.JSONEq("meta", "$.type", "vip"),
+//		"meta_tag": gormx.JSONContains("meta", "$.tags", []string{"new"}),
+//	})
+//
+// 按数据库生成对应的SQL，支持 mysql、postgres，JSONEq 还支持 sqlite；使用JSON条件的查询不走查询缓存
+type JSONCondition struct {
+	column   string
+	path     string
+	segments []string
+	value    any
+	contains bool
+	err      error
+}
+
+var jsonKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// JSONEq column列中path对应的值等于value，path为 $.a.b、$.items[0].id 形式
+// 字符串按去掉引号后的文本比较，数字、布尔值按JSON文本比较
+func JSONEq(column, path string, value any) JSONCondition {
+	return newJSONCondition(column, path, value, false)
+}
+
+// JSONContains column列中path对应的JSON包含value，value会序列化为JSON，
+// 例如 JSONContains("meta", "$.tags", []string{"a"}) 匹配tags数组中有"a"的记录
+func JSONContains(column, path string, value any) JSONCondition {
+	return newJSONCondition(column, path, value, true)
+}
+
+func newJSONCondition(column, path string, value any, contains bool) JSONCondition {
+	c := JSONCondition{column: Camel2Snake(column), path: path, value: value, contains: contains}
+	c.segments, c.err = parseJSONPath(path)
+	return c
+}
+
+// parseJSONPath 解析 $.a[0].b 为 [a 0 b]，只支持字母、数字和下划线组成的key
+func parseJSONPath(path string) ([]string, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, errors.Errorf("db: invalid json path: %q, must start with $", path)
+	}
+	var segments []string
+	rest := path[1:]
+	for rest != "" {
+		var seg string
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			seg, rest = rest[1:end+1], rest[end+1:]
+			if !jsonKeyPattern.MatchString(seg) {
+				return nil, errors.Errorf("db: invalid json path: %q, key: %q", path, seg)
+			}
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, errors.Errorf("db: invalid json path: %q, missing ]", path)
+			}
+			seg, rest = rest[1:end], rest[end+1:]
+			if seg == "" || strings.Trim(seg, "0123456789") != "" {
+				return nil, errors.Errorf("db: invalid json path: %q, index: %q", path, seg)
+			}
+		default:
+			return nil, errors.Errorf("db: invalid json path: %q", path)
+		}
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
+
+func (JSONCondition) condExpr() {}
+
+// Build 实现 clause.Expression
+func (c JSONCondition) Build(builder clause.Builder) {
+	stmt, ok := builder.(*gorm.Statement)
+	if !ok {
+		return
+	}
+	if c.err != nil {
+		_ = stmt.AddError(c.err)
+		return
+	}
+	column := clause.Column{Name: c.column}
+	dialect := stmt.Dialector.Name()
+	switch {
+	case c.contains && dialect == "mysql":
+		doc, err := json.Marshal(c.value)
+		if err != nil {
+			_ = stmt.AddError(errors.Wrapf(err, "db: json contains %s error, value: %v", c.column, c.value))
+			return
+		}
+		builder.WriteString("JSON_CONTAINS(")
+		builder.WriteQuoted(column)
+		builder.WriteString(", ")
+		builder.AddVar(builder, string(doc))
+		builder.WriteString(", ")
+		builder.AddVar(builder, c.path)
+		builder.WriteByte(')')
+	case c.contains && dialect == "postgres":
+		doc, err := json.Marshal(c.value)
+		if err != nil {
+			_ = stmt.AddError(errors.Wrapf(err, "db: json contains %s error, value: %v", c.column, c.value))
+			return
+		}
+		builder.WriteByte('(')
+		builder.WriteQuoted(column)
+		builder.WriteString("::jsonb")
+		if len(c.segments) > 0 {
+			builder.WriteString(" #> " + c.pgPath())
+		}
+		builder.WriteString(") @> ")
+		builder.AddVar(builder, string(doc))
+		builder.WriteString("::jsonb")
+	case !c.contains && dialect == "mysql":
+		builder.WriteString("JSON_UNQUOTE(JSON_EXTRACT(")
+		builder.WriteQuoted(column)
+		builder.WriteString(", ")
+		builder.AddVar(builder, c.path)
+		builder.WriteString(")) = ")
+		builder.AddVar(builder, jsonText(c.value))
+	case !c.contains && dialect == "postgres":
+		builder.WriteQuoted(column)
+		builder.WriteString(" #>> " + c.pgPath() + " = ")
+		builder.AddVar(builder, jsonText(c.value))
+	case !c.contains && dialect == "sqlite":
+		// sqlite 的 json_extract 返回原生类型，直接与值比较
+		builder.WriteString("json_extract(")
+		builder.WriteQuoted(column)
+		builder.WriteString(", ")
+		builder.AddVar(builder, c.path)
+		builder.WriteString(") = ")
+		builder.AddVar(builder, c.value)
+	default:
+		_ = stmt.AddError(errors.Errorf("db: json condition on %s is not supported by %s", c.column, dialect))
+	}
+}
+
+// pgPath postgres 的路径数组字面量，例如 '{a,0,b}'，segments已校验只含字母、数字和下划线
+func (c JSONCondition) pgPath() string {
+	return "'{" + strings.Join(c.segments, ",") + "}'"
+}
+
+// jsonText 值对应的JSON文本，字符串不带引号，与 ->> 、JSON_UNQUOTE 的结果一致
+func jsonText(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(bs)
+}
@@ -0,0 +1,171 @@
+package gormx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LeaderLease 选主的租约，每个name一行，Token 在每次换主时加1，作为fencing token
+type LeaderLease struct {
+	Name      string    `gorm:"column:name;size:191;primaryKey"`
+	Holder    string    `gorm:"column:holder;size:128;NOT NULL"`
+	Token     int64     `gorm:"column:token;NOT NULL"`
+	ExpiresAt time.Time `gorm:"column:expires_at;NOT NULL"`
+}
+
+func (LeaderLease) TableName() string {
+	return "gormx_leaders"
+}
+
+// MigrateLeaderLease 创建选主的租约表
+func MigrateLeaderLease(ctx context.Context, db *gorm.DB) error {
+	if err := db.WithContext(ctx).AutoMigrate(&LeaderLease{}); err != nil {
+		return errors.Wrap(err, "db: migrate gormx_leaders error")
+	}
+	return nil
+}
+
+// Elector 基于数据库租约的选主，多副本部署时只有一个实例执行 outbox 轮询、过期数据清理等后台任务：
+//
+//	e := gormx.NewElector(db, "outbox-poller", 15*time.Second)
+//	err := e.Run(ctx, func(ctx context.Context, token int64) error {
+//		return poller.Run(ctx) // 失去leader时ctx被取消
+//	})
+//
+// leader 每 ttl/3 续约一次，续约失败到租约过期前放弃leader并取消fn的ctx，其他实例在租约过期后接任。
+// token 单调递增，写入外部系统时携带token并拒绝比已见过的更小的token，可以防止暂停（GC、网络分区）后恢复的旧leader继续写入
+type Elector struct {
+	db     *gorm.DB
+	name   string
+	id     string
+	ttl    time.Duration
+	leader atomic.Bool
+	token  atomic.Int64
+}
+
+// NewElector ttl 为租约时长，租约表需要提前通过 MigrateLeaderLease 创建
+func NewElector(db *gorm.DB, name string, ttl time.Duration) *Elector {
+	host, _ := os.Hostname()
+	return &Elector{db: db, name: name, ttl: ttl, id: fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())}
+}
+
+// IsLeader 当前是否为leader
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Token 最近一次成为leader时的fencing token
+func (e *Elector) Token() int64 {
+	return e.token.Load()
+}
+
+// Run 持续竞选，成为leader后执行fn，直到ctx取消
+//
+// 失去leader时fn的ctx被取消，fn返回后继续竞选；仍是leader时fn返回，Run 放弃leader并返回fn的结果
+func (e *Elector) Run(ctx context.Context, fn func(ctx context.Context, token int64) error) error {
+	if e.ttl <= 0 {
+		return errors.Errorf("db: elect %s error, invalid ttl: %s", e.name, e.ttl)
+	}
+	interval := e.ttl / 3
+	for {
+		acquired, err := e.acquire(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "gormx: elect leader error", "name", e.name, "error", err)
+		}
+		if acquired {
+			lost, err := e.lead(ctx, interval, fn)
+			if !lost {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// acquire 插入租约行（已存在时忽略），租约过期时抢占并递增token
+func (e *Elector) acquire(ctx context.Context) (bool, error) {
+	db := e.db.WithContext(ctx)
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&LeaderLease{Name: e.name, Holder: "", ExpiresAt: time.Unix(0, 0)}).Error; err != nil {
+		return false, errors.Wrapf(err, "db: init leader lease %s error", e.name)
+	}
+	now := time.Now()
+	tx := db.Model(&LeaderLease{}).Where("name = ? AND expires_at < ?", e.name, now).Updates(map[string]any{
+		"holder":     e.id,
+		"token":      gorm.Expr("token + 1"),
+		"expires_at": now.Add(e.ttl),
+	})
+	if tx.Error != nil || tx.RowsAffected == 0 {
+		return false, errors.Wrapf(tx.Error, "db: acquire leader lease %s error", e.name)
+	}
+	var lease LeaderLease
+	if err := db.Where("name = ? AND holder = ?", e.name, e.id).Take(&lease).Error; err != nil {
+		return false, errors.Wrapf(err, "db: select leader lease %s error", e.name)
+	}
+	e.token.Store(lease.Token)
+	e.leader.Store(true)
+	return true, nil
+}
+
+// lead 执行fn并定期续约，lost 表示因续约失败失去leader
+func (e *Elector) lead(ctx context.Context, interval time.Duration, fn func(ctx context.Context, token int64) error) (lost bool, err error) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	token := e.token.Load()
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(leaderCtx, token)
+	}()
+	expiresAt := time.Now().Add(e.ttl)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err = <-done:
+			e.resign(context.WithoutCancel(ctx), token)
+			return false, err
+		case <-ctx.Done():
+			cancel()
+			if err = <-done; errors.Is(err, ctx.Err()) {
+				err = nil
+			}
+			e.resign(context.WithoutCancel(ctx), token)
+			return false, err
+		case <-ticker.C:
+			now := time.Now()
+			tx := e.db.WithContext(ctx).Model(&LeaderLease{}).Where("name = ? AND holder = ? AND token = ?", e.name, e.id, token).
+				Update("expires_at", now.Add(e.ttl))
+			switch {
+			case tx.Error == nil && tx.RowsAffected == 1:
+				expiresAt = now.Add(e.ttl)
+				continue
+			case tx.Error != nil && now.Add(interval).Before(expiresAt):
+				// 临时错误，租约过期前还可以重试
+				slog.WarnContext(ctx, "gormx: renew leader lease error", "name", e.name, "error", tx.Error)
+				continue
+			}
+			e.leader.Store(false)
+			cancel()
+			<-done
+			return true, nil
+		}
+	}
+}
+
+// resign 主动放弃leader，其他实例不需要等待租约过期
+func (e *Elector) resign(ctx context.Context, token int64) {
+	e.leader.Store(false)
+	_ = e.db.WithContext(ctx).Model(&LeaderLease{}).Where("name = ? AND holder = ? AND token = ?", e.name, e.id, token).
+		Update("expires_at", time.Unix(0, 0)).Error
+}
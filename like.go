@@ -0,0 +1,86 @@
+package gormx
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LikeCondition LIKE 条件，通过 Like、Prefix、Suffix、Contains 构造，作为map条件的值使用，key为列：
+//
+//	users, err := repo.SelectByMap(ctx, map[string]any{"name": gormx.Contains(keyword).IgnoreCase()})
+//
+// value中的 %、_ 会被转义，按字面匹配；使用LIKE条件的查询不走查询缓存
+type LikeCondition struct {
+	pattern string
+	fold    bool
+	column  string
+}
+
+// likeEscape LIKE 的转义字符，不使用反斜杠，避免 mysql 和 postgres 对字符串字面量中反斜杠的处理不一致
+const likeEscape = "!"
+
+var likeEscaper = strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_")
+
+// EscapeLike 转义LIKE模式中的通配符，转义字符为 !，需要配合 ESCAPE '!' 使用
+func EscapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+// Like 列的值等于value，与 = 不同的是可以配合 IgnoreCase 忽略大小写
+func Like(value string) LikeCondition {
+	return LikeCondition{pattern: EscapeLike(value)}
+}
+
+// Prefix 列的值以value开头
+func Prefix(value string) LikeCondition {
+	return LikeCondition{pattern: EscapeLike(value) + "%"}
+}
+
+// Suffix 列的值以value结尾
+func Suffix(value string) LikeCondition {
+	return LikeCondition{pattern: "%" + EscapeLike(value)}
+}
+
+// Contains 列的值包含value
+func Contains(value string) LikeCondition {
+	return LikeCondition{pattern: "%" + EscapeLike(value) + "%"}
+}
+
+// IgnoreCase 忽略大小写，postgres 使用 ILIKE，其他数据库使用 LOWER()
+func (c LikeCondition) IgnoreCase() LikeCondition {
+	c.fold = true
+	return c
+}
+
+func (c LikeCondition) condOn(column string) clause.Expression {
+	c.column = column
+	return c
+}
+
+// Build 实现 clause.Expression
+func (c LikeCondition) Build(builder clause.Builder) {
+	column := clause.Column{Name: c.column}
+	postgres := false
+	if stmt, ok := builder.(*gorm.Statement); ok {
+		postgres = stmt.Dialector.Name() == "postgres"
+	}
+	switch {
+	case !c.fold:
+		builder.WriteQuoted(column)
+		builder.WriteString(" LIKE ")
+		builder.AddVar(builder, c.pattern)
+	case postgres:
+		builder.WriteQuoted(column)
+		builder.WriteString(" ILIKE ")
+		builder.AddVar(builder, c.pattern)
+	default:
+		builder.WriteString("LOWER(")
+		builder.WriteQuoted(column)
+		builder.WriteString(") LIKE LOWER(")
+		builder.AddVar(builder, c.pattern)
+		builder.WriteByte(')')
+	}
+	builder.WriteString(" ESCAPE '" + likeEscape + "'")
+}
@@ -0,0 +1,229 @@
+package gormx
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// ErrLimited 等待并发或速率限制超过 LimiterConfig.MaxWait
+var ErrLimited = errors.New("db: limited")
+
+// LimiterConfig 限流配置
+type LimiterConfig struct {
+	// 同时执行的语句数，为0时不限制并发
+	MaxConcurrent int
+	// 每秒执行的语句数（令牌桶），为0时不限制速率
+	Rate float64
+	// 令牌桶的容量，为0时为 max(1, Rate)
+	Burst int
+	// 最长等待时间，超过时返回 ErrLimited，为0时只受ctx的超时限制
+	MaxWait time.Duration
+}
+
+// LimiterMetrics 限流的累计指标，用于观察等待时间判断限制是否合适
+type LimiterMetrics struct {
+	InFlight int64
+	// 需要等待的语句数和总等待时间
+	Waits       int64
+	WaitTime    time.Duration
+	MaxWaitTime time.Duration
+	// 等待超时被拒绝的语句数
+	Rejected int64
+}
+
+// Limiter 并发和速率限制，例如让使用 BatchInsert 的批处理任务不会占满连接池，影响在线请求：
+//
+//	batch := gormx.NewLimiter(gormx.LimiterConfig{MaxConcurrent: 2, Rate: 50})
+//	repo := gormx.NewBaseRepo[Order](db, gormx.WithLimiter(batch))
+type Limiter struct {
+	cfg LimiterConfig
+	sem chan struct{}
+
+	mu      sync.Mutex
+	tokens  float64
+	last    time.Time
+	metrics LimiterMetrics
+}
+
+// NewLimiter 创建限流器，通过 LimiterPlugin 作用于整个数据源或通过 WithLimiter 作用于单个repo
+func NewLimiter(cfg LimiterConfig) *Limiter {
+	if cfg.Burst <= 0 {
+		cfg.Burst = max(1, int(cfg.Rate))
+	}
+	l := &Limiter{cfg: cfg, tokens: float64(cfg.Burst), last: time.Now()}
+	if cfg.MaxConcurrent > 0 {
+		l.sem = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	return l
+}
+
+// Metrics 累计指标
+func (l *Limiter) Metrics() LimiterMetrics {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.metrics
+}
+
+// reserve 预占一个令牌，返回需要等待的时间
+func (l *Limiter) reserve() time.Duration {
+	if l.cfg.Rate <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens = min(float64(l.cfg.Burst), l.tokens+now.Sub(l.last).Seconds()*l.cfg.Rate)
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.cfg.Rate * float64(time.Second))
+}
+
+// cancelReserve 放弃等待时归还令牌
+func (l *Limiter) cancelReserve() {
+	if l.cfg.Rate <= 0 {
+		return
+	}
+	l.mu.Lock()
+	l.tokens++
+	l.mu.Unlock()
+}
+
+// acquire 等待令牌和并发名额，返回释放并发名额的函数
+func (l *Limiter) acquire(ctx context.Context) (func(), error) {
+	start := time.Now()
+	var deadline <-chan time.Time
+	if l.cfg.MaxWait > 0 {
+		timer := time.NewTimer(l.cfg.MaxWait)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	reject := func(err error) (func(), error) {
+		l.mu.Lock()
+		l.metrics.Rejected++
+		l.mu.Unlock()
+		return nil, err
+	}
+
+	if wait := l.reserve(); wait > 0 {
+		if l.cfg.MaxWait > 0 && wait > l.cfg.MaxWait {
+			l.cancelReserve()
+			return reject(errors.Wrapf(ErrLimited, "rate %.f/s, wait: %s", l.cfg.Rate, wait))
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			l.cancelReserve()
+			return reject(errors.Wrap(ctx.Err(), "db: wait for rate limit"))
+		}
+	}
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		default:
+			select {
+			case l.sem <- struct{}{}:
+			case <-deadline:
+				return reject(errors.Wrapf(ErrLimited, "concurrency %d, wait: %s", l.cfg.MaxConcurrent, time.Since(start)))
+			case <-ctx.Done():
+				return reject(errors.Wrap(ctx.Err(), "db: wait for concurrency limit"))
+			}
+		}
+	}
+
+	waited := time.Since(start)
+	l.mu.Lock()
+	l.metrics.InFlight++
+	if waited > time.Millisecond {
+		l.metrics.Waits++
+		l.metrics.WaitTime += waited
+		l.metrics.MaxWaitTime = max(l.metrics.MaxWaitTime, waited)
+	}
+	l.mu.Unlock()
+	return func() {
+		l.mu.Lock()
+		l.metrics.InFlight--
+		l.mu.Unlock()
+		if l.sem != nil {
+			<-l.sem
+		}
+	}, nil
+}
+
+const (
+	limiterSettingKey = "gormx:limiter"
+	limiterReleaseKey = "gormx:limiter_release"
+)
+
+// WithLimiter 为repo的操作开启限流，需要注册 LimiterPlugin；同时配置了全局限流时两者都生效
+func WithLimiter(l *Limiter) Option {
+	return func(o *options) {
+		o.limiter = l
+	}
+}
+
+// LimiterPlugin gorm插件，Limiter 不为空时限制该db上的所有语句，开启了 WithLimiter 的repo同时受repo的限流器限制：
+//
+//	db.Use(gormx.LimiterPlugin{Limiter: gormx.NewLimiter(gormx.LimiterConfig{MaxConcurrent: 80})})
+//
+// 限制的是单条语句的执行，不限制事务的数量；InTx 内的语句不受限制，
+// 否则持有行锁的事务等待名额、而占用名额的语句在等待该行锁时会互相等到锁超时
+type LimiterPlugin struct {
+	Limiter *Limiter
+}
+
+func (LimiterPlugin) Name() string {
+	return "gormx:limiter"
+}
+
+func (p LimiterPlugin) Initialize(db *gorm.DB) error {
+	return registerAround(db, "gormx:limiter", p.before, p.after)
+}
+
+func (p LimiterPlugin) before(db *gorm.DB) {
+	ctx := db.Statement.Context
+	if db.Error != nil || db.DryRun {
+		return
+	}
+	if _, inTx := ctx.Value(contextTxKey{}).(*gorm.DB); inTx {
+		return
+	}
+	var limiters []*Limiter
+	if p.Limiter != nil {
+		limiters = append(limiters, p.Limiter)
+	}
+	if v, ok := db.Get(limiterSettingKey); ok && v.(*Limiter) != p.Limiter {
+		limiters = append(limiters, v.(*Limiter))
+	}
+	var releases []func()
+	for _, l := range limiters {
+		release, err := l.acquire(ctx)
+		if err != nil {
+			for _, r := range releases {
+				r()
+			}
+			_ = db.AddError(err)
+			return
+		}
+		releases = append(releases, release)
+	}
+	if len(releases) > 0 {
+		db.InstanceSet(limiterReleaseKey, releases)
+	}
+}
+
+func (LimiterPlugin) after(db *gorm.DB) {
+	if v, ok := db.InstanceGet(limiterReleaseKey); ok {
+		for _, release := range v.([]func()) {
+			release()
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package gormx
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm/schema"
+)
+
+// ErrInvalidListQuery 列表查询参数不合法，HTTP接口通常返回400
+var ErrInvalidListQuery = errors.New("db: invalid list query")
+
+// ListQueryOptions ParseListQuery 的选项
+type ListQueryOptions struct {
+	// 允许过滤的列（字段名或列名），为空时不允许过滤
+	Filterable []string
+	// 允许排序的列，为空时不允许排序
+	Sortable []string
+	// 默认排序，例如 "-create_at"，由服务端指定，可以使用模型的任意列
+	DefaultSort string
+	// size 的默认值和最大值，为0时分别为20和100
+	DefaultSize int32
+	MaxSize     int32
+}
+
+// listQueryOps 按长度从长到短匹配
+var listQueryOps = []string{"!=", ">=", "<=", "~=", "^=", "=", ">", "<"}
+
+// ParseListQuery 把REST列表接口的查询参数转换为条件和 PageParam，列按模型T校验，只允许白名单中的列：
+//
+//	?filter=age>=18,name~=zhang,status=1|2&sort=-create_at,id&page=2&size=20
+//
+//	condition, page, err := repo.ParseListQuery(r.URL.Query(), gormx.ListQueryOptions{
+//		Filterable: []string{"age", "name", "status"},
+//		Sortable:   []string{"create_at", "id"},
+//	})
+//	users, total, err := repo.PageSelect(ctx, page, condition)
+//
+// filter 的操作符：= 等于（值中的 | 分隔多个值为 IN）、!= 不等于、> >= < <= 比较、~= 包含、^= 前缀；
+// 多个条件以 , 分隔，也可以有多个 filter 参数；值按列的类型转换，转换失败返回 ErrInvalidListQuery。
+// sort 以 , 分隔，- 开头为降序
+func (b *BaseRepo[T]) ParseListQuery(query url.Values, opts ListQueryOptions) (map[string]any, *PageParam, error) {
+	s, err := b.gormSchema()
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "parse list query")
+	}
+	filterable, err := allowedColumns(s, opts.Filterable)
+	if err != nil {
+		return nil, nil, err
+	}
+	sortable, err := allowedColumns(s, opts.Sortable)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	condition := make(map[string]any)
+	for _, filter := range query["filter"] {
+		for _, item := range strings.Split(filter, ",") {
+			if strings.TrimSpace(item) == "" {
+				continue
+			}
+			c, err := parseListFilter(s, filterable, item)
+			if err != nil {
+				return nil, nil, err
+			}
+			condition[c.Key] = c.Value
+		}
+	}
+
+	page := &PageParam{PageNo: 1, PageSize: opts.DefaultSize}
+	if page.PageSize <= 0 {
+		page.PageSize = 20
+	}
+	maxSize := opts.MaxSize
+	if maxSize <= 0 {
+		maxSize = 100
+	}
+	if v := query.Get("page"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || n < 1 {
+			return nil, nil, errors.Wrapf(ErrInvalidListQuery, "page: %s", v)
+		}
+		page.PageNo = int32(n)
+	}
+	if v := query.Get("size"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || n < 1 || int32(n) > maxSize {
+			return nil, nil, errors.Wrapf(ErrInvalidListQuery, "size: %s, max: %d", v, maxSize)
+		}
+		page.PageSize = int32(n)
+	}
+	sortSpec := query.Get("sort")
+	if sortSpec == "" {
+		if sortable, err = allowedColumns(s, s.DBNames); err != nil {
+			return nil, nil, err
+		}
+		sortSpec = opts.DefaultSort
+	}
+	if page.OrderBy, err = parseListSort(s, sortable, sortSpec); err != nil {
+		return nil, nil, err
+	}
+	return condition, page, nil
+}
+
+// allowedColumns 白名单中的列，names为空时不允许任何列
+func allowedColumns(s *schema.Schema, names []string) (map[string]struct{}, error) {
+	allowed := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		f := schemaColumn(s, name)
+		if f == nil {
+			return nil, errors.Wrapf(ErrUnknownColumn, "db: %s list query column: %s", s.Name, name)
+		}
+		allowed[f.DBName] = struct{}{}
+	}
+	return allowed, nil
+}
+
+func listColumn(s *schema.Schema, allowed map[string]struct{}, name string) (*schema.Field, error) {
+	f := schemaColumn(s, strings.TrimSpace(name))
+	if f == nil {
+		return nil, errors.Wrapf(ErrInvalidListQuery, "unknown field: %s", name)
+	}
+	if _, ok := allowed[f.DBName]; !ok {
+		return nil, errors.Wrapf(ErrInvalidListQuery, "field not allowed: %s", name)
+	}
+	return f, nil
+}
+
+func parseListFilter(s *schema.Schema, allowed map[string]struct{}, item string) (Cond, error) {
+	idx, op := -1, ""
+	for i := 0; i < len(item) && idx < 0; i++ {
+		for _, candidate := range listQueryOps {
+			if strings.HasPrefix(item[i:], candidate) {
+				idx, op = i, candidate
+				break
+			}
+		}
+	}
+	if idx <= 0 {
+		return Cond{}, errors.Wrapf(ErrInvalidListQuery, "filter: %s", item)
+	}
+	f, err := listColumn(s, allowed, item[:idx])
+	if err != nil {
+		return Cond{}, err
+	}
+	raw := item[idx+len(op):]
+	switch op {
+	case "~=":
+		return Cond{Key: f.DBName, Value: Contains(raw)}, nil
+	case "^=":
+		return Cond{Key: f.DBName, Value: Prefix(raw)}, nil
+	case "=":
+		if strings.Contains(raw, "|") {
+			var values []any
+			for _, part := range strings.Split(raw, "|") {
+				v, err := listValue(f, part)
+				if err != nil {
+					return Cond{}, err
+				}
+				values = append(values, v)
+			}
+			return Cond{Key: f.DBName, Value: values}, nil
+		}
+	}
+	v, err := listValue(f, raw)
+	if err != nil {
+		return Cond{}, err
+	}
+	if op == "=" {
+		return Cond{Key: f.DBName, Value: v}, nil
+	}
+	if op == "!=" {
+		op = "<>"
+	}
+	return compareCond(f.DBName, op, v), nil
+}
+
+// listValue 按字段的类型转换查询参数中的值
+func listValue(f *schema.Field, raw string) (any, error) {
+	rv := reflect.New(f.Schema.ModelType).Elem()
+	if err := f.Set(context.Background(), rv, raw); err != nil {
+		return nil, errors.Wrapf(ErrInvalidListQuery, "field %s value: %s", f.DBName, raw)
+	}
+	return f.ReflectValueOf(context.Background(), rv).Interface(), nil
+}
+
+func parseListSort(s *schema.Schema, allowed map[string]struct{}, spec string) (string, error) {
+	var orders []Order
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		desc := strings.HasPrefix(item, "-")
+		f, err := listColumn(s, allowed, strings.TrimPrefix(strings.TrimPrefix(item, "-"), "+"))
+		if err != nil {
+			return "", err
+		}
+		orders = append(orders, Order{column: f.DBName, desc: desc})
+	}
+	return OrderBy(orders...), nil
+}
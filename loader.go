@@ -0,0 +1,132 @@
+package gormx
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultLoaderWait     = 2 * time.Millisecond
+	DefaultLoaderMaxBatch = 500
+)
+
+// Loader 合并短时间窗口内的 LoadByPK 调用，用一条 WHERE pk IN (...) 查询返回给所有调用方，避免N+1查询
+//
+// 一个批次使用触发该批次的第一个调用方的ctx（忽略其取消）执行查询；需要按请求隔离时，每个请求创建一个 Loader
+type Loader[T any] struct {
+	repo     *BaseRepo[T]
+	wait     time.Duration
+	maxBatch int
+
+	mu    sync.Mutex
+	batch *loaderBatch[T]
+}
+
+type loaderBatch[T any] struct {
+	ctx  context.Context
+	pks  []any
+	keys map[string]struct{}
+	done chan struct{}
+	res  map[string]*T
+	err  error
+}
+
+// NewLoader wait<=0 时使用 DefaultLoaderWait，maxBatch<=0 时使用 DefaultLoaderMaxBatch
+func NewLoader[T any](repo *BaseRepo[T], wait time.Duration, maxBatch int) *Loader[T] {
+	if wait <= 0 {
+		wait = DefaultLoaderWait
+	}
+	if maxBatch <= 0 {
+		maxBatch = DefaultLoaderMaxBatch
+	}
+	return &Loader[T]{repo: repo, wait: wait, maxBatch: maxBatch}
+}
+
+// LoadByPK 根据主键查找，记录不存在时返回nil
+func (l *Loader[T]) LoadByPK(ctx context.Context, pk any) (*T, error) {
+	batch := l.add(ctx, pk)
+	select {
+	case <-batch.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if batch.err != nil {
+		return nil, batch.err
+	}
+	return batch.res[cacheKeyPart(pk)], nil
+}
+
+// LoadManyByPK 按pks的顺序返回结果，不存在的记录对应位置为nil
+func (l *Loader[T]) LoadManyByPK(ctx context.Context, pks []any) ([]*T, error) {
+	batches := make([]*loaderBatch[T], len(pks))
+	for i, pk := range pks {
+		batches[i] = l.add(ctx, pk)
+	}
+	res := make([]*T, len(pks))
+	for i, batch := range batches {
+		select {
+		case <-batch.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if batch.err != nil {
+			return nil, batch.err
+		}
+		res[i] = batch.res[cacheKeyPart(pks[i])]
+	}
+	return res, nil
+}
+
+func (l *Loader[T]) add(ctx context.Context, pk any) *loaderBatch[T] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	batch := l.batch
+	if batch == nil {
+		batch = &loaderBatch[T]{
+			// 第一个调用方取消时不影响同批次的其他调用方
+			ctx:  context.WithoutCancel(ctx),
+			keys: make(map[string]struct{}),
+			done: make(chan struct{}),
+		}
+		l.batch = batch
+		time.AfterFunc(l.wait, func() { l.dispatch(batch) })
+	}
+	key := cacheKeyPart(pk)
+	if _, ok := batch.keys[key]; !ok {
+		batch.keys[key] = struct{}{}
+		batch.pks = append(batch.pks, pk)
+	}
+	if len(batch.pks) >= l.maxBatch {
+		l.batch = nil
+		go l.run(batch)
+	}
+	return batch
+}
+
+// dispatch 窗口到期时执行批次，批次已因达到maxBatch提前执行时忽略
+func (l *Loader[T]) dispatch(batch *loaderBatch[T]) {
+	l.mu.Lock()
+	if l.batch != batch {
+		l.mu.Unlock()
+		return
+	}
+	l.batch = nil
+	l.mu.Unlock()
+	l.run(batch)
+}
+
+func (l *Loader[T]) run(batch *loaderBatch[T]) {
+	defer close(batch.done)
+	rows, err := l.repo.SelectByPK(batch.ctx, batch.pks)
+	if err != nil {
+		batch.err = err
+		return
+	}
+	batch.res = make(map[string]*T, len(rows))
+	for _, row := range rows {
+		if pk, ok := l.repo.pkValue(batch.ctx, row); ok {
+			batch.res[cacheKeyPart(pk)] = row
+		}
+	}
+}
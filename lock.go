@@ -0,0 +1,82 @@
+package gormx
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotInTx 加锁读必须在事务内执行，否则语句结束后锁立即释放
+var ErrNotInTx = errors.New("db: locking read must run inside a transaction")
+
+// LockOption 加锁读在锁冲突时的行为
+type LockOption string
+
+const (
+	// LockNoWait 锁冲突时立即报错
+	LockNoWait LockOption = clause.LockingOptionsNoWait
+	// LockSkipLocked 跳过已被锁定的记录
+	LockSkipLocked LockOption = clause.LockingOptionsSkipLocked
+)
+
+// SelectOneByPKForUpdate 根据主键查找并加排他锁（SELECT ... FOR UPDATE），只能在 InTx 内调用
+func (b *BaseRepo[T]) SelectOneByPKForUpdate(ctx context.Context, pk any, opts ...LockOption) (*T, error) {
+	if err := b.singlePK(); err != nil {
+		return nil, errors.WithMessage(err, "select one by pk for update")
+	}
+	return b.selectOneLocked(ctx, clause.LockingStrengthUpdate, map[string]any{b.PrimaryKey: pk}, opts)
+}
+
+// SelectOneByMapForUpdate 根据条件查找并加排他锁，只能在 InTx 内调用
+func (b *BaseRepo[T]) SelectOneByMapForUpdate(ctx context.Context, condition map[string]any, opts ...LockOption) (*T, error) {
+	return b.selectOneLocked(ctx, clause.LockingStrengthUpdate, b.columnKeys(condition), opts)
+}
+
+// SelectByMapForUpdate 根据条件查找并加排他锁，只能在 InTx 内调用
+func (b *BaseRepo[T]) SelectByMapForUpdate(ctx context.Context, condition map[string]any, opts ...LockOption) ([]*T, error) {
+	return b.selectLocked(ctx, clause.LockingStrengthUpdate, b.columnKeys(condition), opts)
+}
+
+// SelectOneByPKForShare 根据主键查找并加共享锁（SELECT ... FOR SHARE，mysql 8.0+），只能在 InTx 内调用
+func (b *BaseRepo[T]) SelectOneByPKForShare(ctx context.Context, pk any, opts ...LockOption) (*T, error) {
+	if err := b.singlePK(); err != nil {
+		return nil, errors.WithMessage(err, "select one by pk for share")
+	}
+	return b.selectOneLocked(ctx, clause.LockingStrengthShare, map[string]any{b.PrimaryKey: pk}, opts)
+}
+
+// SelectByMapForShare 根据条件查找并加共享锁，只能在 InTx 内调用
+func (b *BaseRepo[T]) SelectByMapForShare(ctx context.Context, condition map[string]any, opts ...LockOption) ([]*T, error) {
+	return b.selectLocked(ctx, clause.LockingStrengthShare, b.columnKeys(condition), opts)
+}
+
+func (b *BaseRepo[T]) selectOneLocked(ctx context.Context, strength string, condition map[string]any, opts []LockOption) (*T, error) {
+	res, err := b.selectLocked(ctx, strength, condition, opts)
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+	if len(res) > 1 {
+		return nil, errors.Errorf("db: select one %s for %s error, result must be one, now it is %d, condition %+v", b.StructName, strength, len(res), condition)
+	}
+	return res[0], nil
+}
+
+func (b *BaseRepo[T]) selectLocked(ctx context.Context, strength string, condition map[string]any, opts []LockOption) ([]*T, error) {
+	if _, ok := ctx.Value(contextTxKey{}).(*gorm.DB); !ok {
+		return nil, errors.Wrapf(ErrNotInTx, "db: select %s for %s error", b.StructName, strength)
+	}
+	locking := clause.Locking{Strength: strength}
+	for _, opt := range opts {
+		locking.Options = string(opt)
+	}
+	var m T
+	// readDB 在事务内返回ctx中的事务，与其他读操作一样带上repo配置、Debug、DryRun 和 preflight
+	query := b.readDB(ctx).Model(&m).Scopes(b.notDeleted).Scopes(whereCond(condition)).Clauses(locking)
+	res, err := b.find(b.withQueryOptions(ctx, query))
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: select %s for %s error, condition: %+v", b.StructName, strength, condition)
+	}
+	return res, nil
+}
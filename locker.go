@@ -0,0 +1,145 @@
+package gormx
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// ErrLockNotAcquired 锁被其他进程持有
+var ErrLockNotAcquired = errors.New("db: lock not acquired")
+
+// DistributedLock 表锁方式的锁记录，ExpiresAt 之后的记录视为已释放
+type DistributedLock struct {
+	Key       string    `gorm:"column:lock_key;size:191;primaryKey"`
+	Owner     string    `gorm:"column:owner;size:128;NOT NULL"`
+	ExpiresAt time.Time `gorm:"column:expires_at;NOT NULL"`
+}
+
+func (DistributedLock) TableName() string {
+	return "gormx_locks"
+}
+
+// Locker 基于数据库的分布式锁，用于通过同一个库协调的定时任务互斥，不需要为此引入Redis：
+//
+//	locker := gormx.NewLocker(db)
+//	err := locker.WithLock(ctx, "purge-expired-orders", 10*time.Minute, func(ctx context.Context) error {
+//		return purge(ctx)
+//	})
+//	if errors.Is(err, gormx.ErrLockNotAcquired) {
+//		// 其他实例正在执行
+//	}
+//
+// mysql 使用 GET_LOCK，postgres 使用 pg_try_advisory_lock，锁绑定在一个独占的连接上，进程崩溃时随连接自动释放；
+// 其他数据库（或 NewTableLocker）使用 gormx_locks 表，进程崩溃时锁在ttl后过期，依赖各实例的时钟基本一致
+type Locker struct {
+	db      *gorm.DB
+	table   bool
+	owner   string
+	seq     atomic.Int64
+	migrate func() error
+}
+
+// NewLocker 按数据库类型选择加锁方式
+func NewLocker(db *gorm.DB) *Locker {
+	name := db.Dialector.Name()
+	return newLocker(db, name != "mysql" && name != "postgres")
+}
+
+// NewTableLocker 总是使用 gormx_locks 表加锁，例如经过不支持会话级锁的代理（PgBouncer事务模式）连接数据库时
+func NewTableLocker(db *gorm.DB) *Locker {
+	return newLocker(db, true)
+}
+
+func newLocker(db *gorm.DB, table bool) *Locker {
+	host, _ := os.Hostname()
+	l := &Locker{db: db, table: table, owner: fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())}
+	l.migrate = sync.OnceValue(func() error {
+		return db.AutoMigrate(&DistributedLock{})
+	})
+	return l
+}
+
+// WithLock 不等待地尝试加锁，锁被持有时返回 ErrLockNotAcquired；加锁成功后执行fn，fn返回后释放锁
+//
+// fn的ctx在ttl后超时，fn需要在ttl内结束，避免表锁过期后被其他进程同时持有
+func (l *Locker) WithLock(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	if ttl <= 0 {
+		return errors.Errorf("db: lock %s error, invalid ttl: %s", key, ttl)
+	}
+	if l.table {
+		return l.withTableLock(ctx, key, ttl, fn)
+	}
+	// 会话级的锁必须在同一个连接上加锁和释放，与ctx中的事务无关
+	return l.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		var acquire, release string
+		var arg any
+		if l.db.Dialector.Name() == "postgres" {
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(key))
+			acquire, release, arg = "SELECT pg_try_advisory_lock(?)", "SELECT pg_advisory_unlock(?)", int64(h.Sum64())
+		} else {
+			acquire, release, arg = "SELECT GET_LOCK(?, 0)", "SELECT RELEASE_LOCK(?)", mysqlLockName(key)
+		}
+		var ok sql.NullBool
+		if err := conn.Raw(acquire, arg).Scan(&ok).Error; err != nil {
+			return errors.Wrapf(err, "db: lock %s error", key)
+		}
+		if !ok.Bool {
+			return errors.Wrapf(ErrLockNotAcquired, "key: %s", key)
+		}
+		defer func() {
+			var released sql.NullBool
+			_ = conn.WithContext(context.WithoutCancel(ctx)).Raw(release, arg).Scan(&released).Error
+		}()
+		fnCtx, cancel := context.WithTimeout(ctx, ttl)
+		defer cancel()
+		return fn(fnCtx)
+	})
+}
+
+// mysqlLockName GET_LOCK 的名称最长64个字符，超过时使用哈希
+func mysqlLockName(key string) string {
+	if len(key) <= 64 {
+		return key
+	}
+	sum := sha1.Sum([]byte(key))
+	return "gormx:" + hex.EncodeToString(sum[:])
+}
+
+func (l *Locker) withTableLock(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	if err := l.migrate(); err != nil {
+		return errors.Wrap(err, "db: migrate gormx_locks error")
+	}
+	db := l.db.WithContext(ctx)
+	// 每次加锁使用不同的owner，同一进程内的锁过期后被重新持有时不会被前一次释放
+	owner := fmt.Sprintf("%s-%d", l.owner, l.seq.Add(1))
+	now := time.Now()
+	if err := db.Where("lock_key = ? AND expires_at < ?", key, now).Delete(&DistributedLock{}).Error; err != nil {
+		return errors.Wrapf(err, "db: lock %s error, delete expired", key)
+	}
+	if err := db.Create(&DistributedLock{Key: key, Owner: owner, ExpiresAt: now.Add(ttl)}).Error; err != nil {
+		if IsDuplicateKey(err) {
+			return errors.Wrapf(ErrLockNotAcquired, "key: %s", key)
+		}
+		return errors.Wrapf(err, "db: lock %s error", key)
+	}
+	defer func() {
+		// 只删除自己持有的锁，锁过期后已被其他进程持有时不删除
+		_ = l.db.WithContext(context.WithoutCancel(ctx)).
+			Where("lock_key = ? AND owner = ?", key, owner).Delete(&DistributedLock{}).Error
+	}()
+	fnCtx, cancel := context.WithTimeout(ctx, ttl)
+	defer cancel()
+	return fn(fnCtx)
+}
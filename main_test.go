@@ -0,0 +1,15 @@
+package gormx_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+
+	"github/flandersRin/gormx/gormxtest"
+)
+
+func TestMain(m *testing.M) {
+	gormxtest.SQLite = sqlite.Open
+	os.Exit(m.Run())
+}
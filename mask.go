@@ -0,0 +1,198 @@
+package gormx
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const maskingPluginName = "gormx:masking"
+
+type contextMaskKey struct{}
+
+// WithMasking 标记ctx的调用方为非特权调用方，查询结果中带有 `gormx:"mask:xxx"` 标签的字段会被脱敏，
+// 例如列表接口在中间件中设置；不设置时（内部任务等）返回原值。需要注册 MaskingPlugin
+func WithMasking(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextMaskKey{}, true)
+}
+
+// withoutMasking 回源写缓存时使用，保证缓存中是原值
+func withoutMasking(ctx context.Context) context.Context {
+	if !maskingFromCtx(ctx) {
+		return ctx
+	}
+	return context.WithValue(ctx, contextMaskKey{}, false)
+}
+
+func maskingFromCtx(ctx context.Context) bool {
+	v, _ := ctx.Value(contextMaskKey{}).(bool)
+	return v
+}
+
+// Masker 脱敏函数
+type Masker func(s string) string
+
+// MaskKeep 保留前prefix个和后suffix个字符，其余替换为*，长度不够时全部替换
+func MaskKeep(prefix, suffix int) Masker {
+	return func(s string) string {
+		n := utf8.RuneCountInString(s)
+		if n <= prefix+suffix {
+			return strings.Repeat("*", n)
+		}
+		r := []rune(s)
+		return string(r[:prefix]) + strings.Repeat("*", n-prefix-suffix) + string(r[n-suffix:])
+	}
+}
+
+// 内置的脱敏规则
+var defaultMaskers = map[string]Masker{
+	"phone":  MaskKeep(3, 4), // 13812341234 -> 138****1234
+	"idcard": MaskKeep(6, 4), // 110101199001011234 -> 110101********1234
+	"name":   MaskKeep(1, 0), // 张三丰 -> 张**
+	"email":  maskEmail,      // zhangsan@example.com -> z***@example.com
+	"all":    MaskKeep(0, 0),
+}
+
+func maskEmail(s string) string {
+	at := strings.LastIndex(s, "@")
+	if at <= 0 {
+		return MaskKeep(1, 0)(s)
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return string(r) + "***" + s[at:]
+}
+
+// MaskingPlugin gorm插件，ctx经过 WithMasking 时对查询结果中带有 `gormx:"mask:规则"` 标签的string字段脱敏
+//
+//	db.Use(gormx.MaskingPlugin{})
+//
+//	type User struct {
+//		Phone string `gormx:"mask:phone"`
+//	}
+//
+//	users, err := repo.SelectByMap(gormx.WithMasking(ctx), condition) // Phone 为 138****1234
+//
+// 内置规则有 phone、idcard、name、email、all，Maskers 中的同名规则覆盖内置规则，未知的规则按 all 处理；
+// 开启缓存时缓存中是原值，命中缓存时同样脱敏；同时使用 EncryptionPlugin 时需要先注册 EncryptionPlugin。
+// 脱敏后的记录不要再用于更新，否则会把脱敏后的值写回数据库
+type MaskingPlugin struct {
+	Maskers map[string]Masker
+}
+
+func (MaskingPlugin) Name() string {
+	return maskingPluginName
+}
+
+func (p MaskingPlugin) Initialize(db *gorm.DB) error {
+	return db.Callback().Query().After("gorm:query").Register(maskingPluginName, p.afterQuery)
+}
+
+// maskingPlugin db上注册的 MaskingPlugin，db.Use(MaskingPlugin{}) 和 db.Use(&MaskingPlugin{}) 都支持
+func maskingPlugin(db *gorm.DB) (MaskingPlugin, bool) {
+	switch p := db.Config.Plugins[maskingPluginName].(type) {
+	case MaskingPlugin:
+		return p, true
+	case *MaskingPlugin:
+		if p != nil {
+			return *p, true
+		}
+	}
+	return MaskingPlugin{}, false
+}
+
+func (p MaskingPlugin) masker(name string) (Masker, bool) {
+	if m, ok := p.Maskers[name]; ok {
+		return m, true
+	}
+	m, ok := defaultMaskers[name]
+	return m, ok
+}
+
+func (p MaskingPlugin) afterQuery(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || !maskingFromCtx(stmt.Context) {
+		return
+	}
+	if err := p.mask(stmt.Context, stmt.Schema, stmt.ReflectValue); err != nil {
+		_ = db.AddError(err)
+	}
+}
+
+var maskedFieldsCache sync.Map // *schema.Schema -> map[*schema.Field]string
+
+// maskedFields 模型中需要脱敏的字段及其规则
+func maskedFields(s *schema.Schema) map[*schema.Field]string {
+	if v, ok := maskedFieldsCache.Load(s); ok {
+		return v.(map[*schema.Field]string)
+	}
+	fields := make(map[*schema.Field]string)
+	for _, field := range s.Fields {
+		if rule, ok := gormxTagSettings(field)["MASK"]; ok && field.DBName != "" {
+			fields[field] = rule
+		}
+	}
+	maskedFieldsCache.Store(s, fields)
+	return fields
+}
+
+func (p MaskingPlugin) mask(ctx context.Context, s *schema.Schema, rv reflect.Value) error {
+	return p.maskFields(ctx, maskedFields(s), rv)
+}
+
+// maskFields 按字段的规则脱敏，fields 中的字段属于rv中的记录的类型
+func (p MaskingPlugin) maskFields(ctx context.Context, fields map[*schema.Field]string, rv reflect.Value) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	var err error
+	forEachRow(rv, func(row reflect.Value) {
+		for field, rule := range fields {
+			if err != nil {
+				return
+			}
+			m, ok := p.masker(rule)
+			if !ok {
+				m = defaultMaskers["all"]
+			}
+			v, zero := field.ValueOf(ctx, row)
+			if zero {
+				continue
+			}
+			switch str := v.(type) {
+			case string:
+				err = field.Set(ctx, row, m(str))
+			case *string:
+				err = field.Set(ctx, row, m(*str))
+			}
+		}
+	})
+	return err
+}
+
+// maskCached 对从缓存中取出的记录脱敏，缓存中保存的是原值
+func (b *BaseRepo[T]) maskCached(ctx context.Context, rows ...*T) error {
+	if !maskingFromCtx(ctx) {
+		return nil
+	}
+	p, ok := maskingPlugin(b.GormDB)
+	if !ok {
+		return nil
+	}
+	s, err := b.gormSchema()
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if row != nil {
+			if err = p.mask(ctx, s, indirectModel(reflect.ValueOf(row))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
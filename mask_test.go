@@ -0,0 +1,112 @@
+package gormx_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github/flandersRin/gormx"
+	"github/flandersRin/gormx/gormxtest"
+)
+
+type maskUser struct {
+	ID    int64  `gorm:"primaryKey"`
+	Phone string `gormx:"mask:phone"`
+}
+
+func TestMaskingCached(t *testing.T) {
+	tests := []struct {
+		name   string
+		plugin gorm.Plugin
+	}{
+		{"value", gormx.MaskingPlugin{}},
+		{"pointer", &gormx.MaskingPlugin{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := gormxtest.NewSQLite(t, &maskUser{})
+			if err := db.Use(tt.plugin); err != nil {
+				t.Fatal(err)
+			}
+			repo := gormx.NewBaseRepo[maskUser](db, gormx.WithCache(gormx.NewMemoryCache(), time.Minute))
+			ctx := context.Background()
+			if err := repo.Insert(ctx, &maskUser{ID: 1, Phone: "13812341234"}); err != nil {
+				t.Fatal(err)
+			}
+			// 第一次回源写缓存，第二次命中缓存，两次都需要脱敏
+			for i := 0; i < 2; i++ {
+				u, err := repo.SelectOneByPK(gormx.WithMasking(ctx), 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if u.Phone != "138****1234" {
+					t.Errorf("read %d: phone %s", i, u.Phone)
+				}
+			}
+			u, err := repo.SelectOneByPK(ctx, 1)
+			if err != nil || u.Phone != "13812341234" {
+				t.Errorf("unmasked: %+v, %v", u, err)
+			}
+		})
+	}
+}
+
+// 合并回源的调用方各自得到独立的记录，一个调用方脱敏不影响其他调用方
+func TestMaskingSingleflight(t *testing.T) {
+	db := gormxtest.NewSQLite(t, &maskUser{})
+	if err := db.Use(gormx.MaskingPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	// 回源变慢，保证并发的调用方合并为一次查询
+	if err := db.Callback().Query().Before("gorm:query").Register("test:slow", func(*gorm.DB) {
+		time.Sleep(20 * time.Millisecond)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	repo := gormx.NewBaseRepo[*maskUser](db, gormx.WithCache(gormx.NewMemoryCache(), time.Minute), gormx.WithCacheSingleflight())
+	ctx := context.Background()
+	u := &maskUser{ID: 1, Phone: "13812341234"}
+	if err := repo.Insert(ctx, &u); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 8
+	var (
+		wg   sync.WaitGroup
+		rows [n]**maskUser
+		errs [n]error
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := ctx
+			if i%2 == 0 {
+				c = gormx.WithMasking(ctx)
+			}
+			rows[i], errs[i] = repo.SelectOneByPK(c, 1)
+		}(i)
+	}
+	wg.Wait()
+	seen := make(map[*maskUser]bool)
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		row := *rows[i]
+		if seen[row] {
+			t.Errorf("caller %d shares a row with another caller", i)
+		}
+		seen[row] = true
+		want := "13812341234"
+		if i%2 == 0 {
+			want = "138****1234"
+		}
+		if row.Phone != want {
+			t.Errorf("caller %d: phone %s, want %s", i, row.Phone, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package gormx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaterializedResult MaterializeQuery 保存的查询结果，按 名称+参数哈希 唯一
+type MaterializedResult struct {
+	ID         int64     `gorm:"column:id;primaryKey;autoIncrement"`
+	Name       string    `gorm:"column:name;size:128;NOT NULL;uniqueIndex:uk_name_params"`
+	ParamsHash string    `gorm:"column:params_hash;size:64;NOT NULL;uniqueIndex:uk_name_params"`
+	Payload    []byte    `gorm:"column:payload"`
+	ExpireAt   time.Time `gorm:"column:expire_at;NOT NULL;index"`
+	CreateAt   time.Time `gorm:"column:create_at;NOT NULL"`
+}
+
+func (MaterializedResult) TableName() string {
+	return "gormx_materialized_results"
+}
+
+// MigrateMaterializedResults 创建 MaterializeQuery 使用的结果表
+func MigrateMaterializedResults(db *gorm.DB) error {
+	if err := db.AutoMigrate(&MaterializedResult{}); err != nil {
+		return errors.Wrap(err, "db: migrate materialized results error")
+	}
+	return nil
+}
+
+// MaterializeQuery 执行耗时查询并把结果保存到结果表，ttl内相同名称和参数的请求直接读取保存的结果
+//
+// 适用于报表等可以接受一定延迟的幂等查询，结果以json保存，R需要可以被json序列化
+func MaterializeQuery[R any](ctx context.Context, db *gorm.DB, name string, ttl time.Duration, sql string, args ...any) ([]R, error) {
+	raw, err := json.Marshal(append([]any{sql}, args...))
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: materialize %s error, marshal params", name)
+	}
+	sum := sha256.Sum256(raw)
+	hash := hex.EncodeToString(sum[:])
+
+	tx := dbWithCtx(ctx, db)
+	var saved []MaterializedResult
+	if err = tx.Where("name = ? AND params_hash = ? AND expire_at > ?", name, hash, time.Now()).Limit(1).Find(&saved).Error; err != nil {
+		return nil, errors.Wrapf(err, "db: materialize %s error, select saved result", name)
+	}
+	var res []R
+	if len(saved) > 0 {
+		if err = json.Unmarshal(saved[0].Payload, &res); err == nil {
+			return res, nil
+		}
+	}
+
+	if err = tx.Raw(sql, args...).Scan(&res).Error; err != nil {
+		return nil, errors.Wrapf(err, "db: materialize %s error, sql: %s, args: %+v", name, sql, args)
+	}
+	payload, err := json.Marshal(res)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: materialize %s error, marshal result", name)
+	}
+	now := time.Now()
+	row := MaterializedResult{Name: name, ParamsHash: hash, Payload: payload, ExpireAt: now.Add(ttl), CreateAt: now}
+	if err = tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}, {Name: "params_hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"payload", "expire_at", "create_at"}),
+	}).Create(&row).Error; err != nil {
+		return nil, errors.Wrapf(err, "db: materialize %s error, save result", name)
+	}
+	return res, nil
+}
+
+// InvalidateMaterialized 删除名称为name的所有保存结果，同时清理已过期的结果
+func InvalidateMaterialized(ctx context.Context, db *gorm.DB, name string) error {
+	if err := dbWithCtx(ctx, db).Where("name = ? OR expire_at <= ?", name, time.Now()).Delete(&MaterializedResult{}).Error; err != nil {
+		return errors.Wrapf(err, "db: invalidate materialized %s error", name)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package gormx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+var modelRegistry struct {
+	mu     sync.Mutex
+	models []any
+	types  map[reflect.Type]struct{}
+}
+
+// RegisterModel 登记需要迁移的模型，通常在定义repo的包的init中调用，同一类型重复登记只保留第一次：
+//
+//	func init() {
+//		gormx.RegisterModel(&User{}, &Order{})
+//	}
+func RegisterModel(models ...any) {
+	modelRegistry.mu.Lock()
+	defer modelRegistry.mu.Unlock()
+	if modelRegistry.types == nil {
+		modelRegistry.types = make(map[reflect.Type]struct{})
+	}
+	for _, m := range models {
+		t := reflect.TypeOf(m)
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if _, ok := modelRegistry.types[t]; ok {
+			continue
+		}
+		modelRegistry.types[t] = struct{}{}
+		modelRegistry.models = append(modelRegistry.models, m)
+	}
+}
+
+// RegisteredModels 按登记顺序返回所有模型
+func RegisteredModels() []any {
+	modelRegistry.mu.Lock()
+	defer modelRegistry.mu.Unlock()
+	return append([]any(nil), modelRegistry.models...)
+}
+
+// Migrate 按登记顺序对 RegisterModel 登记的模型执行 AutoMigrate，通常在服务启动时调用
+// ctx 为 DryRun 时只记录需要执行的DDL，不修改表结构（检查表、列的查询照常执行），用于上线前查看变更：
+//
+//	ctx, rec := gormx.DryRun(ctx)
+//	if err := gormx.Migrate(ctx, db); err != nil {
+//		return err
+//	}
+//	fmt.Println(rec) // 为空说明表结构与模型一致
+func Migrate(ctx context.Context, db *gorm.DB) error {
+	tx := db.WithContext(ctx)
+	if rec := dryRunFromCtx(ctx); rec != nil {
+		tx = tx.Session(&gorm.Session{})
+		tx.Statement.ConnPool = ddlRecorder{ConnPool: tx.Statement.ConnPool, rec: rec}
+	}
+	for _, m := range RegisteredModels() {
+		if err := tx.AutoMigrate(m); err != nil {
+			return errors.Wrapf(err, "db: migrate %T error", m)
+		}
+	}
+	return nil
+}
+
+// ddlRecorder 查询照常执行，Exec（DDL）只记录不执行
+type ddlRecorder struct {
+	gorm.ConnPool
+	rec *DryRunRecorder
+}
+
+func (r ddlRecorder) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	r.rec.record(query, args)
+	return driver.RowsAffected(0), nil
+}
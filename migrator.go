@@ -0,0 +1,318 @@
+package gormx
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// ErrMigrationLocked 另一个进程正在执行迁移，锁没有释放时（例如进程崩溃）可以通过 Migrator.Unlock 手动释放
+var ErrMigrationLocked = errors.New("db: migration is locked by another process")
+
+// Migration 一个版本的迁移，Up、Down 在同一个事务内执行并记录版本（mysql 的DDL会隐式提交，无法回滚），NoTx 的迁移除外
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(ctx context.Context, tx *gorm.DB) error
+	// Down 为nil时该版本不能回退
+	Down func(ctx context.Context, tx *gorm.DB) error
+	// NoTx 不在事务内执行，用于 CREATE INDEX CONCURRENTLY 等不能在事务内执行的DDL，执行成功后再记录版本
+	NoTx bool
+}
+
+// SQLMigration 用SQL定义的迁移，up、down 可以包含多条以 ; 分隔的语句，down为空时不能回退
+func SQLMigration(version int64, name, up, down string) Migration {
+	m := Migration{Version: version, Name: name, Up: execSQL(up)}
+	if strings.TrimSpace(down) != "" {
+		m.Down = execSQL(down)
+	}
+	return m
+}
+
+// execSQL 逐条执行以 ; 分隔的语句，不支持语句内（例如字符串、存储过程）包含 ;
+func execSQL(script string) func(ctx context.Context, tx *gorm.DB) error {
+	return func(ctx context.Context, tx *gorm.DB) error {
+		for _, stmt := range strings.Split(script, ";") {
+			if strings.TrimSpace(stmt) == "" {
+				continue
+			}
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// migrationFileRe 迁移文件名，例如 0001_create_users.up.sql、0001_create_users.down.sql
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadSQLMigrations 读取dir下的SQL迁移文件，通常配合 embed 使用：
+//
+//	//go:embed migrations/*.sql
+//	var migrationFS embed.FS
+//
+//	migrations, err := gormx.LoadSQLMigrations(migrationFS, "migrations")
+func LoadSQLMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: load migrations error, dir: %s", dir)
+	}
+	type files struct {
+		name, up, down string
+	}
+	byVersion := make(map[int64]*files)
+	for _, e := range entries {
+		match := migrationFileRe.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "db: load migrations error, file: %s", e.Name())
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "db: load migrations error, file: %s", e.Name())
+		}
+		f := byVersion[version]
+		if f == nil {
+			f = &files{name: match[2]}
+			byVersion[version] = f
+		}
+		if f.name != match[2] {
+			return nil, errors.Errorf("db: load migrations error, version %d has different names: %s, %s", version, f.name, match[2])
+		}
+		if match[3] == "up" {
+			f.up = string(content)
+		} else {
+			f.down = string(content)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for version, f := range byVersion {
+		if f.up == "" {
+			return nil, errors.Errorf("db: load migrations error, version %d has no up file", version)
+		}
+		migrations = append(migrations, SQLMigration(version, f.name, f.up, f.down))
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// SchemaMigration 已执行的迁移版本
+type SchemaMigration struct {
+	Version   int64     `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;size:255;NOT NULL"`
+	AppliedAt time.Time `gorm:"column:applied_at;NOT NULL"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "gormx_schema_migrations"
+}
+
+// schemaMigrationLock 迁移锁，存在id为1的记录表示已加锁
+type schemaMigrationLock struct {
+	ID       int64     `gorm:"column:id;primaryKey;autoIncrement:false"`
+	LockedAt time.Time `gorm:"column:locked_at;NOT NULL"`
+}
+
+func (schemaMigrationLock) TableName() string {
+	return "gormx_schema_migrations_lock"
+}
+
+// MigrationStatus Migrator.Status 返回的每个版本的状态，AppliedAt 为nil表示未执行
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrator 版本化的迁移，按版本号从小到大执行，已执行的版本记录在 gormx_schema_migrations：
+//
+//	m, err := gormx.NewMigrator(db, migrations...)
+//	err = m.Up(ctx)       // 执行所有未执行的版本
+//	err = m.Down(ctx, 1)  // 回退最近的一个版本
+//	status, err := m.Status(ctx)
+//
+// Up、Down 执行期间通过 gormx_schema_migrations_lock 加锁，多个实例同时启动时只有一个执行，其余返回 ErrMigrationLocked
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator 检查版本号是否重复并按版本排序
+func NewMigrator(db *gorm.DB, migrations ...Migration) (*Migrator, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		if m.Up == nil {
+			return nil, errors.Errorf("db: migration %d %s has no up", m.Version, m.Name)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, errors.Errorf("db: duplicate migration version %d: %s, %s", m.Version, sorted[i-1].Name, m.Name)
+		}
+	}
+	return &Migrator{db: db, migrations: sorted}, nil
+}
+
+func (m *Migrator) init(ctx context.Context) error {
+	if err := m.db.WithContext(ctx).AutoMigrate(&SchemaMigration{}, &schemaMigrationLock{}); err != nil {
+		return errors.Wrap(err, "db: migrate schema migrations error")
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]SchemaMigration, error) {
+	var rows []SchemaMigration
+	if err := m.db.WithContext(ctx).Order("version").Find(&rows).Error; err != nil {
+		return nil, errors.Wrap(err, "db: select schema migrations error")
+	}
+	res := make(map[int64]SchemaMigration, len(rows))
+	for _, r := range rows {
+		res[r.Version] = r
+	}
+	return res, nil
+}
+
+// withLock 加锁后执行fn
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	if err := m.init(ctx); err != nil {
+		return err
+	}
+	if err := m.db.WithContext(ctx).Create(&schemaMigrationLock{ID: 1, LockedAt: time.Now()}).Error; err != nil {
+		if IsDuplicateKey(err) {
+			return ErrMigrationLocked
+		}
+		return errors.Wrap(err, "db: lock schema migrations error")
+	}
+	defer func() {
+		// 迁移失败时ctx可能已经取消，释放锁不使用ctx
+		_ = m.Unlock(context.WithoutCancel(ctx))
+	}()
+	return fn()
+}
+
+// Unlock 释放迁移锁，用于执行迁移的进程崩溃后锁没有释放的情况
+func (m *Migrator) Unlock(ctx context.Context) error {
+	if err := m.db.WithContext(ctx).Delete(&schemaMigrationLock{ID: 1}).Error; err != nil {
+		return errors.Wrap(err, "db: unlock schema migrations error")
+	}
+	return nil
+}
+
+// run 在事务内执行fn，noTx 时直接执行
+func (m *Migrator) run(ctx context.Context, noTx bool, fn func(tx *gorm.DB) error) error {
+	if noTx {
+		return fn(m.db.WithContext(ctx))
+	}
+	return m.db.WithContext(ctx).Transaction(fn)
+}
+
+// Up 执行所有未执行的版本
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.UpTo(ctx, 0)
+}
+
+// UpTo 执行版本号不大于version的未执行版本，version<=0 时执行所有
+// 版本号小于已执行的最大版本但未执行的迁移（例如合并分支带来的）同样会执行
+func (m *Migrator) UpTo(ctx context.Context, version int64) error {
+	return m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if version > 0 && mig.Version > version {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			err = m.run(ctx, mig.NoTx, func(tx *gorm.DB) error {
+				if err := mig.Up(ctx, tx); err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return errors.Wrapf(err, "db: migrate up %d %s error", mig.Version, mig.Name)
+			}
+		}
+		return nil
+	})
+}
+
+// Down 按版本从大到小回退最近执行的steps个版本，steps 必须大于0
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		return errors.Errorf("db: migrate down error, steps must be positive, now it is %d", steps)
+	}
+	return m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		byVersion := make(map[int64]Migration, len(m.migrations))
+		for _, mig := range m.migrations {
+			byVersion[mig.Version] = mig
+		}
+		versions := make([]int64, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+		for _, v := range versions[:min(steps, len(versions))] {
+			mig, ok := byVersion[v]
+			if !ok {
+				return errors.Errorf("db: migrate down %d %s error, migration not found", v, applied[v].Name)
+			}
+			if mig.Down == nil {
+				return errors.Errorf("db: migrate down %d %s error, migration is irreversible", v, mig.Name)
+			}
+			err = m.run(ctx, mig.NoTx, func(tx *gorm.DB) error {
+				if err := mig.Down(ctx, tx); err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{Version: v}).Error
+			})
+			if err != nil {
+				return errors.Wrapf(err, "db: migrate down %d %s error", v, mig.Name)
+			}
+		}
+		return nil
+	})
+}
+
+// Status 所有迁移的执行状态，包括数据库中已执行但代码中不存在的版本，按版本排序
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.init(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if r, ok := applied[mig.Version]; ok {
+			s.AppliedAt = &r.AppliedAt
+			delete(applied, mig.Version)
+		}
+		res = append(res, s)
+	}
+	for _, r := range applied {
+		res = append(res, MigrationStatus{Version: r.Version, Name: r.Name, AppliedAt: &r.AppliedAt})
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
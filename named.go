@@ -0,0 +1,84 @@
+package gormx
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// bindNamed 把sql中的 :name 命名参数替换为 ?，返回按出现顺序排列的参数
+//
+// args为单个 map[string]any 或若干 sql.Named 时才会替换，其他情况原样返回，
+// gorm原生的 @name 写法不受影响；引号内的内容、postgres的 ::类型转换 和 mysql的 := 不会被当作参数
+func bindNamed(query string, args []any) (string, []any, error) {
+	named, ok := namedArgs(args)
+	if !ok || !strings.Contains(query, ":") {
+		return query, args, nil
+	}
+	var (
+		sb    strings.Builder
+		vars  []any
+		found bool
+	)
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for j < len(query) && query[j] != c {
+				if query[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(query))
+			sb.WriteString(query[i:j])
+			i = j
+		case c == ':' && i+1 < len(query) && query[i+1] == ':':
+			sb.WriteString("::")
+			i += 2
+		case c == ':' && i+1 < len(query) && isIdentStart(query[i+1]) && (i == 0 || !isIdentStart(query[i-1])):
+			j := i + 1
+			for j < len(query) && (isIdentStart(query[j]) || query[j] >= '0' && query[j] <= '9') {
+				j++
+			}
+			name := query[i+1 : j]
+			v, exists := named[name]
+			if !exists {
+				return "", nil, errors.Errorf("db: named parameter :%s not found, sql: %s", name, query)
+			}
+			sb.WriteByte('?')
+			vars = append(vars, v)
+			found = true
+			i = j
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	if !found {
+		return query, args, nil
+	}
+	return sb.String(), vars, nil
+}
+
+func namedArgs(args []any) (map[string]any, bool) {
+	if len(args) == 1 {
+		if m, ok := args[0].(map[string]any); ok {
+			return m, true
+		}
+	}
+	if len(args) == 0 {
+		return nil, false
+	}
+	m := make(map[string]any, len(args))
+	for _, arg := range args {
+		n, ok := arg.(sql.NamedArg)
+		if !ok {
+			return nil, false
+		}
+		m[n.Name] = n.Value
+	}
+	return m, true
+}
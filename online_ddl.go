@@ -0,0 +1,180 @@
+package gormx
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// DefaultDDLLockTimeout 在线DDL等待元数据锁的默认超时时间
+const DefaultDDLLockTimeout = 5 * time.Second
+
+// OnlineDDLOptions 在线DDL的选项
+type OnlineDDLOptions struct {
+	// 等待表锁的超时时间，超时后放弃本次DDL而不是阻塞业务SQL，<=0 时为 DefaultDDLLockTimeout
+	LockTimeout time.Duration
+	// mysql 创建唯一索引
+	Unique bool
+}
+
+// OnlineDDLError 在线DDL失败时返回，Advice为后续处理建议
+type OnlineDDLError struct {
+	Statement string
+	Advice    string
+	Err       error
+}
+
+func (e *OnlineDDLError) Error() string {
+	return fmt.Sprintf("db: online ddl error, statement: %s, advice: %s: %v", e.Statement, e.Advice, e.Err)
+}
+
+func (e *OnlineDDLError) Unwrap() error {
+	return e.Err
+}
+
+// AddColumnOnline 在线增加列
+//
+// mysql 依次尝试 ALGORITHM=INSTANT、ALGORITHM=INPLACE, LOCK=NONE，都不支持时返回错误而不是退化为锁表的COPY；
+// postgres 直接 ADD COLUMN，带非易变默认值时为元数据操作
+func AddColumnOnline(ctx context.Context, db *gorm.DB, table, column, definition string, opts OnlineDDLOptions) error {
+	q := db.Statement.Quote
+	ddl := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", q(table), q(column), definition)
+	if db.Dialector.Name() == "mysql" {
+		return execOnlineDDL(ctx, db, opts, ddl+", ALGORITHM=INSTANT", ddl+", ALGORITHM=INPLACE, LOCK=NONE")
+	}
+	return execOnlineDDL(ctx, db, opts, ddl)
+}
+
+// AddIndexOnline 在线创建索引
+//
+// mysql 使用 ALGORITHM=INPLACE, LOCK=NONE；postgres 使用 CREATE INDEX CONCURRENTLY，不能在事务内执行
+func AddIndexOnline(ctx context.Context, db *gorm.DB, table, index string, columns []string, opts OnlineDDLOptions) error {
+	q := db.Statement.Quote
+	cols := make([]string, 0, len(columns))
+	for _, c := range columns {
+		cols = append(cols, q(c))
+	}
+	unique := ""
+	if opts.Unique {
+		unique = "UNIQUE "
+	}
+	if db.Dialector.Name() == "postgres" {
+		if _, inTx := ctx.Value(contextTxKey{}).(*gorm.DB); inTx {
+			return errors.Errorf("db: create index %s concurrently cannot run inside a transaction", index)
+		}
+		ddl := fmt.Sprintf("CREATE %sINDEX CONCURRENTLY IF NOT EXISTS %s ON %s (%s)", unique, q(index), q(table), strings.Join(cols, ", "))
+		return execOnlineDDL(ctx, db, opts, ddl)
+	}
+	ddl := fmt.Sprintf("ALTER TABLE %s ADD %sINDEX %s (%s), ALGORITHM=INPLACE, LOCK=NONE", q(table), unique, q(index), strings.Join(cols, ", "))
+	return execOnlineDDL(ctx, db, opts, ddl)
+}
+
+// AddColumnOnlineMigration 通过 AddColumnOnline 增加列的迁移，Down 删除该列，不在事务内执行
+func AddColumnOnlineMigration(version int64, name, table, column, definition string, opts OnlineDDLOptions) Migration {
+	return Migration{
+		Version: version,
+		Name:    name,
+		NoTx:    true,
+		Up: func(ctx context.Context, db *gorm.DB) error {
+			return AddColumnOnline(ctx, db, table, column, definition, opts)
+		},
+		Down: func(ctx context.Context, db *gorm.DB) error {
+			return db.Migrator().DropColumn(table, column)
+		},
+	}
+}
+
+// AddIndexOnlineMigration 通过 AddIndexOnline 创建索引的迁移，Down 删除该索引，不在事务内执行
+func AddIndexOnlineMigration(version int64, name, table, index string, columns []string, opts OnlineDDLOptions) Migration {
+	return Migration{
+		Version: version,
+		Name:    name,
+		NoTx:    true,
+		Up: func(ctx context.Context, db *gorm.DB) error {
+			return AddIndexOnline(ctx, db, table, index, columns, opts)
+		},
+		Down: func(ctx context.Context, db *gorm.DB) error {
+			return db.Migrator().DropIndex(table, index)
+		},
+	}
+}
+
+// execOnlineDDL 在固定连接上设置锁等待超时后依次尝试statements，前一条因算法不支持失败时尝试下一条，
+// 结束后恢复连接原来的超时，避免连接归还连接池后影响其他语句
+func execOnlineDDL(ctx context.Context, db *gorm.DB, opts OnlineDDLOptions, statements ...string) error {
+	if opts.LockTimeout <= 0 {
+		opts.LockTimeout = DefaultDDLLockTimeout
+	}
+	dialect := db.Dialector.Name()
+	return db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		// 每条语句使用新的Statement，前一条语句的错误不影响后面的语句
+		conn = conn.Session(&gorm.Session{NewDB: true})
+		restore, err := setLockTimeout(ctx, conn, dialect, opts.LockTimeout)
+		if err != nil {
+			return err
+		}
+		defer restore()
+
+		for i, stmt := range statements {
+			if err = conn.Exec(stmt).Error; err == nil {
+				return nil
+			}
+			if isLockTimeout(err) {
+				return &OnlineDDLError{Statement: stmt, Err: err,
+					Advice: "lock wait timeout, long running transactions are holding the table, retry off-peak or kill the blocking sessions"}
+			}
+			if !isUnsupportedAlgorithm(err) {
+				return errors.Wrapf(err, "db: online ddl error, statement: %s", stmt)
+			}
+			if i == len(statements)-1 {
+				return &OnlineDDLError{Statement: stmt, Err: err,
+					Advice: "online algorithm is not supported for this change, use gh-ost or pt-online-schema-change instead of a locking table copy"}
+			}
+		}
+		return err
+	})
+}
+
+// setLockTimeout 设置conn的锁等待超时，返回恢复原值的函数
+func setLockTimeout(ctx context.Context, conn *gorm.DB, dialect string, timeout time.Duration) (func(), error) {
+	var get, set, value string
+	switch dialect {
+	case "mysql":
+		get, set = "SELECT @@SESSION.lock_wait_timeout", "SET SESSION lock_wait_timeout = %s"
+		value = strconv.Itoa(max(int(timeout.Seconds()), 1))
+	case "postgres":
+		get, set = "SHOW lock_timeout", "SET lock_timeout = '%s'"
+		value = fmt.Sprintf("%dms", timeout.Milliseconds())
+	default:
+		return func() {}, nil
+	}
+	var old string
+	if err := conn.Raw(get).Scan(&old).Error; err != nil {
+		return nil, errors.Wrap(err, "db: online ddl get lock timeout error")
+	}
+	if err := conn.Exec(fmt.Sprintf(set, value)).Error; err != nil {
+		return nil, errors.Wrap(err, "db: online ddl set lock timeout error")
+	}
+	return func() {
+		// DDL失败时ctx可能已经取消，恢复不使用ctx
+		_ = conn.WithContext(context.WithoutCancel(ctx)).Exec(fmt.Sprintf(set, old)).Error
+	}, nil
+}
+
+func isLockTimeout(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "Error 1205") || strings.Contains(msg, "Lock wait timeout") ||
+		strings.Contains(msg, "55P03") || strings.Contains(msg, "lock timeout")
+}
+
+func isUnsupportedAlgorithm(err error) bool {
+	msg := err.Error()
+	// 1845/1846: ALGORITHM=INSTANT/INPLACE is not supported
+	return strings.Contains(msg, "Error 1845") || strings.Contains(msg, "Error 1846") ||
+		strings.Contains(msg, "is not supported")
+}
@@ -0,0 +1,84 @@
+package gormx
+
+import (
+	"context"
+	"time"
+)
+
+// Option BaseRepo 的可选配置，在 NewBaseRepo 时传入
+type Option func(*options)
+
+type options struct {
+	cache            Cache
+	cacheTTL         time.Duration
+	negativeCacheTTL time.Duration
+	queryCache       bool
+	pageCache        bool
+	pageCacheTTL     time.Duration
+	cacheTenant      func(ctx context.Context) string
+	replicas         *replicaSet
+	stickyWindow     time.Duration
+	flight           *singleflightGroup
+	pkFilter         *PKFilter
+	audit            bool
+	history          bool
+	strict           bool
+
+	circuitBreaker *CircuitBreaker
+	limiter        *Limiter
+
+	deadlineCheck bool
+	deadlineFloor time.Duration
+
+	updateTimeMode   UpdateTimeMode
+	updateTimeColumn string
+	idGenerator      IDGenerator
+}
+
+// WithCache 为 SelectOneByPK / SelectByPK 开启读穿透缓存，ttl<=0 时使用 DefaultCacheTTL
+// 通过 UpdateByPK、DeleteByPK 等写操作修改的记录会自动失效；带有加密字段的模型不使用缓存
+func WithCache(cache Cache, ttl time.Duration) Option {
+	return func(o *options) {
+		if ttl <= 0 {
+			ttl = DefaultCacheTTL
+		}
+		o.cache = cache
+		o.cacheTTL = ttl
+	}
+}
+
+// WithNegativeCache 开启 WithCache 时同时缓存 SelectOneByPK / SelectByPK 中不存在的主键，
+// 避免反复查询已删除或不存在的ID（缓存穿透）的请求都落到数据库；新增记录时删除对应主键的缓存，
+// ttl 应较短，ttl<=0 时使用 DefaultNegativeCacheTTL
+func WithNegativeCache(ttl time.Duration) Option {
+	return func(o *options) {
+		if ttl <= 0 {
+			ttl = DefaultNegativeCacheTTL
+		}
+		o.negativeCacheTTL = ttl
+	}
+}
+
+// WithCacheSingleflight 缓存未命中时合并相同主键的并发 SelectOneByPK，只由一次数据库查询返回给所有调用方
+// 合并后的查询使用第一个调用方的ctx
+func WithCacheSingleflight() Option {
+	return func(o *options) {
+		o.flight = &singleflightGroup{}
+	}
+}
+
+// WithQueryCache 额外缓存 SelectByMap / SelectOneByMap / SelectAll 的结果，需要 WithCache 传入 TagCache
+//
+// 结果按表、条件列值以及结果主键登记标签，写操作只失效受影响的查询
+func WithQueryCache() Option {
+	return func(o *options) {
+		o.queryCache = true
+	}
+}
+
+// WithCacheTenant 从ctx中取租户标识，条件查询的缓存和标签按租户隔离，写操作只失效当前租户的查询
+func WithCacheTenant(tenant func(ctx context.Context) string) Option {
+	return func(o *options) {
+		o.cacheTenant = tenant
+	}
+}
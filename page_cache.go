@@ -0,0 +1,85 @@
+package gormx
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DefaultPageCacheTTL WithPageCache 未指定 ttl 时的默认过期时间
+const DefaultPageCacheTTL = 10 * time.Second
+
+// WithPageCache 缓存 PageSelect 按map条件分页查询的结果（当前页的记录和总数），需要 WithCache 传入 TagCache，
+// 用于同一页被大量重复请求的列表接口：
+//
+//	repo := gormx.NewBaseRepo[Article](db, gormx.WithCache(cache, time.Minute), gormx.WithPageCache(5*time.Second))
+//
+// 任何写操作都可能改变其他页的内容和总数，表上的写操作会失效该表所有缓存的页；ttl 应较短，ttl<=0 时使用 DefaultPageCacheTTL
+func WithPageCache(ttl time.Duration) Option {
+	return func(o *options) {
+		if ttl <= 0 {
+			ttl = DefaultPageCacheTTL
+		}
+		o.pageCache = true
+		o.pageCacheTTL = ttl
+	}
+}
+
+func (b *BaseRepo[T]) pageCacheEnabled(ctx context.Context) bool {
+	if !b.opts.pageCache || !b.cacheEnabled(ctx) {
+		return false
+	}
+	_, ok := b.tagCache()
+	return ok
+}
+
+// pageTag 分页缓存的标签：gormx:tag:表名[:租户]:page，表上的写操作失效该标签
+func (b *BaseRepo[T]) pageTag(ctx context.Context) string {
+	return b.tagPrefix(ctx) + ":page"
+}
+
+type pageCacheEntry struct {
+	Items []cachedRow `json:"items"`
+	Total int32       `json:"total"`
+}
+
+// pageSelectCached PageSelect 的缓存，key为条件、页码、页大小和排序的哈希
+func (b *BaseRepo[T]) pageSelectCached(ctx context.Context, page *PageParam, condition map[string]any) ([]*T, int32, error) {
+	tc, _ := b.tagCache()
+	keyParts := map[string]any{"condition": condition}
+	if page != nil {
+		keyParts["page"] = []any{page.PageNo, page.PageSize, page.OrderBy}
+	}
+	// map按key排序序列化，相同条件得到相同的key
+	raw, err := json.Marshal(keyParts)
+	if err != nil {
+		return b.pageSelect(ctx, page, condition)
+	}
+	sum := sha1.Sum(raw)
+	key := fmt.Sprintf("gormx:%s:p:%s", b.cacheScope(ctx), hex.EncodeToString(sum[:]))
+	if data, err := tc.Get(ctx, key); err == nil {
+		var entry pageCacheEntry
+		if err = json.Unmarshal(data, &entry); err == nil {
+			if res, err := b.fromCachedRows(ctx, entry.Items); err == nil {
+				recordCache(ctx, true)
+				return res, entry.Total, b.maskCached(ctx, res...)
+			}
+		}
+	}
+	recordCache(ctx, false)
+
+	// 缓存中保存原值，取出后再按ctx脱敏
+	res, total, err := b.pageSelect(withoutMasking(ctx), page, condition)
+	if err != nil {
+		return nil, 0, err
+	}
+	if crs, err := b.toCachedRows(ctx, res); err == nil {
+		if data, err := json.Marshal(pageCacheEntry{Items: crs, Total: total}); err == nil {
+			_ = tc.SetWithTags(ctx, key, data, b.opts.pageCacheTTL, b.pageTag(ctx))
+		}
+	}
+	return res, total, b.maskCached(ctx, res...)
+}
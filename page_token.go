@@ -0,0 +1,143 @@
+package gormx
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidPageToken page_token 无法解析，gRPC接口通常返回 InvalidArgument
+var ErrInvalidPageToken = errors.New("db: invalid page token")
+
+const (
+	// DefaultPageSize page_size 为0时的每页条数
+	DefaultPageSize = 20
+	// MaxPageSize page_size 的上限，超过时按上限处理
+	MaxPageSize = 1000
+)
+
+// PageRequest 常见的proto分页请求，protoc 为 page_size、page_token 字段生成的 Getter 满足该接口：
+//
+//	message ListUsersRequest {
+//	  int32 page_size = 1;
+//	  string page_token = 2;
+//	}
+type PageRequest interface {
+	GetPageSize() int32
+	GetPageToken() string
+}
+
+// pageToken page_token 的内容，Offset 用于 PageParamFromRequest，After 用于 SelectByPageToken
+type pageToken struct {
+	Offset int64           `json:"o,omitempty"`
+	After  json.RawMessage `json:"a,omitempty"`
+}
+
+func encodePageToken(t pageToken) string {
+	raw, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodePageToken(token string) (pageToken, error) {
+	var t pageToken
+	if token == "" {
+		return t, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return t, errors.Wrapf(ErrInvalidPageToken, "token: %s", token)
+	}
+	if err = json.Unmarshal(raw, &t); err != nil || t.Offset < 0 {
+		return t, errors.Wrapf(ErrInvalidPageToken, "token: %s", token)
+	}
+	return t, nil
+}
+
+// pageSize page_size 为0时为 DefaultPageSize，超过 MaxPageSize 时为 MaxPageSize
+func pageSize(req PageRequest) (int, error) {
+	size := int(req.GetPageSize())
+	switch {
+	case size < 0:
+		return 0, errors.Wrapf(ErrInvalidPageToken, "page size: %d", size)
+	case size == 0:
+		size = DefaultPageSize
+	case size > MaxPageSize:
+		size = MaxPageSize
+	}
+	return size, nil
+}
+
+// PageParamFromRequest 把proto分页请求转换为 PageParam，page_token 为 NextPageToken 返回的不透明字符串：
+//
+//	page, err := gormx.PageParamFromRequest(req, "id")
+//	users, total, err := repo.PageSelect(ctx, page, condition)
+//	resp.NextPageToken = gormx.NextPageToken(page, total)
+//
+// token中记录的是偏移量，偏移量不是PageSize的整数倍时（两页的page_size不同）按 PageNo 向下取整
+func PageParamFromRequest(req PageRequest, orderBy string) (*PageParam, error) {
+	size, err := pageSize(req)
+	if err != nil {
+		return nil, err
+	}
+	t, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	return &PageParam{PageNo: int32(t.Offset/int64(size)) + 1, PageSize: int32(size), OrderBy: orderBy}, nil
+}
+
+// NextPageToken 下一页的 page_token，没有下一页时为空
+func NextPageToken(page *PageParam, total int32) string {
+	next := int64(page.PageNo) * int64(page.PageSize)
+	if page.PageSize <= 0 || next >= int64(total) {
+		return ""
+	}
+	return encodePageToken(pageToken{Offset: next})
+}
+
+// SelectByPageToken 按主键顺序的游标分页，page_token 中记录的是上一页最后一条记录的主键，
+// 与 PageSelect 相比不需要 count，深翻页时也不会变慢，适合 List 接口：
+//
+//	users, next, err := repo.SelectByPageToken(ctx, condition, req)
+//	resp.Users, resp.NextPageToken = toProto(users), next
+//
+// 返回的next为空表示没有下一页
+func (b *BaseRepo[T]) SelectByPageToken(ctx context.Context, condition map[string]any, req PageRequest) ([]*T, string, error) {
+	if err := b.singlePK(); err != nil {
+		return nil, "", errors.WithMessage(err, "select by page token")
+	}
+	size, err := pageSize(req)
+	if err != nil {
+		return nil, "", err
+	}
+	t, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, "", err
+	}
+	var after any
+	if len(t.After) > 0 {
+		if after, err = b.decodeCursor(string(t.After)); err != nil {
+			return nil, "", errors.Wrapf(ErrInvalidPageToken, "token: %s", req.GetPageToken())
+		}
+	}
+	// 多读一条判断是否有下一页
+	res, err := b.selectBatchAfter(ctx, b.columnKeys(condition), after, size+1)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(res) <= size {
+		return res, "", nil
+	}
+	res = res[:size]
+	last, ok := b.pkValue(ctx, res[size-1])
+	if !ok {
+		return res, "", nil
+	}
+	raw, err := json.Marshal(last)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "db: encode %s page token error", b.StructName)
+	}
+	return res, encodePageToken(pageToken{After: raw}), nil
+}
@@ -0,0 +1,112 @@
+package gormx
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm/clause"
+)
+
+// ErrCompositePK 复合主键的模型调用了只支持单列主键的方法（SelectOneByPK、DeleteByPK等），应使用 XxxByPKs
+var ErrCompositePK = errors.New("db: composite primary key")
+
+// PK 复合主键的值，key为主键的列名或字段名（兼容驼峰和蛇形），需要包含所有主键列：
+//
+//	repo.SelectOneByPKs(ctx, gormx.PK{"order_id": 1, "sku_id": 2})
+type PK map[string]any
+
+// singlePK 复合主键的模型返回 ErrCompositePK
+func (b *BaseRepo[T]) singlePK() error {
+	if keys := b.Schema().PrimaryKeys; len(keys) > 1 {
+		return errors.Wrapf(ErrCompositePK, "db: %s primary keys: %v", b.StructName, keys)
+	}
+	return nil
+}
+
+// pkExpr 一个主键值对应的条件：各主键列相等
+func (b *BaseRepo[T]) pkExpr(pk PK) (clause.Expression, error) {
+	keys := b.Schema().PrimaryKeys
+	if len(keys) == 0 {
+		return nil, errors.Errorf("db: %s has no primary key", b.StructName)
+	}
+	c := b.columnKeys(pk)
+	if len(c) != len(keys) {
+		return nil, errors.Errorf("db: %s primary keys are %v, got %v", b.StructName, keys, pk)
+	}
+	exprs := make([]clause.Expression, 0, len(keys))
+	for _, key := range keys {
+		v, ok := c[key]
+		if !ok {
+			return nil, errors.Errorf("db: %s primary keys are %v, got %v", b.StructName, keys, pk)
+		}
+		exprs = append(exprs, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: key}, Value: v})
+	}
+	return clause.And(exprs...), nil
+}
+
+// pksExpr 多个主键值的条件：(a = ? AND b = ?) OR (a = ? AND b = ?)
+func (b *BaseRepo[T]) pksExpr(pks []PK) (clause.Expression, error) {
+	exprs := make([]clause.Expression, 0, len(pks))
+	for _, pk := range pks {
+		e, err := b.pkExpr(pk)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return clause.Or(exprs...), nil
+}
+
+// SelectOneByPKs 根据复合主键查找，单列主键的模型也可以使用
+func (b *BaseRepo[T]) SelectOneByPKs(ctx context.Context, pk PK) (*T, error) {
+	expr, err := b.pkExpr(pk)
+	if err != nil {
+		return nil, errors.WithMessage(err, "select one by pks")
+	}
+	return b.selectOne(ctx, expr)
+}
+
+// SelectByPKs 根据多个复合主键查找，pks为空时返回空
+func (b *BaseRepo[T]) SelectByPKs(ctx context.Context, pks []PK) ([]*T, error) {
+	if len(pks) == 0 {
+		return nil, nil
+	}
+	expr, err := b.pksExpr(pks)
+	if err != nil {
+		return nil, errors.WithMessage(err, "select by pks")
+	}
+	return b._select(ctx, expr)
+}
+
+// UpdateByPKsWithMap 根据复合主键更新，支持零值，updateData的处理同 UpdateByMap
+func (b *BaseRepo[T]) UpdateByPKsWithMap(ctx context.Context, pk PK, updateData map[string]any) (int64, error) {
+	if _, err := b.pkExpr(pk); err != nil {
+		return 0, errors.WithMessage(err, "update by pks")
+	}
+	return b.UpdateByMap(ctx, pk, updateData)
+}
+
+// DeleteByPKs 根据多个复合主键删除，pks为空时不删除
+func (b *BaseRepo[T]) DeleteByPKs(ctx context.Context, pks []PK) (int64, error) {
+	if len(pks) == 0 {
+		return 0, nil
+	}
+	expr, err := b.pksExpr(pks)
+	if err != nil {
+		return 0, errors.WithMessage(err, "delete by pks")
+	}
+	var m T
+	tx := b.withTransactionCtx(ctx).Where(expr).Delete(&m)
+	if err := tx.Error; err != nil {
+		return 0, errors.Wrapf(err, "db: delete %s by pks error, pks: %v", b.StructName, pks)
+	}
+	// 只有单列主键的模型使用缓存
+	values := make([]any, 0, len(pks))
+	for _, pk := range pks {
+		values = append(values, b.columnKeys(pk)[b.PrimaryKey])
+	}
+	return tx.RowsAffected, b.invalidateCache(ctx, values, nil)
+}
@@ -0,0 +1,208 @@
+package gormx
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm/clause"
+)
+
+// bloomBits 布隆过滤器的位图，并发读写不加锁
+type bloomBits struct {
+	words []atomic.Uint64
+	m     uint64
+	k     uint64
+}
+
+func newBloomBits(m, k uint64) *bloomBits {
+	return &bloomBits{words: make([]atomic.Uint64, (m+63)/64), m: m, k: k}
+}
+
+// positions 双重哈希得到k个位置
+func (bb *bloomBits) positions(key string, fn func(pos uint64) bool) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	h1 := h.Sum64()
+	h2 := h1>>33 | h1<<31 | 1
+	for i := uint64(0); i < bb.k; i++ {
+		if !fn((h1 + i*h2) % bb.m) {
+			return
+		}
+	}
+}
+
+func (bb *bloomBits) add(key string) {
+	bb.positions(key, func(pos uint64) bool {
+		word := &bb.words[pos/64]
+		bit := uint64(1) << (pos % 64)
+		for {
+			old := word.Load()
+			if old&bit != 0 || word.CompareAndSwap(old, old|bit) {
+				return true
+			}
+		}
+	})
+}
+
+func (bb *bloomBits) contains(key string) bool {
+	found := true
+	bb.positions(key, func(pos uint64) bool {
+		if bb.words[pos/64].Load()&(uint64(1)<<(pos%64)) == 0 {
+			found = false
+		}
+		return found
+	})
+	return found
+}
+
+// PKFilter 主键的布隆过滤器，SelectOneByPK / SelectByPK 查询前先判断主键是否可能存在，
+// 直接拒绝一定不存在的主键，用于热点表上遍历ID的请求：
+//
+//	filter := gormx.NewPKFilter(10_000_000, 0.001)
+//	repo := gormx.NewBaseRepo[User](db, gormx.WithPKFilter(filter))
+//	err := repo.RebuildPKFilter(ctx) // 启动时以及定期执行
+//
+// 通过repo新增的记录会加入过滤器，删除的记录无法从过滤器中移除，只会让查询落到数据库；
+// 其他进程新增的记录在本进程重建前会被误判为不存在，多实例部署时需要定期重建，或者通过消息调用 Add
+type PKFilter struct {
+	expected int
+	fpRate   float64
+
+	mu         sync.RWMutex
+	bits       *bloomBits
+	rebuilding *bloomBits
+	ready      atomic.Bool
+}
+
+// NewPKFilter expected 为预计的记录数，fpRate 为误判存在的概率；记录数超过 expected 后误判率会上升，需要调大后重建
+func NewPKFilter(expected int, fpRate float64) *PKFilter {
+	if expected <= 0 {
+		expected = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	return &PKFilter{expected: expected, fpRate: fpRate, bits: newPKFilterBits(expected, fpRate)}
+}
+
+func newPKFilterBits(expected int, fpRate float64) *bloomBits {
+	n := float64(expected)
+	m := math.Ceil(-n * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/n*math.Ln2))
+	return newBloomBits(uint64(m), uint64(k))
+}
+
+// Add 加入主键
+func (f *PKFilter) Add(pk any) {
+	key := cacheKeyPart(pk)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	f.bits.add(key)
+	if f.rebuilding != nil {
+		f.rebuilding.add(key)
+	}
+}
+
+// MayContain 主键是否可能存在，第一次重建完成前始终返回true
+func (f *PKFilter) MayContain(pk any) bool {
+	if !f.ready.Load() {
+		return true
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.bits.contains(cacheKeyPart(pk))
+}
+
+// WithPKFilter 为 SelectOneByPK / SelectByPK 开启主键的布隆过滤器，过滤器需要通过 RebuildPKFilter 加载后才生效
+func WithPKFilter(f *PKFilter) Option {
+	return func(o *options) {
+		o.pkFilter = f
+	}
+}
+
+// pkFilterRejects 主键一定不存在
+func (b *BaseRepo[T]) pkFilterRejects(pk any) bool {
+	return b.opts.pkFilter != nil && !b.opts.pkFilter.MayContain(pk)
+}
+
+// filterPKs 去掉一定不存在的主键
+func (b *BaseRepo[T]) filterPKs(pks any) []any {
+	all := Interface2Array(pks)
+	res := make([]any, 0, len(all))
+	for _, pk := range all {
+		if !b.pkFilterRejects(pk) {
+			res = append(res, pk)
+		}
+	}
+	return res
+}
+
+// addToPKFilter 新增的记录加入过滤器
+func (b *BaseRepo[T]) addToPKFilter(ctx context.Context, rows ...*T) {
+	if b.opts.pkFilter == nil {
+		return
+	}
+	for _, row := range rows {
+		if pk, ok := b.pkValue(ctx, row); ok {
+			b.opts.pkFilter.Add(pk)
+		}
+	}
+}
+
+// RebuildPKFilter 按主键顺序分批加载表中所有主键（包括软删除的记录），完成后替换过滤器；
+// 重建过程中新增的记录同时加入新旧过滤器
+func (b *BaseRepo[T]) RebuildPKFilter(ctx context.Context) error {
+	f := b.opts.pkFilter
+	if f == nil {
+		return errors.Errorf("db: rebuild %s pk filter error, WithPKFilter is not set", b.StructName)
+	}
+	if err := b.singlePK(); err != nil {
+		return errors.WithMessage(err, "rebuild pk filter")
+	}
+	s, err := b.gormSchema()
+	if err != nil {
+		return err
+	}
+	column := s.PrioritizedPrimaryField.DBName
+	next := newPKFilterBits(f.expected, f.fpRate)
+	f.mu.Lock()
+	f.rebuilding = next
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.rebuilding = nil
+		f.mu.Unlock()
+	}()
+
+	const batchSize = 10000
+	var (
+		m    T
+		last any
+	)
+	for {
+		var pks []any
+		q := b.GormDB.WithContext(ctx).Unscoped().Model(&m).Order(clause.OrderByColumn{Column: clause.Column{Name: column}}).Limit(batchSize)
+		if last != nil {
+			q = q.Where(clause.Gt{Column: clause.Column{Name: column}, Value: last})
+		}
+		if err := q.Pluck(column, &pks).Error; err != nil {
+			return errors.Wrapf(err, "db: rebuild %s pk filter error, after: %v", b.StructName, last)
+		}
+		for _, pk := range pks {
+			next.add(cacheKeyPart(pk))
+		}
+		if len(pks) < batchSize {
+			break
+		}
+		last = pks[len(pks)-1]
+	}
+	f.mu.Lock()
+	f.bits = next
+	f.mu.Unlock()
+	f.ready.Store(true)
+	return nil
+}
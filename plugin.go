@@ -0,0 +1,28 @@
+package gormx
+
+import (
+	stderrors "errors"
+
+	"gorm.io/gorm"
+)
+
+// registerAround 在gorm内置的各个执行回调（create、query、update、delete、row、raw）前后注册插件回调
+// before注册名为 name+":before"，after注册名为 name+":after"
+func registerAround(db *gorm.DB, name string, before, after func(*gorm.DB)) error {
+	cb := db.Callback()
+	b, a := name+":before", name+":after"
+	return stderrors.Join(
+		cb.Create().Before("gorm:create").Register(b, before),
+		cb.Create().After("gorm:create").Register(a, after),
+		cb.Query().Before("gorm:query").Register(b, before),
+		cb.Query().After("gorm:query").Register(a, after),
+		cb.Update().Before("gorm:update").Register(b, before),
+		cb.Update().After("gorm:update").Register(a, after),
+		cb.Delete().Before("gorm:delete").Register(b, before),
+		cb.Delete().After("gorm:delete").Register(a, after),
+		cb.Row().Before("gorm:row").Register(b, before),
+		cb.Row().After("gorm:row").Register(a, after),
+		cb.Raw().Before("gorm:raw").Register(b, before),
+		cb.Raw().After("gorm:raw").Register(a, after),
+	)
+}
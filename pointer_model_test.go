@@ -0,0 +1,115 @@
+package gormx_test
+
+import (
+	"context"
+	"testing"
+
+	"github/flandersRin/gormx"
+	"github/flandersRin/gormx/gormxtest"
+)
+
+type PopBase struct {
+	ID int64 `gorm:"column:id;primaryKey"`
+}
+
+// pop 主键在嵌入的匿名结构体指针中
+type pop struct {
+	*PopBase
+	Name string `gorm:"column:name"`
+	gormx.ModelBaseInfo
+}
+
+func TestPointerModel(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		run  func(t *testing.T, ctx context.Context) []*pop
+	}{
+		{"struct", func(t *testing.T, ctx context.Context) []*pop {
+			repo := gormxtest.NewRepo[pop](t)
+			return crudPop(t, ctx, &repo, func(p *pop) *pop { return p }, func(p *pop) *pop { return p })
+		}},
+		{"pointer", func(t *testing.T, ctx context.Context) []*pop {
+			repo := gormxtest.NewRepo[*pop](t)
+			return crudPop(t, ctx, &repo, func(p *pop) **pop { return &p }, func(p **pop) *pop {
+				if p == nil {
+					return nil
+				}
+				return *p
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := tt.run(t, ctx)
+			if len(rows) != 2 || rows[0].ID != 1 || rows[0].Name != "a" || rows[1].ID != 2 {
+				t.Errorf("rows: %+v", rows)
+			}
+		})
+	}
+}
+
+// crudPop 在repo上执行插入、查询、更新和删除，wrap、unwrap 在 *pop 和 *T 之间转换，返回各个查询方法的结果
+func crudPop[T any](t *testing.T, ctx context.Context, repo *gormx.BaseRepo[T], wrap func(*pop) *T, unwrap func(*T) *pop) []*pop {
+	t.Helper()
+	if repo.PrimaryKey != "id" {
+		t.Fatalf("primary key: %q", repo.PrimaryKey)
+	}
+	for i, name := range []string{"a", "b"} {
+		if err := repo.Insert(ctx, wrap(&pop{PopBase: &PopBase{ID: int64(i + 1)}, Name: name})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	unwrapAll := func(rows []*T) []*pop {
+		res := make([]*pop, 0, len(rows))
+		for _, row := range rows {
+			res = append(res, unwrap(row))
+		}
+		return res
+	}
+	check := func(method string, rows []*T, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		if got := unwrapAll(rows); len(got) != 2 || got[0].PopBase == nil || got[0].ID != 1 || got[1].Name != "b" {
+			t.Fatalf("%s: %+v", method, got)
+		}
+	}
+
+	one, err := repo.SelectOneByPK(ctx, 1)
+	if err != nil || unwrap(one) == nil || unwrap(one).Name != "a" {
+		t.Fatalf("SelectOneByPK: %+v, %v", one, err)
+	}
+	rows, err := repo.SelectByMap(ctx, map[string]any{"name": []string{"a", "b"}})
+	check("SelectByMap", rows, err)
+	rows, total, err := repo.PageSelect(ctx, &gormx.PageParam{PageNo: 1, PageSize: 10, OrderBy: "id"}, map[string]any{})
+	check("PageSelect", rows, err)
+	if total != 2 {
+		t.Errorf("PageSelect total: %d", total)
+	}
+	rows, err = repo.RawSelect(ctx, "SELECT * FROM pops ORDER BY id")
+	check("RawSelect", rows, err)
+	rows, total, err = repo.SelectUnion(ctx, &gormx.PageParam{PageNo: 1, PageSize: 10, OrderBy: "id"}, true,
+		gormx.UnionQuery{Condition: map[string]any{"id": 1}}, gormx.UnionQuery{Condition: map[string]any{"id": 2}})
+	check("SelectUnion", rows, err)
+	if total != 2 {
+		t.Errorf("SelectUnion total: %d", total)
+	}
+
+	if _, err = repo.UpdateByPK(ctx, wrap(&pop{PopBase: &PopBase{ID: 2}, Name: "c"})); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := repo.RawSelect(ctx, "SELECT * FROM pops WHERE id = :id", map[string]any{"id": 2})
+	if err != nil || len(updated) != 1 || unwrap(updated[0]).Name != "c" {
+		t.Fatalf("RawSelect after update: %+v, %v", unwrapAll(updated), err)
+	}
+	if _, err = repo.DeleteByPK(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	rows, err = repo.SelectByMap(ctx, map[string]any{})
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("SelectByMap after delete: %d, %v", len(rows), err)
+	}
+	return []*pop{unwrap(one), unwrap(updated[0])}
+}
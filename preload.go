@@ -0,0 +1,65 @@
+package gormx
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type preload struct {
+	association string
+	conds       []any
+}
+
+type contextPreloadKey struct{}
+
+// WithPreload 在ctx内的查询中预加载模型的gorm关联，可以多次调用加载多个关联，
+// association支持嵌套（"Orders.Items"）和 clause.Associations，conds与 gorm.DB.Preload 相同
+//
+//	ctx = gormx.WithPreload(ctx, "Orders", "status = ?", 1)
+//	users, err := userRepo.SelectByMap(ctx, condition)
+//
+// 只对声明了该关联的模型生效，ctx传给其他repo时不会影响它们的查询；带预加载的查询不走缓存
+// 支持 SelectOne、Select、SelectByPK、SelectByMap、PageSelect、加锁读和 SelectEach / Iterate
+func WithPreload(ctx context.Context, association string, conds ...any) context.Context {
+	preloads := preloadsFromCtx(ctx)
+	next := make([]preload, len(preloads), len(preloads)+1)
+	copy(next, preloads)
+	next = append(next, preload{association: association, conds: conds})
+	return context.WithValue(ctx, contextPreloadKey{}, next)
+}
+
+func preloadsFromCtx(ctx context.Context) []preload {
+	preloads, _ := ctx.Value(contextPreloadKey{}).([]preload)
+	return preloads
+}
+
+// modelPreloads ctx中对当前模型有效的预加载
+func (b *BaseRepo[T]) modelPreloads(ctx context.Context) []preload {
+	preloads := preloadsFromCtx(ctx)
+	if len(preloads) == 0 {
+		return nil
+	}
+	s, err := b.gormSchema()
+	if err != nil {
+		return nil
+	}
+	var res []preload
+	for _, p := range preloads {
+		name, _, _ := strings.Cut(p.association, ".")
+		if _, ok := s.Relationships.Relations[name]; ok || p.association == clause.Associations {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// withPreloads 在查询上追加ctx中的预加载
+func (b *BaseRepo[T]) withPreloads(ctx context.Context, db *gorm.DB) *gorm.DB {
+	for _, p := range b.modelPreloads(ctx) {
+		db = db.Preload(p.association, p.conds...)
+	}
+	return db
+}
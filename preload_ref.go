@@ -0,0 +1,57 @@
+package gormx
+
+import "context"
+
+// preloadRefChunkSize 每次 IN 查询的最大主键数量
+const preloadRefChunkSize = 1000
+
+// PreloadRef 批量加载items通过外键引用的target记录并回填，用于不声明gorm关联标签的模型
+//
+// fkGetter 返回外键值，返回nil或零值时跳过；引用的记录不存在时不会调用setter
+//
+// 示例：
+//
+//	err := gormx.PreloadRef(ctx, orders, func(o *Order) any { return o.UserID }, &userRepo,
+//		func(o *Order, u *User) { o.User = u })
+func PreloadRef[T, U any](ctx context.Context, items []*T, fkGetter func(*T) any, target *BaseRepo[U], setter func(*T, *U)) error {
+	var (
+		fks  []any
+		seen = make(map[string]struct{})
+	)
+	for _, item := range items {
+		fk := fkGetter(item)
+		if isZeroValue(fk) {
+			continue
+		}
+		key := cacheKeyPart(fk)
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			fks = append(fks, fk)
+		}
+	}
+
+	refs := make(map[string]*U, len(fks))
+	for start := 0; start < len(fks); start += preloadRefChunkSize {
+		end := min(start+preloadRefChunkSize, len(fks))
+		rows, err := target.SelectByPK(ctx, fks[start:end])
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if pk, ok := target.pkValue(ctx, row); ok {
+				refs[cacheKeyPart(pk)] = row
+			}
+		}
+	}
+
+	for _, item := range items {
+		fk := fkGetter(item)
+		if isZeroValue(fk) {
+			continue
+		}
+		if ref, ok := refs[cacheKeyPart(fk)]; ok {
+			setter(item, ref)
+		}
+	}
+	return nil
+}
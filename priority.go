@@ -0,0 +1,85 @@
+package gormx
+
+import (
+	"context"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// Priority 操作的优先级，通过 WithPriority 在ctx中标记
+type Priority int8
+
+const (
+	// PriorityInteractive 面向用户的请求，默认值，不受调度限制
+	PriorityInteractive Priority = iota
+	// PriorityBatch 后台任务、批量导入导出等，最多占用 PriorityScheduler 配置的连接数
+	PriorityBatch
+)
+
+type contextPriorityKey struct{}
+
+// WithPriority 标记ctx内数据库操作的优先级
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, contextPriorityKey{}, p)
+}
+
+func priorityFromCtx(ctx context.Context) Priority {
+	p, _ := ctx.Value(contextPriorityKey{}).(Priority)
+	return p
+}
+
+const prioritySlotKey = "gormx:priority_slot"
+
+// PriorityScheduler gorm插件，限制同时执行的批量操作数量，保证高峰期批量任务不会占满连接池
+//
+// 示例：连接池最大20个连接，批量任务最多使用其中5个
+//
+//	db.Use(gormx.NewPriorityScheduler(5))
+//	ctx = gormx.WithPriority(ctx, gormx.PriorityBatch)
+type PriorityScheduler struct {
+	slots   chan struct{}
+	waiting atomic.Int64
+	running atomic.Int64
+}
+
+// NewPriorityScheduler maxBatchConns为批量操作同时占用的最大连接数
+func NewPriorityScheduler(maxBatchConns int) *PriorityScheduler {
+	return &PriorityScheduler{slots: make(chan struct{}, max(maxBatchConns, 1))}
+}
+
+func (s *PriorityScheduler) Name() string {
+	return "gormx:priority_scheduler"
+}
+
+func (s *PriorityScheduler) Initialize(db *gorm.DB) error {
+	return registerAround(db, "gormx:priority", s.acquire, s.release)
+}
+
+func (s *PriorityScheduler) acquire(db *gorm.DB) {
+	ctx := db.Statement.Context
+	if ctx == nil || priorityFromCtx(ctx) != PriorityBatch || db.Error != nil {
+		return
+	}
+	s.waiting.Add(1)
+	defer s.waiting.Add(-1)
+	select {
+	case s.slots <- struct{}{}:
+		s.running.Add(1)
+		db.InstanceSet(prioritySlotKey, true)
+	case <-ctx.Done():
+		_ = db.AddError(ctx.Err())
+	}
+}
+
+func (s *PriorityScheduler) release(db *gorm.DB) {
+	if _, ok := db.InstanceGet(prioritySlotKey); ok {
+		s.running.Add(-1)
+		<-s.slots
+	}
+}
+
+// Stats 正在执行和等待执行的批量操作数量
+func (s *PriorityScheduler) Stats() (running, waiting int64) {
+	return s.running.Load(), s.waiting.Load()
+}
@@ -0,0 +1,122 @@
+package gormx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// ProcOut 存储过程的 OUT / INOUT 参数，调用结束后值写入Dest
+type ProcOut struct {
+	Dest  any
+	Value any
+	inOut bool
+}
+
+// Out OUT参数，dest为接收值的指针
+func Out(dest any) ProcOut {
+	return ProcOut{Dest: dest}
+}
+
+// InOut INOUT参数，value为传入值，调用结束后的值写入dest
+func InOut(dest any, value any) ProcOut {
+	return ProcOut{Dest: dest, Value: value, inOut: true}
+}
+
+// CallProc 调用存储过程，第一个结果集扫描到R，OUT参数通过 Out / InOut 接收，参与ctx中的事务
+//
+// 支持的方言：
+//   - mysql：OUT参数通过会话变量传递，在同一个连接上执行 CALL 后再 SELECT 取回
+//   - postgres：OUT参数以 NULL 占位，CALL 返回的单行结果写入OUT参数，存储过程没有结果集
+//
+// 示例：
+//
+//	var total int64
+//	rows, err := gormx.CallProc[Order](ctx, db, "list_orders", userID, gormx.Out(&total))
+func CallProc[R any](ctx context.Context, db *gorm.DB, name string, args ...any) ([]R, error) {
+	var res []R
+	tx := dbWithCtx(ctx, db)
+	var err error
+	switch tx.Dialector.Name() {
+	case "postgres":
+		err = callProcPostgres(tx, name, args)
+	default:
+		if _, inTx := ctx.Value(contextTxKey{}).(*gorm.DB); inTx {
+			err = callProcMySQL(tx, name, args, &res)
+		} else {
+			// 会话变量只在当前连接可见，需要固定连接
+			err = tx.Connection(func(conn *gorm.DB) error {
+				return callProcMySQL(conn, name, args, &res)
+			})
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: call proc %s error, args: %+v", name, args)
+	}
+	return res, nil
+}
+
+func callProcMySQL(tx *gorm.DB, name string, args []any, dest any) error {
+	var (
+		placeholders []string
+		callArgs     []any
+		outVars      []string
+		outDests     []any
+	)
+	for i, arg := range args {
+		out, ok := arg.(ProcOut)
+		if !ok {
+			placeholders = append(placeholders, "?")
+			callArgs = append(callArgs, arg)
+			continue
+		}
+		v := fmt.Sprintf("@gormx_out_%d", i)
+		if out.inOut {
+			if err := tx.Exec("SET "+v+" = ?", out.Value).Error; err != nil {
+				return err
+			}
+		}
+		placeholders = append(placeholders, v)
+		outVars = append(outVars, v)
+		outDests = append(outDests, out.Dest)
+	}
+	sql := fmt.Sprintf("CALL %s(%s)", name, strings.Join(placeholders, ", "))
+	if err := tx.Raw(sql, callArgs...).Scan(dest).Error; err != nil {
+		return err
+	}
+	if len(outVars) == 0 {
+		return nil
+	}
+	return tx.Raw("SELECT " + strings.Join(outVars, ", ")).Row().Scan(outDests...)
+}
+
+func callProcPostgres(tx *gorm.DB, name string, args []any) error {
+	var (
+		placeholders []string
+		callArgs     []any
+		outDests     []any
+	)
+	for _, arg := range args {
+		out, ok := arg.(ProcOut)
+		if !ok {
+			placeholders = append(placeholders, "?")
+			callArgs = append(callArgs, arg)
+			continue
+		}
+		if out.inOut {
+			placeholders = append(placeholders, "?")
+			callArgs = append(callArgs, out.Value)
+		} else {
+			placeholders = append(placeholders, "NULL")
+		}
+		outDests = append(outDests, out.Dest)
+	}
+	sql := fmt.Sprintf("CALL %s(%s)", name, strings.Join(placeholders, ", "))
+	if len(outDests) == 0 {
+		return tx.Exec(sql, callArgs...).Error
+	}
+	return tx.Raw(sql, callArgs...).Row().Scan(outDests...)
+}
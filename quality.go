@@ -0,0 +1,231 @@
+package gormx
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultQualityBatchSize  = 10000
+	DefaultQualitySampleSize = 10
+)
+
+// QualityRule 数据质量规则，通过 NotNullRatio、ValueRange、Reference、Freshness、CustomRule 构造
+type QualityRule struct {
+	Name string
+	// MaxRatio 允许的最大违规比例，超过时规则不通过
+	MaxRatio float64
+
+	// violation 违规记录的条件
+	violation func(quote func(string) string, table string) (string, []any)
+	// tableCheck 表级规则，返回违规数，db 已指定表并过滤已删除的记录
+	tableCheck func(ctx context.Context, db *gorm.DB, table string) (int64, error)
+}
+
+// NotNullRatio column非空的比例不能低于minRatio
+func NotNullRatio(column string, minRatio float64) QualityRule {
+	return QualityRule{
+		Name:     fmt.Sprintf("not_null_ratio(%s)", column),
+		MaxRatio: 1 - minRatio,
+		violation: func(quote func(string) string, _ string) (string, []any) {
+			return quote(column) + " IS NULL", nil
+		},
+	}
+}
+
+// ValueRange column的值必须在[min, max]之间，NULL不计为违规
+func ValueRange(column string, min, max any) QualityRule {
+	return QualityRule{
+		Name: fmt.Sprintf("value_range(%s)", column),
+		violation: func(quote func(string) string, _ string) (string, []any) {
+			return fmt.Sprintf("(%s < ? OR %s > ?)", quote(column), quote(column)), []any{min, max}
+		},
+	}
+}
+
+// Reference column的值必须在refTable.refColumn中存在，NULL不计为违规
+func Reference(column, refTable, refColumn string) QualityRule {
+	return QualityRule{
+		Name: fmt.Sprintf("reference(%s->%s.%s)", column, refTable, refColumn),
+		violation: func(quote func(string) string, table string) (string, []any) {
+			return fmt.Sprintf("%s IS NOT NULL AND NOT EXISTS (SELECT 1 FROM %s AS %s WHERE %s = %s)",
+				quote(table+"."+column), quote(refTable), quote("gormx_ref"),
+				quote("gormx_ref."+refColumn), quote(table+"."+column)), nil
+		},
+	}
+}
+
+// Freshness 表中column的最大值不能早于当前时间减去maxAge，用于检查数据是否停止写入
+func Freshness(column string, maxAge time.Duration) QualityRule {
+	return QualityRule{
+		Name: fmt.Sprintf("freshness(%s)", column),
+		tableCheck: func(ctx context.Context, db *gorm.DB, table string) (int64, error) {
+			var latest *time.Time
+			if err := db.Select("MAX(" + db.Statement.Quote(column) + ")").Scan(&latest).Error; err != nil {
+				return 0, err
+			}
+			if latest == nil || time.Since(*latest) > maxAge {
+				return 1, nil
+			}
+			return 0, nil
+		},
+	}
+}
+
+// CustomRule 自定义违规条件，例如 CustomRule("paid_without_time", "status = ? AND paid_at IS NULL", 0, 2)
+func CustomRule(name, violation string, maxRatio float64, args ...any) QualityRule {
+	return QualityRule{
+		Name:     name,
+		MaxRatio: maxRatio,
+		violation: func(func(string) string, string) (string, []any) {
+			return violation, args
+		},
+	}
+}
+
+// QualityResult 单条规则的检查结果
+type QualityResult struct {
+	Rule       string
+	Checked    int64
+	Violations int64
+	Ratio      float64
+	Passed     bool
+	// 违规记录的主键抽样
+	SamplePKs []any
+}
+
+// QualityReport 一次检查的报告，Score为各规则合规比例的平均值，范围0-100
+type QualityReport struct {
+	Table     string
+	Total     int64
+	Score     float64
+	Results   []QualityResult
+	CheckedAt time.Time
+}
+
+// QualityChecker 按主键分批对模型数据执行质量规则
+type QualityChecker[T any] struct {
+	repo       *BaseRepo[T]
+	rules      []QualityRule
+	BatchSize  int
+	SampleSize int
+}
+
+func NewQualityChecker[T any](repo *BaseRepo[T], rules ...QualityRule) *QualityChecker[T] {
+	return &QualityChecker[T]{
+		repo:       repo,
+		rules:      rules,
+		BatchSize:  DefaultQualityBatchSize,
+		SampleSize: DefaultQualitySampleSize,
+	}
+}
+
+// Run 执行一次检查
+func (q *QualityChecker[T]) Run(ctx context.Context) (*QualityReport, error) {
+	b := q.repo
+	db := b.readDB(ctx)
+	table := b.tableName()
+	quote := db.Statement.Quote
+	report := &QualityReport{Table: table, CheckedAt: time.Now()}
+	results := make([]QualityResult, len(q.rules))
+	for i, rule := range q.rules {
+		results[i].Rule = rule.Name
+	}
+
+	var last any
+	for {
+		var pks []any
+		query := db.Session(&gorm.Session{}).Table(table).Scopes(b.notDeleted)
+		if last != nil {
+			query = query.Where(quote(b.PrimaryKey)+" > ?", last)
+		}
+		if err := query.Order(quote(b.PrimaryKey)).Limit(q.BatchSize).Pluck(b.PrimaryKey, &pks).Error; err != nil {
+			return nil, errors.Wrapf(err, "db: quality check %s error, select batch after %v", b.StructName, last)
+		}
+		if len(pks) == 0 {
+			break
+		}
+		report.Total += int64(len(pks))
+		for i, rule := range q.rules {
+			if rule.violation == nil {
+				continue
+			}
+			if err := q.checkBatch(db, table, rule, pks[0], pks[len(pks)-1], &results[i]); err != nil {
+				return nil, err
+			}
+		}
+		if len(pks) < q.BatchSize {
+			break
+		}
+		last = pks[len(pks)-1]
+	}
+
+	var score float64
+	for i, rule := range q.rules {
+		r := &results[i]
+		if rule.tableCheck != nil {
+			// 每条规则从新的会话开始，之前规则的条件不会带入
+			violations, err := rule.tableCheck(ctx, db.Session(&gorm.Session{}).Table(table).Scopes(b.notDeleted), table)
+			if err != nil {
+				return nil, errors.Wrapf(err, "db: quality check %s error, rule: %s", b.StructName, rule.Name)
+			}
+			r.Checked, r.Violations = 1, violations
+		} else {
+			r.Checked = report.Total
+		}
+		if r.Checked > 0 {
+			r.Ratio = float64(r.Violations) / float64(r.Checked)
+		}
+		r.Passed = r.Ratio <= rule.MaxRatio
+		score += 1 - r.Ratio
+	}
+	if len(results) > 0 {
+		report.Score = score / float64(len(results)) * 100
+	}
+	report.Results = results
+	return report, nil
+}
+
+func (q *QualityChecker[T]) checkBatch(db *gorm.DB, table string, rule QualityRule, lo, hi any, r *QualityResult) error {
+	b := q.repo
+	quote := func(s string) string { return db.Statement.Quote(s) }
+	cond, args := rule.violation(quote, table)
+	query := func() *gorm.DB {
+		return db.Session(&gorm.Session{}).Table(table).Scopes(b.notDeleted).
+			Where(quote(table+"."+b.PrimaryKey)+" BETWEEN ? AND ?", lo, hi).
+			Where(cond, args...)
+	}
+	var count int64
+	if err := query().Count(&count).Error; err != nil {
+		return errors.Wrapf(err, "db: quality check %s error, rule: %s", b.StructName, rule.Name)
+	}
+	r.Violations += count
+	if need := q.SampleSize - len(r.SamplePKs); count > 0 && need > 0 {
+		var pks []any
+		if err := query().Limit(need).Pluck(table+"."+b.PrimaryKey, &pks).Error; err != nil {
+			return errors.Wrapf(err, "db: quality check %s error, sample rule: %s", b.StructName, rule.Name)
+		}
+		r.SamplePKs = append(r.SamplePKs, pks...)
+	}
+	return nil
+}
+
+// Start 按interval定时执行检查并通过publish发布报告，ctx结束时停止
+func (q *QualityChecker[T]) Start(ctx context.Context, interval time.Duration, publish func(*QualityReport, error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			publish(q.Run(ctx))
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,63 @@
+package gormx
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// RawSelect 执行原生查询并扫描到T，参与ctx中的事务；不会自动追加软删除条件
+// 支持 :name 命名参数，args传入 map[string]any 或 sql.Named
+func (b *BaseRepo[T]) RawSelect(ctx context.Context, sql string, args ...any) ([]*T, error) {
+	sql, args, err := bindNamed(sql, args)
+	if err != nil {
+		return nil, err
+	}
+	res, err := b.find(b.readDB(ctx).Raw(sql, args...))
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: raw select %s error, sql: %s, args: %+v", b.StructName, sql, args)
+	}
+	return res, nil
+}
+
+// RawExec 执行原生写语句，参与ctx中的事务，支持 :name 命名参数
+// 注：不会自动失效缓存
+func (b *BaseRepo[T]) RawExec(ctx context.Context, sql string, args ...any) (int64, error) {
+	sql, args, err := bindNamed(sql, args)
+	if err != nil {
+		return 0, err
+	}
+	tx := b.withTransactionCtx(ctx).Exec(sql, args...)
+	if err = tx.Error; err != nil {
+		return 0, errors.Wrapf(err, "db: raw exec %s error, sql: %s, args: %+v", b.StructName, sql, args)
+	}
+	return tx.RowsAffected, nil
+}
+
+// Query 执行原生查询并扫描到任意类型R（结构体、基础类型或map），用于报表、联表投影等不对应模型的查询
+// 参与ctx中的事务；R为基础类型时查询只能返回一列，例如 Query[int64](ctx, db, "SELECT id FROM ...")
+// 支持 :name 命名参数，args传入 map[string]any 或 sql.Named
+func Query[R any](ctx context.Context, db *gorm.DB, sql string, args ...any) ([]R, error) {
+	var res []R
+	sql, args, err := bindNamed(sql, args)
+	if err != nil {
+		return nil, err
+	}
+	if err = dbWithCtx(ctx, db).Raw(sql, args...).Scan(&res).Error; err != nil {
+		return nil, errors.Wrapf(err, "db: query error, sql: %s, args: %+v", sql, args)
+	}
+	return res, nil
+}
+
+// QueryOne 与 Query 相同，期望最多一行结果，没有结果时返回nil，多于一行时返回错误
+func QueryOne[R any](ctx context.Context, db *gorm.DB, sql string, args ...any) (*R, error) {
+	res, err := Query[R](ctx, db, sql, args...)
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+	if len(res) > 1 {
+		return nil, errors.Errorf("db: query one error, result must be one, now it is %d, sql: %s", len(res), sql)
+	}
+	return &res[0], nil
+}
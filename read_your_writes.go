@@ -0,0 +1,98 @@
+package gormx
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// RequireFresh ctx内的读操作只读主库，等同于 WithConsistency(ctx, Strong)
+func RequireFresh(ctx context.Context) context.Context {
+	return WithConsistency(ctx, Strong)
+}
+
+type contextWriteTrackerKey struct{}
+
+// writeTracker 记录一次请求内最近的写操作，在 freshUntil 之前读主库
+type writeTracker struct {
+	freshUntil atomic.Int64
+}
+
+func (t *writeTracker) mark(window time.Duration) {
+	until := time.Now().Add(window).UnixNano()
+	for {
+		old := t.freshUntil.Load()
+		if old >= until || t.freshUntil.CompareAndSwap(old, until) {
+			return
+		}
+	}
+}
+
+func (t *writeTracker) fresh() bool {
+	return time.Now().UnixNano() < t.freshUntil.Load()
+}
+
+// TrackWrites 在请求的入口（例如HTTP中间件）调用，之后通过该ctx执行的写操作会让同一个请求内的后续读操作
+// 在 WithReadYourWrites 配置的时间内读主库，避免创建后立即查询列表时从库还没有同步：
+//
+//	ctx = gormx.TrackWrites(ctx)
+//	_ = orderRepo.Insert(ctx, order)
+//	orders, _ := orderRepo.SelectByMap(ctx, condition) // 读主库
+//
+// 跨请求（例如同一个用户的下一次请求）需要读到自己的写入时，由调用方保存写入时间并使用 RequireFresh
+func TrackWrites(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(contextWriteTrackerKey{}).(*writeTracker); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, contextWriteTrackerKey{}, &writeTracker{})
+}
+
+// WithReadYourWrites 通过 TrackWrites 的ctx执行写操作后，同一个ctx内window时间内的读操作读主库，
+// window 应大于从库的正常延迟；事务内的写操作从事务提交时开始计时。
+// 计时从取主库连接时开始，SubQueryOf 等在主库执行的读操作同样会计时
+func WithReadYourWrites(window time.Duration) Option {
+	return func(o *options) {
+		o.stickyWindow = window
+	}
+}
+
+// markWrite 记录ctx内的写操作
+func (b *BaseRepo[T]) markWrite(ctx context.Context) {
+	if b.opts.stickyWindow <= 0 {
+		return
+	}
+	tracker, ok := ctx.Value(contextWriteTrackerKey{}).(*writeTracker)
+	if !ok {
+		return
+	}
+	tracker.mark(b.opts.stickyWindow)
+	if hooks := txHooksFromCtx(ctx); hooks != nil {
+		hooks.markOnCommit(tracker, b.opts.stickyWindow)
+	}
+}
+
+// markOnCommit 事务提交后再标记一次tracker，同一个事务内只注册一个回调，window取各次写操作的最大值
+func (h *txHooks) markOnCommit(t *writeTracker, window time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if old, ok := h.marks[t]; ok {
+		h.marks[t] = max(old, window)
+		return
+	}
+	if h.marks == nil {
+		h.marks = make(map[*writeTracker]time.Duration)
+	}
+	h.marks[t] = window
+	h.afterCommit = append(h.afterCommit, func(context.Context) {
+		h.mu.Lock()
+		w := h.marks[t]
+		h.mu.Unlock()
+		t.mark(w)
+	})
+}
+
+// freshRequired ctx内最近有写操作，读操作需要读主库
+func freshRequired(ctx context.Context) bool {
+	tracker, ok := ctx.Value(contextWriteTrackerKey{}).(*writeTracker)
+	return ok && tracker.fresh()
+}
@@ -0,0 +1,42 @@
+package gormx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SelectMissingFrom 查找在 otherTable 中不存在对应记录的数据，用于对账和数据质量检查
+//
+// joinCols 为本表列名到 otherTable 列名的映射，例如 {"order_no": "order_no"}
+// condition 只作用于本表，key兼容驼峰和蛇形
+//
+// 生成的SQL形如：
+//
+//	SELECT * FROM orders WHERE deleted != 2 AND ... AND NOT EXISTS (
+//		SELECT 1 FROM payments AS gormx_other WHERE gormx_other.order_no = orders.order_no)
+func (b *BaseRepo[T]) SelectMissingFrom(ctx context.Context, otherTable string, joinCols map[string]string, condition map[string]any) ([]*T, error) {
+	if len(joinCols) == 0 {
+		return nil, errors.Errorf("db: select %s missing from %s error, joinCols is empty", b.StructName, otherTable)
+	}
+	var m T
+	c := b.columnKeys(condition)
+	db := b.readDB(ctx)
+	quote := db.Statement.Quote
+	table := b.tableName()
+
+	on := make([]string, 0, len(joinCols))
+	for _, col := range SortedKeys(joinCols) {
+		on = append(on, fmt.Sprintf("%s = %s", quote("gormx_other."+joinCols[col]), quote(table+"."+Camel2Snake(col))))
+	}
+	notExists := fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s AS %s WHERE %s)",
+		quote(otherTable), quote("gormx_other"), strings.Join(on, " AND "))
+
+	res, err := b.find(db.Model(&m).Scopes(b.notDeleted).Scopes(whereCond(c)).Where(notExists))
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: select %s missing from %s error, joinCols: %v, condition: %v", b.StructName, otherTable, joinCols, condition)
+	}
+	return res, nil
+}
@@ -31,3 +31,24 @@ func Interface2Array(s any) []any {
 	}
 	return arr
 }
+
+// isZeroValue v为nil、空指针或者零值
+func isZeroValue(v any) bool {
+	rv := Indirect(reflect.ValueOf(v))
+	return !rv.IsValid() || rv.IsZero()
+}
+
+// indirectModel 解引用多级指针取到模型的struct，BaseRepo[*Pop] 时 *T 为 **Pop；
+// 中间为nil的指针在可以赋值时分配
+func indirectModel(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			if !v.CanSet() {
+				return v
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
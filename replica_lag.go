@@ -0,0 +1,187 @@
+package gormx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WithMaxReplicaLag 未指定一致性级别（Eventual）的读操作也只读延迟不超过maxLag的从库，没有满足条件的从库时读主库，
+// 需要同时配置 WithReplicaLag；需要更新鲜数据的读操作通过 WithConsistency 指定 Strong 或更小的 BoundedStaleness
+func WithMaxReplicaLag(maxLag time.Duration) Option {
+	return func(o *options) {
+		if o.replicas == nil {
+			o.replicas = &replicaSet{}
+		}
+		o.replicas.maxLag = maxLag
+	}
+}
+
+// MySQLReplicaLag 通过 SHOW REPLICA STATUS（8.0.22 之前为 SHOW SLAVE STATUS）的 Seconds_Behind_Source 获取延迟，
+// 复制线程停止或连接的不是从库时返回错误；精度为秒，需要更精确时使用 HeartbeatReplicaLag
+func MySQLReplicaLag(ctx context.Context, replica *gorm.DB) (time.Duration, error) {
+	status, err := showReplicaStatus(ctx, replica, "SHOW REPLICA STATUS")
+	if err != nil {
+		status, err = showReplicaStatus(ctx, replica, "SHOW SLAVE STATUS")
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "db: show replica status error")
+	}
+	if status == nil {
+		return 0, errors.New("db: show replica status error, not a replica")
+	}
+	for _, column := range []string{"Seconds_Behind_Source", "Seconds_Behind_Master"} {
+		v, ok := status[column]
+		if !ok {
+			continue
+		}
+		if !v.Valid {
+			return 0, errors.New("db: replication is not running")
+		}
+		seconds, err := strconv.ParseInt(v.String, 10, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "db: parse %s error", column)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, errors.New("db: show replica status error, Seconds_Behind_Source not found")
+}
+
+// showReplicaStatus 返回列名到值的映射，没有结果时返回nil
+func showReplicaStatus(ctx context.Context, replica *gorm.DB, query string) (map[string]sql.NullString, error) {
+	rows, err := replica.WithContext(ctx).Raw(query).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	status := make(map[string]sql.NullString, len(columns))
+	for i, column := range columns {
+		status[column] = values[i]
+	}
+	return status, nil
+}
+
+// PostgresReplicaLag 通过 pg_last_xact_replay_timestamp 获取延迟，已回放完收到的所有WAL时延迟为0
+// （否则主库没有写入时延迟会一直增长），连接的不是从库时返回错误
+func PostgresReplicaLag(ctx context.Context, replica *gorm.DB) (time.Duration, error) {
+	var seconds sql.NullFloat64
+	err := replica.WithContext(ctx).Raw(`SELECT CASE
+	WHEN NOT pg_is_in_recovery() THEN NULL
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END`).Scan(&seconds).Error
+	if err != nil {
+		return 0, errors.Wrap(err, "db: query replica lag error")
+	}
+	if !seconds.Valid {
+		return 0, errors.New("db: query replica lag error, not a replica")
+	}
+	return time.Duration(seconds.Float64 * float64(time.Second)), nil
+}
+
+// ReplicaHeartbeat 心跳表，主库定期写入当前时间，从库上读到的时间与当前时间的差即为延迟
+type ReplicaHeartbeat struct {
+	ID        int64     `gorm:"column:id;primaryKey;autoIncrement:false"`
+	UpdatedAt time.Time `gorm:"column:updated_at;NOT NULL"`
+}
+
+func (ReplicaHeartbeat) TableName() string {
+	return "gormx_heartbeat"
+}
+
+// MigrateReplicaHeartbeat 在主库创建心跳表
+func MigrateReplicaHeartbeat(ctx context.Context, primary *gorm.DB) error {
+	if err := primary.WithContext(ctx).AutoMigrate(&ReplicaHeartbeat{}); err != nil {
+		return errors.Wrap(err, "db: migrate gormx_heartbeat error")
+	}
+	return nil
+}
+
+// RunHeartbeat 每隔interval在主库写入一次心跳，直到ctx取消；多个实例同时写入没有影响，
+// 延迟的精度取决于interval，HeartbeatReplicaLag 的结果最多比实际延迟大一个interval
+func RunHeartbeat(ctx context.Context, primary *gorm.DB, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := primary.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).
+			Create(&ReplicaHeartbeat{ID: 1, UpdatedAt: time.Now()}).Error
+		if err != nil && ctx.Err() == nil {
+			return errors.Wrap(err, "db: write heartbeat error")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// HeartbeatReplicaLag 从心跳表读取延迟，需要在主库运行 RunHeartbeat；
+// 心跳时间由写入的应用服务器生成，各服务器之间的时钟偏差会计入延迟
+func HeartbeatReplicaLag(ctx context.Context, replica *gorm.DB) (time.Duration, error) {
+	var hb ReplicaHeartbeat
+	err := replica.WithContext(ctx).Where("id = ?", 1).Take(&hb).Error
+	if err != nil {
+		return 0, errors.Wrap(err, "db: read heartbeat error")
+	}
+	return max(0, time.Since(hb.UpdatedAt)), nil
+}
+
+type cachedLag struct {
+	lag       time.Duration
+	err       error
+	expiresAt time.Time
+}
+
+// CachedReplicaLag 缓存每个从库的延迟ttl时间，避免每次读操作都查询延迟；
+// 查询失败的结果同样缓存，该从库在ttl内不会被选中。同一个从库的并发查询合并为一次，
+// 查询期间不持有锁，不同从库的查询互不影响
+func CachedReplicaLag(lag ReplicaLagFunc, ttl time.Duration) ReplicaLagFunc {
+	var (
+		mu     sync.Mutex
+		cache  = make(map[*gorm.DB]cachedLag)
+		flight singleflightGroup
+	)
+	return func(ctx context.Context, replica *gorm.DB) (time.Duration, error) {
+		mu.Lock()
+		c, ok := cache[replica]
+		mu.Unlock()
+		if ok && time.Now().Before(c.expiresAt) {
+			return c.lag, c.err
+		}
+		v, err := flight.do(fmt.Sprintf("%p", replica), func() (any, error) {
+			d, err := lag(ctx, replica)
+			if err != nil && ctx.Err() != nil {
+				// 调用方取消不代表从库不可用，不缓存
+				return d, err
+			}
+			mu.Lock()
+			cache[replica] = cachedLag{lag: d, err: err, expiresAt: time.Now().Add(ttl)}
+			mu.Unlock()
+			return d, err
+		})
+		d, _ := v.(time.Duration)
+		return d, err
+	}
+}
@@ -0,0 +1,42 @@
+package gormx
+
+import "context"
+
+// Repo BaseRepo 常用的增删改查方法，业务代码依赖 Repo 而不是 *BaseRepo 时，单元测试可以替换为 FakeRepo：
+//
+//	type OrderService struct {
+//		orders gormx.Repo[Order]
+//	}
+//
+//	svc := OrderService{orders: &orderRepo}              // 生产代码
+//	svc := OrderService{orders: gormx.NewFakeRepo[Order]()} // 单元测试
+//
+// 依赖SQL或gorm的方法（RawSelect、ListPage、ExplainSelectByMap等）不在接口中
+type Repo[T any] interface {
+	Transaction
+
+	Insert(ctx context.Context, m *T) error
+	BatchInsert(ctx context.Context, m []*T, batchSize int) (int64, error)
+
+	DeleteByPK(ctx context.Context, pks any) (int64, error)
+	DeleteByMap(ctx context.Context, condition map[string]any) (int64, error)
+
+	UpdateByPK(ctx context.Context, t *T) (int64, error)
+	UpdateByPKWithMap(ctx context.Context, pk any, updateData map[string]any) (int64, error)
+	UpdateByMap(ctx context.Context, condition map[string]any, updateData map[string]any) (int64, error)
+	IncrementByPK(ctx context.Context, pk any, column string, delta int64) (int64, error)
+
+	SelectOne(ctx context.Context, condition *T) (*T, error)
+	SelectOneByPK(ctx context.Context, pk any) (*T, error)
+	SelectOneByMap(ctx context.Context, condition map[string]any) (*T, error)
+	Select(ctx context.Context, condition *T) ([]*T, error)
+	SelectAll(ctx context.Context) ([]*T, error)
+	SelectByPK(ctx context.Context, pks any) ([]*T, error)
+	SelectByMap(ctx context.Context, condition map[string]any) ([]*T, error)
+	PageSelect(ctx context.Context, page *PageParam, query any, args ...any) ([]*T, int32, error)
+}
+
+var (
+	_ Repo[struct{ ID int64 }] = (*BaseRepo[struct{ ID int64 }])(nil)
+	_ Repo[struct{ ID int64 }] = (*FakeRepo[struct{ ID int64 }])(nil)
+)
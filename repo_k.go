@@ -0,0 +1,68 @@
+package gormx
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// BaseRepoK 主键类型为K的 BaseRepo，按主键操作的方法参数为K，传错类型在编译时就能发现：
+//
+//	repo := gormx.NewBaseRepoK[Pop, int64](db)
+//	pop, err := repo.SelectOneByPK(ctx, int64(1))
+//	n, err := repo.DeleteByPK(ctx, []int64{1, 2})
+//
+// 其余方法与 BaseRepo 相同；复合主键的模型使用 BaseRepo 的 XxxByPKs
+type BaseRepoK[T any, K comparable] struct {
+	BaseRepo[T]
+}
+
+// NewBaseRepoK 同 NewBaseRepo，K为模型主键的类型
+func NewBaseRepoK[T any, K comparable](db *gorm.DB, opts ...Option) BaseRepoK[T, K] {
+	return BaseRepoK[T, K]{BaseRepo: NewBaseRepo[T](db, opts...)}
+}
+
+// Debug 同 BaseRepo.Debug
+func (b *BaseRepoK[T, K]) Debug() *BaseRepoK[T, K] {
+	return &BaseRepoK[T, K]{BaseRepo: *b.BaseRepo.Debug()}
+}
+
+// SelectOneByPK 根据主键查找
+func (b *BaseRepoK[T, K]) SelectOneByPK(ctx context.Context, pk K) (*T, error) {
+	return b.BaseRepo.SelectOneByPK(ctx, pk)
+}
+
+// SelectByPK 根据多个主键查找
+func (b *BaseRepoK[T, K]) SelectByPK(ctx context.Context, pks []K) ([]*T, error) {
+	return b.BaseRepo.SelectByPK(ctx, pks)
+}
+
+// DeleteByPK 根据多个主键删除
+func (b *BaseRepoK[T, K]) DeleteByPK(ctx context.Context, pks []K) (int64, error) {
+	return b.BaseRepo.DeleteByPK(ctx, pks)
+}
+
+// UpdateByPKWithMap 根据主键更新，支持零值，updateData的处理同 BaseRepo.UpdateByPKWithMap
+func (b *BaseRepoK[T, K]) UpdateByPKWithMap(ctx context.Context, pk K, updateData map[string]any) (int64, error) {
+	return b.BaseRepo.UpdateByPKWithMap(ctx, pk, updateData)
+}
+
+// IncrementByPK 原子地把主键为pk的记录的column加上delta
+func (b *BaseRepoK[T, K]) IncrementByPK(ctx context.Context, pk K, column string, delta int64) (int64, error) {
+	return b.BaseRepo.IncrementByPK(ctx, pk, column, delta)
+}
+
+// SelectOneByPKForUpdate 根据主键查找并加排他锁，只能在 InTx 内调用
+func (b *BaseRepoK[T, K]) SelectOneByPKForUpdate(ctx context.Context, pk K, opts ...LockOption) (*T, error) {
+	return b.BaseRepo.SelectOneByPKForUpdate(ctx, pk, opts...)
+}
+
+// SelectOneByPKForShare 根据主键查找并加共享锁，只能在 InTx 内调用
+func (b *BaseRepoK[T, K]) SelectOneByPKForShare(ctx context.Context, pk K, opts ...LockOption) (*T, error) {
+	return b.BaseRepo.SelectOneByPKForShare(ctx, pk, opts...)
+}
+
+// SelectOneByPKTracked 根据主键查找并跟踪修改，记录不存在时返回nil
+func (b *BaseRepoK[T, K]) SelectOneByPKTracked(ctx context.Context, pk K) (*Tracked[T], error) {
+	return b.BaseRepo.SelectOneByPKTracked(ctx, pk)
+}
@@ -0,0 +1,130 @@
+package gormx
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type consistencyKind int8
+
+const (
+	consistencyEventual consistencyKind = iota
+	consistencyBoundedStaleness
+	consistencyStrong
+)
+
+// ConsistencyLevel 单次查询的读一致性级别，由读写分离路由使用
+type ConsistencyLevel struct {
+	kind   consistencyKind
+	maxLag time.Duration
+}
+
+var (
+	// Strong 只读主库
+	Strong = ConsistencyLevel{kind: consistencyStrong}
+	// Eventual 任意从库，未配置从库时读主库
+	Eventual = ConsistencyLevel{kind: consistencyEventual}
+)
+
+// BoundedStaleness 只读延迟不超过maxLag的从库，没有满足条件的从库时读主库
+// 需要通过 WithReplicaLag 提供从库延迟，否则等同于 Strong
+func BoundedStaleness(maxLag time.Duration) ConsistencyLevel {
+	return ConsistencyLevel{kind: consistencyBoundedStaleness, maxLag: maxLag}
+}
+
+type contextConsistencyKey struct{}
+
+// WithConsistency 指定ctx内读操作的一致性级别，未指定时为 Eventual
+func WithConsistency(ctx context.Context, level ConsistencyLevel) context.Context {
+	return context.WithValue(ctx, contextConsistencyKey{}, level)
+}
+
+func consistencyFromCtx(ctx context.Context) ConsistencyLevel {
+	level, ok := ctx.Value(contextConsistencyKey{}).(ConsistencyLevel)
+	if !ok {
+		return Eventual
+	}
+	return level
+}
+
+// ReplicaLagFunc 返回从库当前的复制延迟
+type ReplicaLagFunc func(ctx context.Context, replica *gorm.DB) (time.Duration, error)
+
+type replicaSet struct {
+	dbs    []*gorm.DB
+	lag    ReplicaLagFunc
+	maxLag time.Duration
+	next   atomic.Uint64
+}
+
+// WithReplicas 开启读写分离，读操作按一致性级别路由到从库，写操作和事务内的读操作始终走主库
+func WithReplicas(replicas ...*gorm.DB) Option {
+	return func(o *options) {
+		if o.replicas == nil {
+			o.replicas = &replicaSet{}
+		}
+		o.replicas.dbs = append(o.replicas.dbs, replicas...)
+	}
+}
+
+// WithReplicaLag 提供从库延迟，BoundedStaleness 依赖此配置
+func WithReplicaLag(lag ReplicaLagFunc) Option {
+	return func(o *options) {
+		if o.replicas == nil {
+			o.replicas = &replicaSet{}
+		}
+		o.replicas.lag = lag
+	}
+}
+
+// pick 轮询选择满足一致性级别的从库，返回nil表示应读主库
+func (r *replicaSet) pick(ctx context.Context, level ConsistencyLevel) *gorm.DB {
+	if r == nil || len(r.dbs) == 0 || level.kind == consistencyStrong {
+		return nil
+	}
+	start := r.next.Add(1)
+	n := uint64(len(r.dbs))
+	if level.kind == consistencyEventual {
+		if r.maxLag <= 0 || r.lag == nil {
+			return r.dbs[start%n]
+		}
+		level.maxLag = r.maxLag
+	}
+	if r.lag == nil {
+		return nil
+	}
+	for i := uint64(0); i < n; i++ {
+		replica := r.dbs[(start+i)%n]
+		if lag, err := r.lag(ctx, replica); err == nil && lag <= level.maxLag {
+			return replica
+		}
+	}
+	return nil
+}
+
+// readsPrimary 读操作一定读主库：未配置从库、Strong、事务内或ctx内最近有写操作
+func (b *BaseRepo[T]) readsPrimary(ctx context.Context) bool {
+	if _, ok := ctx.Value(contextTxKey{}).(*gorm.DB); ok {
+		return true
+	}
+	r := b.opts.replicas
+	return r == nil || len(r.dbs) == 0 || consistencyFromCtx(ctx).kind == consistencyStrong || freshRequired(ctx)
+}
+
+// readDB 读操作取db连接时均采用此方法，事务内读主库，否则按ctx中的一致性级别路由，ctx内最近有写操作时读主库
+func (b *BaseRepo[T]) readDB(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(contextTxKey{}).(*gorm.DB); ok {
+		return b.preflight(ctx, b.withSettings(b.withDebug(withDryRun(ctx, tx)))).Session(&gorm.Session{})
+	}
+	level := consistencyFromCtx(ctx)
+	if freshRequired(ctx) {
+		level = Strong
+	}
+	if replica := b.opts.replicas.pick(ctx, level); replica != nil {
+		return b.preflight(ctx, b.withSettings(b.withDebug(withDryRun(ctx, replica.WithContext(ctx))))).Session(&gorm.Session{})
+	}
+	return b.preflight(ctx, b.withSettings(b.withDebug(withDryRun(ctx, b.GormDB.WithContext(ctx))))).Session(&gorm.Session{})
+}
@@ -0,0 +1,212 @@
+package gormx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// mysql: 1053 服务关闭、1290/1836 只读（主从切换后连到了从库）、2006/2013 连接断开
+var mysqlTransientCodes = map[uint64]struct{}{1053: {}, 1290: {}, 1836: {}, 2006: {}, 2013: {}}
+
+// postgres: 08xxx 连接异常、57P01/57P02/57P03 服务关闭或正在启动、25006 只读事务
+var pgTransientStates = map[string]struct{}{
+	"08000": {}, "08001": {}, "08003": {}, "08004": {}, "08006": {},
+	"57P01": {}, "57P02": {}, "57P03": {}, "25006": {},
+}
+
+// IsTransientConnError 判断是否为连接被拒绝、连接断开、主从切换等重新执行可能成功的错误
+func IsTransientConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	msg := err.Error()
+	for _, s := range []string{"connection refused", "bad connection", "broken pipe", "connection reset by peer",
+		"invalid connection", "server has gone away", "read-only", "the database system is shutting down",
+		"the database system is starting up"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return matchDBError(err, pgTransientStates, mysqlTransientCodes)
+}
+
+type contextIdempotentKey struct{}
+
+// IdempotentWrites 标记ctx内的写操作是幂等的（例如按主键的 UpdateByPK、Upsert），RetryPlugin 才会重试
+func IdempotentWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextIdempotentKey{}, true)
+}
+
+// RetryPlugin gorm插件，事务外的读操作遇到临时的连接错误时按策略重试：
+//
+//	db.Use(gormx.RetryPlugin{Budget: 100})
+//
+// 只重试执行语句时返回的错误，读取结果过程中断开的连接不重试；事务内的语句不重试，由 InTxWithRetry 重试整个事务。
+// 写操作（包括 INSERT ... RETURNING）可能已经在数据库端执行成功，只有ctx通过 IdempotentWrites 标记后才重试
+type RetryPlugin struct {
+	// 为空时 MaxAttempts 为 DefaultTxMaxAttempts，Backoff 为 ExponentialBackoff(50ms, 1s)，
+	// RetryableErrors 为 IsTransientConnError
+	Policy RetryPolicy
+	// 每秒最多重试的次数，超过时直接返回错误，避免数据库故障时重试放大请求量，为0时不限制
+	Budget int
+}
+
+func (RetryPlugin) Name() string {
+	return "gormx:retry"
+}
+
+func (p RetryPlugin) Initialize(db *gorm.DB) error {
+	if p.Policy.MaxAttempts <= 0 {
+		p.Policy.MaxAttempts = DefaultTxMaxAttempts
+	}
+	if p.Policy.Backoff == nil {
+		p.Policy.Backoff = ExponentialBackoff(50*time.Millisecond, time.Second)
+	}
+	if p.Policy.RetryableErrors == nil {
+		p.Policy.RetryableErrors = IsTransientConnError
+	}
+	pool := &retryPool{ConnPool: db.ConnPool, policy: p.Policy, budget: &retryBudget{limit: p.Budget}}
+	db.ConnPool = pool
+	db.Statement.ConnPool = pool
+	return nil
+}
+
+// retryBudget 按秒计数的重试预算
+type retryBudget struct {
+	limit  int
+	mu     sync.Mutex
+	second int64
+	used   int
+}
+
+func (b *retryBudget) take() bool {
+	if b.limit <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now := time.Now().Unix(); now != b.second {
+		b.second, b.used = now, 0
+	}
+	if b.used >= b.limit {
+		return false
+	}
+	b.used++
+	return true
+}
+
+// retryPool 包装连接池，开启事务时返回原始的事务，事务内不重试
+type retryPool struct {
+	gorm.ConnPool
+	policy RetryPolicy
+	budget *retryBudget
+}
+
+// retry 执行fn，可重试的错误按策略重新执行
+func (p *retryPool) retry(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !p.policy.RetryableErrors(err) || attempt >= p.policy.MaxAttempts || !p.budget.take() {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(p.policy.Backoff(attempt)):
+		}
+	}
+}
+
+func (p *retryPool) ExecContext(ctx context.Context, query string, args ...any) (res sql.Result, err error) {
+	if idempotent, _ := ctx.Value(contextIdempotentKey{}).(bool); !idempotent {
+		return p.ConnPool.ExecContext(ctx, query, args...)
+	}
+	err = p.retry(ctx, func() (err error) {
+		res, err = p.ConnPool.ExecContext(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
+
+func (p *retryPool) QueryContext(ctx context.Context, query string, args ...any) (rows *sql.Rows, err error) {
+	if !p.canRetry(ctx, query) {
+		return p.ConnPool.QueryContext(ctx, query, args...)
+	}
+	err = p.retry(ctx, func() (err error) {
+		rows, err = p.ConnPool.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+func (p *retryPool) QueryRowContext(ctx context.Context, query string, args ...any) (row *sql.Row) {
+	if !p.canRetry(ctx, query) {
+		return p.ConnPool.QueryRowContext(ctx, query, args...)
+	}
+	_ = p.retry(ctx, func() error {
+		row = p.ConnPool.QueryRowContext(ctx, query, args...)
+		return row.Err()
+	})
+	return row
+}
+
+// canRetry 只读语句或标记为幂等的写操作
+func (p *retryPool) canRetry(ctx context.Context, query string) bool {
+	if idempotent, _ := ctx.Value(contextIdempotentKey{}).(bool); idempotent {
+		return true
+	}
+	return isReadSQL(query)
+}
+
+// isReadSQL 跳过开头的注释后是否为 SELECT（不加锁）或 WITH 开头的语句
+func isReadSQL(query string) bool {
+	q := strings.TrimSpace(query)
+	for strings.HasPrefix(q, "/*") {
+		end := strings.Index(q, "*/")
+		if end < 0 {
+			return false
+		}
+		q = strings.TrimSpace(q[end+2:])
+	}
+	upper := strings.ToUpper(q)
+	if strings.Contains(upper, " FOR UPDATE") || strings.Contains(upper, " FOR SHARE") {
+		return false
+	}
+	if strings.HasPrefix(upper, "WITH") {
+		// postgres 的 CTE 中可以有写操作
+		return !strings.Contains(upper, "INSERT ") && !strings.Contains(upper, "UPDATE ") && !strings.Contains(upper, "DELETE ")
+	}
+	return strings.HasPrefix(upper, "SELECT")
+}
+
+// BeginTx 实现 gorm.ConnPoolBeginner，事务使用原始的连接池
+func (p *retryPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	switch beginner := p.ConnPool.(type) {
+	case gorm.TxBeginner:
+		return beginner.BeginTx(ctx, opts)
+	case gorm.ConnPoolBeginner:
+		return beginner.BeginTx(ctx, opts)
+	}
+	return nil, gorm.ErrInvalidTransaction
+}
+
+// GetDBConn 实现 gorm.GetDBConnector，db.DB() 返回原始的 *sql.DB
+func (p *retryPool) GetDBConn() (*sql.DB, error) {
+	if sqlDB, ok := p.ConnPool.(*sql.DB); ok {
+		return sqlDB, nil
+	}
+	if c, ok := p.ConnPool.(gorm.GetDBConnector); ok {
+		return c.GetDBConn()
+	}
+	return nil, gorm.ErrInvalidDB
+}
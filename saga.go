@@ -0,0 +1,274 @@
+package gormx
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// ErrSagaClaimed saga实例已被其他进程接管，当前进程停止执行
+var ErrSagaClaimed = errors.New("db: saga is claimed by another process")
+
+// SagaStatus saga的状态
+type SagaStatus string
+
+const (
+	SagaRunning      SagaStatus = "running"
+	SagaCompensating SagaStatus = "compensating"
+	SagaCompleted    SagaStatus = "completed"
+	SagaCompensated  SagaStatus = "compensated"
+)
+
+// SagaRecord saga的执行状态，每个saga实例一行；Step 为下一个要执行的步骤，补偿时为下一个要补偿的步骤+1，
+// Owner 为当前执行的进程的标识，只有 Owner 匹配时才能保存进度
+type SagaRecord struct {
+	ID        string     `gorm:"column:id;size:191;primaryKey"`
+	Name      string     `gorm:"column:name;size:128;index:idx_gormx_sagas_status,priority:1;NOT NULL"`
+	Status    SagaStatus `gorm:"column:status;size:32;index:idx_gormx_sagas_status,priority:2;NOT NULL"`
+	Step      int        `gorm:"column:step;NOT NULL"`
+	Payload   string     `gorm:"column:payload;type:text"`
+	Error     string     `gorm:"column:error;type:text"`
+	Owner     string     `gorm:"column:owner;size:64;NOT NULL;default:''"`
+	CreatedAt time.Time  `gorm:"column:created_at;NOT NULL"`
+	UpdatedAt time.Time  `gorm:"column:updated_at;NOT NULL"`
+}
+
+func (SagaRecord) TableName() string {
+	return "gormx_sagas"
+}
+
+// MigrateSaga 创建saga的状态表
+func MigrateSaga(ctx context.Context, db *gorm.DB) error {
+	if err := db.WithContext(ctx).AutoMigrate(&SagaRecord{}); err != nil {
+		return errors.Wrap(err, "db: migrate gormx_sagas error")
+	}
+	return nil
+}
+
+// SagaStep saga的一个步骤，Do 和 Compensate 可以修改payload，修改后的payload随进度保存，
+// 例如 Do 创建的记录的ID保存在payload中，Compensate 据此删除
+type SagaStep[P any] struct {
+	Name       string
+	Do         func(ctx context.Context, p *P) error
+	Compensate func(ctx context.Context, p *P) error
+}
+
+// Saga 无法放在一个事务中的多步操作（跨库、调用外部服务），步骤失败时按相反顺序补偿已完成的步骤：
+//
+//	saga := gormx.NewSaga(db, "place-order",
+//		gormx.SagaStep[Order]{Name: "reserve", Do: reserveStock, Compensate: releaseStock},
+//		gormx.SagaStep[Order]{Name: "charge", Do: charge, Compensate: refund},
+//	)
+//	err := saga.Run(ctx, orderNo, &order)
+//
+// 每个步骤完成后保存进度，进程崩溃后由 Resume 继续执行或补偿。
+// 步骤可能在完成后、保存进度前崩溃，恢复时会再执行一次，Do 和 Compensate 都需要是幂等的
+type Saga[P any] struct {
+	db    *gorm.DB
+	name  string
+	steps []SagaStep[P]
+}
+
+// NewSaga 状态表需要提前通过 MigrateSaga 创建，name 相同的saga的步骤需要保持一致，
+// 修改步骤时只能在末尾追加，否则恢复时会执行错误的步骤
+func NewSaga[P any](db *gorm.DB, name string, steps ...SagaStep[P]) *Saga[P] {
+	return &Saga[P]{db: db, name: name, steps: steps}
+}
+
+// Run 执行id对应的saga实例，id已存在时与 Resume 一样接管后继续执行未完成的实例，已结束的实例直接返回
+//
+// 步骤失败并补偿完成时返回步骤的错误；补偿失败时返回补偿的错误，实例保持补偿中，由 Resume 重试；
+// 实例被其他进程接管时返回 ErrSagaClaimed
+func (s *Saga[P]) Run(ctx context.Context, id string, p *P) error {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return errors.Wrapf(err, "db: run saga %s error, marshal payload", s.name)
+	}
+	owner, err := sagaOwner(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "db: run saga %s error, generate owner", s.name)
+	}
+	now := time.Now()
+	rec := &SagaRecord{}
+	res := s.db.WithContext(ctx).Where(SagaRecord{ID: id}).
+		Attrs(SagaRecord{Name: s.name, Status: SagaRunning, Payload: string(payload), Owner: owner, CreatedAt: now, UpdatedAt: now}).
+		FirstOrCreate(rec)
+	if res.Error != nil {
+		return errors.Wrapf(res.Error, "db: run saga %s error, id: %s", s.name, id)
+	}
+	if rec.Name != s.name {
+		return errors.Errorf("db: run saga %s error, id %s belongs to saga %s", s.name, id, rec.Name)
+	}
+	if res.RowsAffected == 0 {
+		if rec.Status == SagaRunning || rec.Status == SagaCompensating {
+			claimed, err := s.claim(ctx, rec)
+			if err != nil {
+				return errors.Wrapf(err, "db: run saga %s error, claim %s", s.name, id)
+			}
+			if !claimed {
+				return errors.Wrapf(ErrSagaClaimed, "db: run saga %s error, id: %s", s.name, id)
+			}
+		}
+		if rec.Payload != "" {
+			// 继续执行已有的实例，使用保存的payload
+			if err := json.Unmarshal([]byte(rec.Payload), p); err != nil {
+				return errors.Wrapf(err, "db: run saga %s error, unmarshal payload of %s", s.name, id)
+			}
+		}
+	}
+	return s.execute(ctx, rec, p)
+}
+
+// sagaOwner 生成执行进程的标识，每次接管都不同
+func sagaOwner(ctx context.Context) (string, error) {
+	id, err := UUIDv4.NextID(ctx)
+	if err != nil {
+		return "", err
+	}
+	return id.(string), nil
+}
+
+// claim 通过 updated_at 和 owner 乐观锁接管实例，返回false表示已被其他进程接管
+func (s *Saga[P]) claim(ctx context.Context, rec *SagaRecord) (bool, error) {
+	owner, err := sagaOwner(ctx)
+	if err != nil {
+		return false, err
+	}
+	claimed := time.Now()
+	res := s.db.WithContext(ctx).Model(&SagaRecord{}).
+		Where("id = ? AND updated_at = ? AND owner = ?", rec.ID, rec.UpdatedAt, rec.Owner).
+		Updates(map[string]any{"owner": owner, "updated_at": claimed})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	rec.Owner, rec.UpdatedAt = owner, claimed
+	return true, nil
+}
+
+// Resume 继续执行或补偿超过 staleAfter 没有更新的未结束实例（执行它们的进程已经崩溃），返回处理的实例数
+//
+// 多个实例同时调用时，通过 updated_at 和 owner 乐观锁保证每个saga实例只由一个进程接管，被接管的进程保存进度时停止；
+// staleAfter 需要大于单个步骤的最长耗时，否则正在执行的实例会被接管
+func (s *Saga[P]) Resume(ctx context.Context, staleAfter time.Duration) (int, error) {
+	var recs []*SagaRecord
+	err := s.db.WithContext(ctx).
+		Where("name = ? AND status IN ? AND updated_at < ?", s.name, []SagaStatus{SagaRunning, SagaCompensating}, time.Now().Add(-staleAfter)).
+		Order("created_at").Find(&recs).Error
+	if err != nil {
+		return 0, errors.Wrapf(err, "db: resume saga %s error", s.name)
+	}
+	resumed := 0
+	for _, rec := range recs {
+		if ctx.Err() != nil {
+			return resumed, ctx.Err()
+		}
+		claimed, err := s.claim(ctx, rec)
+		if err != nil {
+			return resumed, errors.Wrapf(err, "db: resume saga %s error, claim %s", s.name, rec.ID)
+		}
+		if !claimed {
+			// 已被其他进程接管
+			continue
+		}
+		p := new(P)
+		if rec.Payload != "" {
+			if err := json.Unmarshal([]byte(rec.Payload), p); err != nil {
+				return resumed, errors.Wrapf(err, "db: resume saga %s error, unmarshal payload of %s", s.name, rec.ID)
+			}
+		}
+		resumed++
+		if err := s.execute(ctx, rec, p); err != nil {
+			slog.WarnContext(ctx, "gormx: resume saga", "saga", s.name, "id", rec.ID, "status", rec.Status, "error", err)
+		}
+	}
+	return resumed, nil
+}
+
+// execute 从rec的进度开始执行或补偿
+func (s *Saga[P]) execute(ctx context.Context, rec *SagaRecord, p *P) error {
+	var stepErr error
+	if rec.Status == SagaRunning {
+		for rec.Step < len(s.steps) {
+			step := s.steps[rec.Step]
+			if err := step.Do(ctx, p); err != nil {
+				stepErr = errors.Wrapf(err, "db: saga %s step %s error", s.name, step.Name)
+				break
+			}
+			rec.Step++
+			if rec.Step == len(s.steps) {
+				rec.Status = SagaCompleted
+			}
+			if err := s.save(ctx, rec, p); err != nil {
+				return err
+			}
+		}
+		if stepErr == nil {
+			return nil
+		}
+		rec.Status, rec.Error = SagaCompensating, stepErr.Error()
+		if err := s.save(ctx, rec, p); err != nil {
+			return err
+		}
+	}
+	if rec.Status != SagaCompensating {
+		if rec.Error != "" {
+			return errors.Errorf("db: saga %s %s error: %s", s.name, rec.Status, rec.Error)
+		}
+		return nil
+	}
+	for rec.Step > 0 {
+		step := s.steps[rec.Step-1]
+		if step.Compensate != nil {
+			if err := step.Compensate(ctx, p); err != nil {
+				return errors.Wrapf(err, "db: saga %s compensate %s error", s.name, step.Name)
+			}
+		}
+		rec.Step--
+		if rec.Step == 0 {
+			rec.Status = SagaCompensated
+		}
+		if err := s.save(ctx, rec, p); err != nil {
+			return err
+		}
+	}
+	if rec.Status != SagaCompensated {
+		rec.Status = SagaCompensated
+		if err := s.save(ctx, rec, p); err != nil {
+			return err
+		}
+	}
+	if stepErr == nil {
+		stepErr = errors.Errorf("db: saga %s compensated: %s", s.name, rec.Error)
+	}
+	return stepErr
+}
+
+// save 保存进度和payload，只有当前进程仍是实例的 Owner 时才会保存，否则返回 ErrSagaClaimed
+func (s *Saga[P]) save(ctx context.Context, rec *SagaRecord, p *P) error {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return errors.Wrapf(err, "db: save saga %s error, marshal payload", s.name)
+	}
+	rec.Payload, rec.UpdatedAt = string(payload), time.Now()
+	res := s.db.WithContext(ctx).Model(&SagaRecord{}).Where("id = ? AND owner = ?", rec.ID, rec.Owner).Updates(map[string]any{
+		"status":     rec.Status,
+		"step":       rec.Step,
+		"payload":    rec.Payload,
+		"error":      rec.Error,
+		"updated_at": rec.UpdatedAt,
+	})
+	if res.Error != nil {
+		return errors.Wrapf(res.Error, "db: save saga %s error, id: %s, step: %d", s.name, rec.ID, rec.Step)
+	}
+	if res.RowsAffected == 0 {
+		return errors.Wrapf(ErrSagaClaimed, "db: save saga %s error, id: %s, step: %d", s.name, rec.ID, rec.Step)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package gormx
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// Save 主键为零值时插入，否则按主键更新所有字段（包括零值），记录不存在时插入
+//
+// 更新时不会修改：主键、autoCreateTime字段、值为零值且有数据库默认值的字段（例如 ModelBaseInfo 的 CreateAt、UpdateAt、Deleted），
+// 与插入时由数据库填充默认值的行为一致；模型带有乐观锁版本号时按 UpdateByPK 更新非零字段
+func (b *BaseRepo[T]) Save(ctx context.Context, m *T) error {
+	if _, ok := b.pkValue(ctx, m); !ok {
+		return b.Insert(ctx, m)
+	}
+	if b.versionField() != nil {
+		_, err := b.UpdateByPK(ctx, m)
+		return err
+	}
+	s, err := b.gormSchema()
+	if err != nil {
+		return err
+	}
+
+	rv := indirectModel(reflect.ValueOf(m))
+	columns := make([]string, 0, len(s.Fields))
+	for _, field := range s.Fields {
+		if field.DBName == "" || field.DBName == b.PrimaryKey || field.AutoCreateTime > 0 {
+			continue
+		}
+		if _, isZero := field.ValueOf(ctx, rv); isZero && field.HasDefaultValue {
+			continue
+		}
+		columns = append(columns, field.DBName)
+	}
+
+	tx := b.withTransactionCtx(ctx).Model(m).Select(columns).Updates(m)
+	if err = tx.Error; err != nil {
+		return errors.Wrapf(err, "db: save %s error, param: %+v", b.StructName, m)
+	}
+	if tx.RowsAffected == 0 {
+		// mysql 值未变化时影响行数也为0，需要确认记录是否存在
+		var (
+			n     int64
+			model T
+		)
+		pk, _ := b.pkValue(ctx, m)
+		if err = b.withTransactionCtx(ctx).Model(&model).Where(map[string]any{b.PrimaryKey: pk}).Count(&n).Error; err != nil {
+			return errors.Wrapf(err, "db: save %s error, check exists, pk: %v", b.StructName, pk)
+		}
+		if n == 0 {
+			return b.Insert(ctx, m)
+		}
+	}
+	pk, _ := b.pkValue(ctx, m)
+	return b.invalidateCache(ctx, []any{pk}, b.rowColumns(ctx, m, true))
+}
@@ -0,0 +1,94 @@
+package gormx
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+// ModelSchema 模型的元数据，按模型类型在包级别缓存，每个类型只通过反射解析一次
+type ModelSchema struct {
+	// struct名称
+	Name string
+	// 列名，按字段顺序；列名使用gorm默认的命名规则，与db上自定义的 NamingStrategy 无关
+	Columns []string
+	// 主键列名，复合主键时为第一个主键列，没有主键时为空
+	PrimaryKey string
+	// 所有主键列名，复合主键时有多个
+	PrimaryKeys []string
+	// 带有gorm标签 autoCreateTime、autoUpdateTime 的字段名，UpdateByMap 时从updateData中删除
+	AutoTimeFields []string
+	// 软删除列，嵌入 ModelBaseInfo 时为 deleted，使用 gorm.DeletedAt 时为其列名，都没有时为空
+	SoftDeleteColumn string
+	// 是否使用gorm的 gorm.DeletedAt 软删除
+	GormDeletedAt bool
+}
+
+type modelSchemaEntry struct {
+	once   sync.Once
+	schema *ModelSchema
+}
+
+var modelSchemas sync.Map // reflect.Type -> *modelSchemaEntry
+
+// Schema 模型的元数据
+func (b *BaseRepo[T]) Schema() *ModelSchema {
+	return modelSchemaOf[T]()
+}
+
+func modelSchemaOf[T any]() *ModelSchema {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	v, _ := modelSchemas.LoadOrStore(t, &modelSchemaEntry{})
+	e := v.(*modelSchemaEntry)
+	e.once.Do(func() {
+		e.schema = parseModelSchema(t)
+	})
+	return e.schema
+}
+
+// parseModelSchema T为指针（包括多级指针）时按指向的struct解析
+func parseModelSchema(t reflect.Type) *ModelSchema {
+	t = IndirectType(t)
+	s := &ModelSchema{Name: t.Name()}
+	keys, hasId := recursiveParsePrimaryKeys(t)
+	if len(keys) == 0 && hasId {
+		keys = []string{"id"}
+	}
+	if len(keys) > 0 {
+		s.PrimaryKey, s.PrimaryKeys = keys[0], keys
+	}
+	recursiveParseAutoTime(t, s)
+	// 解析失败（例如T不是struct）时只有名称和主键
+	if gs, err := schema.Parse(reflect.New(t).Interface(), &sync.Map{}, schema.NamingStrategy{}); err == nil {
+		s.Columns = append(s.Columns, gs.DBNames...)
+		if f, gormDeletedAt := softDeleteField(gs); f != nil {
+			s.SoftDeleteColumn, s.GormDeletedAt = f.DBName, gormDeletedAt
+		}
+	}
+	return s
+}
+
+func recursiveParseAutoTime(t reflect.Type, s *ModelSchema) {
+	t = IndirectType(t)
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			recursiveParseAutoTime(field.Type, s)
+			continue
+		}
+		tag := field.Tag.Get("gorm")
+		autoCreateTime := strings.Contains(tag, "autoCreateTime") &&
+			!strings.Contains(tag, "autoCreateTime:false")
+
+		autoUpdateTime := strings.Contains(tag, "autoUpdateTime") &&
+			!strings.Contains(tag, "autoUpdateTime:false")
+		if autoCreateTime || autoUpdateTime {
+			s.AutoTimeFields = append(s.AutoTimeFields, field.Name)
+		}
+	}
+}
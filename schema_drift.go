@@ -0,0 +1,147 @@
+package gormx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// DriftKind 表结构与模型不一致的类型
+type DriftKind string
+
+const (
+	DriftMissingTable  DriftKind = "missing_table"
+	DriftMissingColumn DriftKind = "missing_column"
+	DriftTypeMismatch  DriftKind = "type_mismatch"
+	DriftSizeMismatch  DriftKind = "size_mismatch"
+	// DriftNullable 模型为 NOT NULL，数据库的列允许NULL
+	DriftNullable     DriftKind = "nullable"
+	DriftMissingIndex DriftKind = "missing_index"
+)
+
+// SchemaDrift 一处不一致，Expected 为模型对应的定义，Actual 为数据库中的定义
+type SchemaDrift struct {
+	Table    string
+	Column   string
+	Index    string
+	Kind     DriftKind
+	Expected string
+	Actual   string
+}
+
+func (d SchemaDrift) String() string {
+	target := d.Table
+	if d.Column != "" {
+		target += "." + d.Column
+	}
+	if d.Index != "" {
+		target += " index " + d.Index
+	}
+	switch {
+	case d.Expected == "" && d.Actual == "":
+		return fmt.Sprintf("%s: %s", target, d.Kind)
+	case d.Actual == "":
+		return fmt.Sprintf("%s: %s, expected %s", target, d.Kind, d.Expected)
+	}
+	return fmt.Sprintf("%s: %s, expected %s, actual %s", target, d.Kind, d.Expected, d.Actual)
+}
+
+// ValidateSchema 对比T的字段、类型和索引与数据库中的表结构，返回不一致的地方，表结构一致时返回空
+// 只检查模型中有的列和索引，数据库中多出的列、索引不算不一致；类型按 AutoMigrate 的规则比较（包括类型别名）
+func (b *BaseRepo[T]) ValidateSchema(ctx context.Context) ([]SchemaDrift, error) {
+	var m T
+	return ValidateSchema(ctx, b.GormDB, &m)
+}
+
+// ValidateAll 对 RegisterModel 登记的所有模型执行 ValidateSchema，通常在服务启动时调用：
+//
+//	drifts, err := gormx.ValidateAll(ctx, db)
+//	for _, d := range drifts {
+//		log.Println(d)
+//	}
+func ValidateAll(ctx context.Context, db *gorm.DB) ([]SchemaDrift, error) {
+	return ValidateSchema(ctx, db, RegisteredModels()...)
+}
+
+// ValidateSchema 对比models与数据库中的表结构
+func ValidateSchema(ctx context.Context, db *gorm.DB, models ...any) ([]SchemaDrift, error) {
+	db = db.WithContext(ctx)
+	migrator := db.Migrator()
+	var drifts []SchemaDrift
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, errors.Wrapf(err, "db: validate schema %T error", model)
+		}
+		s := stmt.Schema
+		if !migrator.HasTable(model) {
+			drifts = append(drifts, SchemaDrift{Table: s.Table, Kind: DriftMissingTable})
+			continue
+		}
+		columnTypes, err := migrator.ColumnTypes(model)
+		if err != nil {
+			return nil, errors.Wrapf(err, "db: validate schema %s error", s.Table)
+		}
+		actual := make(map[string]gorm.ColumnType, len(columnTypes))
+		for _, ct := range columnTypes {
+			actual[strings.ToLower(ct.Name())] = ct
+		}
+		for _, f := range s.Fields {
+			if f.DBName == "" || f.IgnoreMigration {
+				continue
+			}
+			ct, ok := actual[strings.ToLower(f.DBName)]
+			if !ok {
+				drifts = append(drifts, SchemaDrift{Table: s.Table, Column: f.DBName, Kind: DriftMissingColumn,
+					Expected: migrator.FullDataTypeOf(f).SQL})
+				continue
+			}
+			drifts = append(drifts, columnDrifts(migrator, s, f, ct)...)
+		}
+		indexes := s.ParseIndexes()
+		for _, name := range SortedKeys(indexes) {
+			if !migrator.HasIndex(model, name) {
+				drifts = append(drifts, SchemaDrift{Table: s.Table, Index: name, Kind: DriftMissingIndex,
+					Expected: indexColumns(indexes[name])})
+			}
+		}
+	}
+	return drifts, nil
+}
+
+// columnDrifts 按 gorm Migrator.MigrateColumn 的规则比较列的类型、长度和是否允许NULL
+func columnDrifts(migrator gorm.Migrator, s *schema.Schema, f *schema.Field, ct gorm.ColumnType) []SchemaDrift {
+	var drifts []SchemaDrift
+	expected := strings.TrimSpace(strings.ToLower(migrator.FullDataTypeOf(f).SQL))
+	actualType := strings.ToLower(ct.DatabaseTypeName())
+	sameType := strings.HasPrefix(expected, actualType)
+	for _, alias := range migrator.GetTypeAliases(actualType) {
+		if strings.HasPrefix(expected, alias) {
+			sameType = true
+		}
+	}
+	if !f.PrimaryKey && !sameType {
+		drifts = append(drifts, SchemaDrift{Table: s.Table, Column: f.DBName, Kind: DriftTypeMismatch,
+			Expected: migrator.FullDataTypeOf(f).SQL, Actual: actualType})
+	} else if length, ok := ct.Length(); ok && length > 0 && f.Size > 0 && length != int64(f.Size) {
+		drifts = append(drifts, SchemaDrift{Table: s.Table, Column: f.DBName, Kind: DriftSizeMismatch,
+			Expected: fmt.Sprint(f.Size), Actual: fmt.Sprint(length)})
+	}
+	if nullable, ok := ct.Nullable(); ok && nullable && f.NotNull && !f.PrimaryKey {
+		drifts = append(drifts, SchemaDrift{Table: s.Table, Column: f.DBName, Kind: DriftNullable,
+			Expected: "NOT NULL", Actual: "NULL"})
+	}
+	return drifts
+}
+
+func indexColumns(idx schema.Index) string {
+	columns := make([]string, 0, len(idx.Fields))
+	for _, f := range idx.Fields {
+		columns = append(columns, f.DBName)
+	}
+	return strings.Join(columns, ",")
+}
@@ -0,0 +1,83 @@
+package gormx
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// SelectAs 与 SelectByMap 相同，但只查询D中存在的列并直接扫描到D，接口层不需要再从模型逐个字段复制：
+//
+//	type UserBrief struct {
+//		ID       int64
+//		Nickname string `gorm:"column:nick_name"`
+//	}
+//	briefs, err := gormx.SelectAs[User, UserBrief](ctx, &userRepo, map[string]any{"status": 1})
+//
+// D的字段按列名（column标签或字段名转蛇形）对应模型的列，模型中不存在的列返回 ErrUnknownColumn，
+// 不需要的字段用 `gorm:"-"` 忽略。结果不经过缓存；模型中带有 mask 标签的列同样脱敏，带有 encrypt 标签的列不支持
+func SelectAs[T, D any](ctx context.Context, repo *BaseRepo[T], condition map[string]any) ([]D, error) {
+	ms, err := repo.gormSchema()
+	if err != nil {
+		return nil, err
+	}
+	ds, err := parseDTOSchema[D](repo.GormDB)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := encryptedFields(ms)
+	masked := maskedFields(ms)
+	var (
+		columns   []string
+		dtoMasked = make(map[*schema.Field]string)
+	)
+	for _, f := range ds.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		mf := schemaColumn(ms, f.DBName)
+		if mf == nil {
+			return nil, errors.Wrapf(ErrUnknownColumn, "db: select %s as %s, field %s column: %s", repo.StructName, ds.Name, f.Name, f.DBName)
+		}
+		if _, ok := encrypted[mf.DBName]; ok {
+			return nil, errors.Errorf("db: select %s as %s error, encrypted column %s is not supported", repo.StructName, ds.Name, mf.DBName)
+		}
+		if rule, ok := masked[mf]; ok {
+			dtoMasked[f] = rule
+		}
+		columns = append(columns, mf.DBName)
+	}
+	if len(columns) == 0 {
+		return nil, errors.Errorf("db: select %s as %s error, no columns", repo.StructName, ds.Name)
+	}
+
+	var (
+		m   T
+		res []D
+	)
+	query := repo.readDB(ctx).Model(&m).Select(columns).Scopes(repo.notDeleted).Scopes(whereCond(repo.columnKeys(condition)))
+	if err = withStatementTimeout(ctx, withIndexHints(ctx, query)).Scan(&res).Error; err != nil {
+		return nil, errors.Wrapf(err, "db: select %s as %s error, condition: %+v", repo.StructName, ds.Name, condition)
+	}
+	if len(dtoMasked) > 0 && maskingFromCtx(ctx) {
+		if p, ok := maskingPlugin(repo.GormDB); ok {
+			if err = p.maskFields(ctx, dtoMasked, reflect.ValueOf(res)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
+
+// parseDTOSchema 按db的命名策略解析D
+func parseDTOSchema[D any](db *gorm.DB) (*schema.Schema, error) {
+	var d D
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&d); err != nil {
+		return nil, errors.Wrapf(err, "db: parse %T schema error", d)
+	}
+	return stmt.Schema, nil
+}
@@ -0,0 +1,57 @@
+package gormx
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// SelectChan 根据条件查询，逐行扫描并通过通道返回，适合流水线式的消费者
+// 数据通道关闭后从错误通道读取结果（nil表示成功）；ctx取消时停止扫描并返回ctx的错误
+// condition里的key兼容驼峰和蛇形
+//
+//	rows, errc := repo.SelectChan(ctx, condition)
+//	for m := range rows {
+//		...
+//	}
+//	if err := <-errc; err != nil {
+//		return err
+//	}
+//
+// 注：扫描期间会一直占用一个数据库连接，消费者需要及时读取或取消ctx
+func (b *BaseRepo[T]) SelectChan(ctx context.Context, condition map[string]any) (<-chan *T, <-chan error) {
+	out := make(chan *T)
+	errc := make(chan error, 1)
+	c := b.columnKeys(condition)
+	go func() {
+		defer close(errc)
+		defer close(out)
+		errc <- b.scanChan(ctx, c, out)
+	}()
+	return out, errc
+}
+
+func (b *BaseRepo[T]) scanChan(ctx context.Context, condition map[string]any, out chan<- *T) error {
+	var m T
+	db := b.readDB(ctx)
+	rows, err := db.Model(&m).Scopes(b.notDeleted).Scopes(whereCond(condition)).Rows()
+	if err != nil {
+		return errors.Wrapf(err, "db: select %s chan error, condition: %v", b.StructName, condition)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		row := new(T)
+		if err = db.ScanRows(rows, row); err != nil {
+			return errors.Wrapf(err, "db: select %s chan error, scan row", b.StructName)
+		}
+		select {
+		case out <- row:
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "db: select %s chan canceled", b.StructName)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return errors.Wrapf(err, "db: select %s chan error, condition: %v", b.StructName, condition)
+	}
+	return nil
+}
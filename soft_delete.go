@@ -0,0 +1,55 @@
+package gormx
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+// softDeleteField 模型的软删除字段：嵌入 ModelBaseInfo 时为 deleted 列，gorm为 gorm.DeletedAt 类型的字段，没有时返回nil
+func softDeleteField(s *schema.Schema) (field *schema.Field, gormDeletedAt bool) {
+	for _, f := range s.Fields {
+		if f.DBName != "" && f.FieldType == deletedAtType {
+			return f, true
+		}
+	}
+	if f := s.LookUpField(softDeleteColumn); f != nil && f.DBName != "" {
+		return f, false
+	}
+	return nil, false
+}
+
+// notDeletedSQL 未删除的条件，alias为表别名，用于手写的SQL；模型没有软删除字段时返回空
+func notDeletedSQL(s *schema.Schema, quote func(string) string, alias string) (string, []any) {
+	f, gormDeletedAt := softDeleteField(s)
+	if f == nil {
+		return "", nil
+	}
+	column := quote(f.DBName)
+	if alias != "" {
+		column = quote(alias) + "." + column
+	}
+	if gormDeletedAt {
+		return column + " IS NULL", nil
+	}
+	return column + " != ?", []any{Deleted}
+}
+
+// notDeleted 过滤已删除记录的scope：嵌入 ModelBaseInfo 的模型为 deleted != 2；
+// 使用 gorm.DeletedAt 的模型查询带有Model时由gorm加上 deleted_at IS NULL，只指定了Table时在这里加上；都没有时不过滤
+func (b *BaseRepo[T]) notDeleted(db *gorm.DB) *gorm.DB {
+	ms := b.Schema()
+	switch {
+	case ms.SoftDeleteColumn == "":
+		return db
+	case ms.GormDeletedAt:
+		if db.Statement.Model != nil {
+			return db
+		}
+		return db.Where(ms.SoftDeleteColumn + " IS NULL")
+	}
+	return db.Where(ms.SoftDeleteColumn+" !=?", Deleted)
+}
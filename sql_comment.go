@@ -0,0 +1,177 @@
+package gormx
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type contextSQLCommentKey struct{}
+
+// WithSQLComment 在ctx内的语句的注释中添加 key='value'，需要注册 SQLCommentPlugin：
+//
+//	ctx = gormx.WithSQLComment(ctx, "route", "/orders/:id")
+func WithSQLComment(ctx context.Context, key, value string) context.Context {
+	tags := sqlCommentsFromCtx(ctx)
+	next := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		next[k] = v
+	}
+	next[key] = value
+	return context.WithValue(ctx, contextSQLCommentKey{}, next)
+}
+
+func sqlCommentsFromCtx(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(contextSQLCommentKey{}).(map[string]string)
+	return tags
+}
+
+// SQLCommentPlugin gorm插件，按 sqlcommenter 的格式在每条语句末尾添加注释，
+// 慢查询日志、pg_stat_activity、performance_schema 中可以看到语句来自哪个服务和trace：
+//
+//	db.Use(gormx.SQLCommentPlugin{
+//		Tags: map[string]string{"application": "orders"},
+//		FromContext: func(ctx context.Context) map[string]string {
+//			return map[string]string{"traceparent": traceparent(ctx)}
+//		},
+//	})
+//
+//	SELECT * FROM `orders` WHERE `id` = ? /*application='orders',traceparent='00-abc-def-01'*/
+//
+// 同名的key优先级：WithSQLComment > FromContext > Tags，值为空的key忽略。
+// 注释中包含trace等每次请求都不同的值时，开启 PrepareStmt 会让预处理语句的缓存失效；
+// pg_stat_statements 会把注释去掉后再归并，不影响统计
+type SQLCommentPlugin struct {
+	Tags        map[string]string
+	FromContext func(ctx context.Context) map[string]string
+}
+
+func (SQLCommentPlugin) Name() string {
+	return "gormx:sql_comment"
+}
+
+func (p SQLCommentPlugin) Initialize(db *gorm.DB) error {
+	pool := &commentPool{ConnPool: db.ConnPool, plugin: p}
+	db.ConnPool = pool
+	db.Statement.ConnPool = pool
+	return nil
+}
+
+// comment 生成注释，没有标签时返回空
+func (p SQLCommentPlugin) comment(ctx context.Context) string {
+	tags := make(map[string]string, len(p.Tags))
+	for k, v := range p.Tags {
+		tags[k] = v
+	}
+	if p.FromContext != nil {
+		for k, v := range p.FromContext(ctx) {
+			tags[k] = v
+		}
+	}
+	for k, v := range sqlCommentsFromCtx(ctx) {
+		tags[k] = v
+	}
+	items := make([]string, 0, len(tags))
+	for _, k := range SortedKeys(tags) {
+		if tags[k] == "" {
+			continue
+		}
+		items = append(items, sqlCommentEscape(k)+"='"+sqlCommentEscape(tags[k])+"'")
+	}
+	if len(items) == 0 {
+		return ""
+	}
+	return "/*" + strings.Join(items, ",") + "*/"
+}
+
+// sqlCommentEscape 按 sqlcommenter 的规范URL编码，编码后不会出现 ' 和 */
+func sqlCommentEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// withComment 在语句末尾（结尾的分号之前）添加注释
+func (p SQLCommentPlugin) withComment(ctx context.Context, query string) string {
+	c := p.comment(ctx)
+	if c == "" {
+		return query
+	}
+	q := strings.TrimRight(query, " \t\n;")
+	return q + " " + c + query[len(q):]
+}
+
+// commentPool 包装连接池，开启的事务同样添加注释
+type commentPool struct {
+	gorm.ConnPool
+	plugin SQLCommentPlugin
+}
+
+func (p *commentPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return p.ConnPool.PrepareContext(ctx, p.plugin.withComment(ctx, query))
+}
+
+func (p *commentPool) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return p.ConnPool.ExecContext(ctx, p.plugin.withComment(ctx, query), args...)
+}
+
+func (p *commentPool) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return p.ConnPool.QueryContext(ctx, p.plugin.withComment(ctx, query), args...)
+}
+
+func (p *commentPool) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return p.ConnPool.QueryRowContext(ctx, p.plugin.withComment(ctx, query), args...)
+}
+
+// BeginTx 实现 gorm.ConnPoolBeginner
+func (p *commentPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	var (
+		tx  gorm.ConnPool
+		err error
+	)
+	switch beginner := p.ConnPool.(type) {
+	case gorm.TxBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	case gorm.ConnPoolBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	default:
+		return nil, gorm.ErrInvalidTransaction
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &commentTx{commentPool: commentPool{ConnPool: tx, plugin: p.plugin}}, nil
+}
+
+// GetDBConn 实现 gorm.GetDBConnector
+func (p *commentPool) GetDBConn() (*sql.DB, error) {
+	if sqlDB, ok := p.ConnPool.(*sql.DB); ok {
+		return sqlDB, nil
+	}
+	if c, ok := p.ConnPool.(gorm.GetDBConnector); ok {
+		return c.GetDBConn()
+	}
+	return nil, gorm.ErrInvalidDB
+}
+
+// commentTx 事务内的语句添加注释，实现 gorm.TxCommitter
+type commentTx struct {
+	commentPool
+}
+
+func (t *commentTx) Commit() error {
+	return t.ConnPool.(gorm.TxCommitter).Commit()
+}
+
+func (t *commentTx) Rollback() error {
+	return t.ConnPool.(gorm.TxCommitter).Rollback()
+}
+
+// GetDBConn 内层连接池支持时返回其 *sql.DB，*sql.Tx 无法取得所属的 *sql.DB，返回 gorm.ErrInvalidDB
+func (t *commentTx) GetDBConn() (*sql.DB, error) {
+	if c, ok := t.ConnPool.(interface{ GetDBConn() (*sql.DB, error) }); ok {
+		return c.GetDBConn()
+	}
+	return nil, gorm.ErrInvalidDB
+}
@@ -0,0 +1,83 @@
+package gormx
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+var identRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?( (?i:asc|desc))?$`)
+
+// SQLTemplate 具名SQL片段模板，基于 text/template，渲染结果交给 RawSelect / RawExec 执行
+//
+// 模板内可用的函数：
+//   - bind：输出占位符 ? 并记录参数，切片参数由gorm展开，例如 id IN {{bind .ids}}
+//   - ident：输出经过校验的列名（可带 asc/desc），用于 ORDER BY 等无法使用占位符的位置
+//
+// 片段之间通过 {{template "name" .}} 引用，条件块使用 {{if}} / {{with}}。
+// 参数值不要直接用 {{.x}} 输出，否则会有SQL注入风险
+//
+// 示例：
+//
+//	tpl := gormx.NewSQLTemplate()
+//	_ = tpl.Define("where", `WHERE deleted = 1 {{if .status}}AND status = {{bind .status}}{{end}}`)
+//	_ = tpl.Define("report", `SELECT * FROM orders {{template "where" .}} ORDER BY {{ident .orderBy}}`)
+//	sql, args, err := tpl.Render("report", map[string]any{"status": 2, "orderBy": "id desc"})
+//	res, err := repo.RawSelect(ctx, sql, args...)
+type SQLTemplate struct {
+	mu   sync.RWMutex
+	root *template.Template
+}
+
+func NewSQLTemplate() *SQLTemplate {
+	return &SQLTemplate{root: template.New("").Funcs(sqlTemplateFuncs(nil))}
+}
+
+func sqlTemplateFuncs(args *[]any) template.FuncMap {
+	return template.FuncMap{
+		"bind": func(v any) string {
+			if args != nil {
+				*args = append(*args, v)
+			}
+			return "?"
+		},
+		"ident": func(s string) (string, error) {
+			if !identRegexp.MatchString(s) {
+				return "", errors.Errorf("invalid identifier %q", s)
+			}
+			return s, nil
+		},
+	}
+}
+
+// Define 定义具名片段，同名片段会被覆盖
+func (s *SQLTemplate) Define(name, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.root.New(name).Parse(text); err != nil {
+		return errors.Wrapf(err, "sql template: parse %s error", name)
+	}
+	return nil
+}
+
+// Render 渲染具名片段，返回SQL以及按占位符顺序排列的参数
+func (s *SQLTemplate) Render(name string, params any) (string, []any, error) {
+	s.mu.RLock()
+	t, err := s.root.Clone()
+	s.mu.RUnlock()
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "sql template: clone error")
+	}
+	var (
+		args []any
+		sb   strings.Builder
+	)
+	t.Funcs(sqlTemplateFuncs(&args))
+	if err = t.ExecuteTemplate(&sb, name, params); err != nil {
+		return "", nil, errors.Wrapf(err, "sql template: render %s error, params: %+v", name, params)
+	}
+	return strings.TrimSpace(sb.String()), args, nil
+}
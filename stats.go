@@ -0,0 +1,181 @@
+package gormx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RequestStats 一次请求内数据库操作的统计，通过 WithRequestStats 放入ctx，由 StatsPlugin 和缓存累加
+type RequestStats struct {
+	queries     atomic.Int64
+	dbTime      atomic.Int64
+	rowsRead    atomic.Int64
+	rowsWritten atomic.Int64
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+}
+
+// StatsSummary RequestStats 的快照
+type StatsSummary struct {
+	Queries     int64
+	DBTime      time.Duration
+	RowsRead    int64
+	RowsWritten int64
+	CacheHits   int64
+	CacheMisses int64
+}
+
+type contextStatsKey struct{}
+
+// WithRequestStats 在ctx中开始统计，ctx中已有统计时直接返回
+func WithRequestStats(ctx context.Context) (context.Context, *RequestStats) {
+	if s := RequestStatsFromCtx(ctx); s != nil {
+		return ctx, s
+	}
+	s := &RequestStats{}
+	return context.WithValue(ctx, contextStatsKey{}, s), s
+}
+
+// RequestStatsFromCtx ctx中的统计，没有时返回nil
+func RequestStatsFromCtx(ctx context.Context) *RequestStats {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(contextStatsKey{}).(*RequestStats)
+	return s
+}
+
+// Summary 当前的统计值
+func (s *RequestStats) Summary() StatsSummary {
+	return StatsSummary{
+		Queries:     s.queries.Load(),
+		DBTime:      time.Duration(s.dbTime.Load()),
+		RowsRead:    s.rowsRead.Load(),
+		RowsWritten: s.rowsWritten.Load(),
+		CacheHits:   s.cacheHits.Load(),
+		CacheMisses: s.cacheMisses.Load(),
+	}
+}
+
+func (s StatsSummary) String() string {
+	return fmt.Sprintf("queries=%d;db_time=%s;rows_read=%d;rows_written=%d;cache_hits=%d;cache_misses=%d",
+		s.Queries, s.DBTime, s.RowsRead, s.RowsWritten, s.CacheHits, s.CacheMisses)
+}
+
+// LogValue 实现 slog.LogValuer，例如 logger.Info("request done", "db", stats.Summary())
+func (s StatsSummary) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int64("queries", s.Queries),
+		slog.Duration("db_time", s.DBTime),
+		slog.Int64("rows_read", s.RowsRead),
+		slog.Int64("rows_written", s.RowsWritten),
+		slog.Int64("cache_hits", s.CacheHits),
+		slog.Int64("cache_misses", s.CacheMisses),
+	)
+}
+
+// recordCache 记录一次缓存命中或未命中，ctx中没有统计时忽略
+func recordCache(ctx context.Context, hit bool) {
+	s := RequestStatsFromCtx(ctx)
+	if s == nil {
+		return
+	}
+	if hit {
+		s.cacheHits.Add(1)
+	} else {
+		s.cacheMisses.Add(1)
+	}
+}
+
+const statsStartKey = "gormx:stats_start"
+
+// StatsPlugin gorm插件，把每条SQL的次数、耗时和影响行数累加到ctx中的 RequestStats
+//
+//	db.Use(gormx.StatsPlugin{})
+type StatsPlugin struct{}
+
+func (StatsPlugin) Name() string {
+	return "gormx:stats"
+}
+
+func (p StatsPlugin) Initialize(db *gorm.DB) error {
+	return registerAround(db, "gormx:stats", p.before, p.after)
+}
+
+func (StatsPlugin) before(db *gorm.DB) {
+	if RequestStatsFromCtx(db.Statement.Context) != nil {
+		db.InstanceSet(statsStartKey, time.Now())
+	}
+}
+
+func (StatsPlugin) after(db *gorm.DB) {
+	s := RequestStatsFromCtx(db.Statement.Context)
+	start, ok := db.InstanceGet(statsStartKey)
+	if s == nil || !ok {
+		return
+	}
+	s.queries.Add(1)
+	s.dbTime.Add(int64(time.Since(start.(time.Time))))
+	if db.RowsAffected <= 0 {
+		return
+	}
+	sql := strings.TrimSpace(db.Statement.SQL.String())
+	if len(sql) >= 6 && (strings.EqualFold(sql[:6], "SELECT") || strings.EqualFold(sql[:4], "WITH")) {
+		s.rowsRead.Add(db.RowsAffected)
+	} else {
+		s.rowsWritten.Add(db.RowsAffected)
+	}
+}
+
+// StatsHeader StatsMiddleware 写入响应头的名称
+const StatsHeader = "X-DB-Stats"
+
+// StatsMiddleware http中间件，为每个请求开启统计，在写响应头时把统计写入 StatsHeader，
+// 请求结束后调用report（可以为nil），例如记录日志或上报监控
+//
+//	handler = gormx.StatsMiddleware(handler, func(r *http.Request, s gormx.StatsSummary) {
+//		slog.Info("request done", "path", r.URL.Path, "db", s)
+//	})
+func StatsMiddleware(next http.Handler, report func(r *http.Request, s StatsSummary)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, stats := WithRequestStats(r.Context())
+		sw := &statsResponseWriter{ResponseWriter: w, stats: stats}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		if report != nil {
+			report(r, stats.Summary())
+		}
+	})
+}
+
+// statsResponseWriter 在第一次写入时设置统计响应头
+type statsResponseWriter struct {
+	http.ResponseWriter
+	stats       *RequestStats
+	wroteHeader bool
+}
+
+func (w *statsResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set(StatsHeader, w.stats.Summary().String())
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statsResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *statsResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
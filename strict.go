@@ -0,0 +1,68 @@
+package gormx
+
+import (
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const strictSettingKey = "gormx:strict"
+
+// WithStrict 开启严格模式：map条件和 UpdateByMap 等的updateData中的key必须是模型的字段名或列名（兼容驼峰和蛇形），
+// 否则返回 ErrUnknownColumn，避免拼写错误的列名生成报错难以理解的SQL或者静默地不更新任何列
+//
+// 查询其他表（Table）时不校验
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// schemaColumn 按字段名或列名（兼容驼峰和蛇形）查找模型中有列的字段
+func schemaColumn(s *schema.Schema, name string) *schema.Field {
+	f := s.LookUpField(name)
+	if f == nil {
+		f = s.LookUpField(Camel2Snake(name))
+	}
+	if f == nil || f.DBName == "" {
+		return nil
+	}
+	return f
+}
+
+// checkColumns 严格模式下校验updateData的key
+func (b *BaseRepo[T]) checkColumns(data map[string]any) error {
+	if !b.opts.strict {
+		return nil
+	}
+	for _, k := range SortedKeys(data) {
+		if _, err := b.lookupColumn(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// strictCheck 严格模式下在 whereCond 中校验map条件的key，condExpr 的key只是标签不校验
+func strictCheck(db *gorm.DB, c map[string]any) {
+	if v, ok := db.Get(strictSettingKey); !ok || v != true {
+		return
+	}
+	stmt := db.Statement
+	model := stmt.Model
+	if model == nil {
+		model = stmt.Dest
+	}
+	if model == nil || stmt.Parse(model) != nil || (stmt.Table != "" && stmt.Table != stmt.Schema.Table) {
+		return
+	}
+	for _, k := range SortedKeys(c) {
+		if _, ok := c[k].(condExpr); ok {
+			continue
+		}
+		if schemaColumn(stmt.Schema, k) == nil {
+			_ = db.AddError(errors.Wrapf(ErrUnknownColumn, "db: %s column: %s", stmt.Schema.Name, k))
+			return
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package gormx
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SubQuery 作为map条件的值时生成 col IN (子查询) 或 col NOT IN (子查询)，例如：
+//
+//	paid := orderRepo.SubQueryOf(ctx, "user_id", map[string]any{"status": 2})
+//	users, err := userRepo.SelectByMap(ctx, map[string]any{"id": paid})
+//
+// 条件值直接为 *gorm.DB 时等同于 InSubQuery；使用子查询的条件不走查询缓存
+type SubQuery struct {
+	db  *gorm.DB
+	not bool
+}
+
+// InSubQuery col IN (db)，db需要只查询一列
+func InSubQuery(db *gorm.DB) SubQuery {
+	return SubQuery{db: db}
+}
+
+// NotInSubQuery col NOT IN (db)，与 NOT IN 相同，子查询结果包含NULL时没有记录满足条件
+func NotInSubQuery(db *gorm.DB) SubQuery {
+	return SubQuery{db: db, not: true}
+}
+
+// GormValue 实现 gorm.Valuer，gorm把map条件构造为 col = 值，
+// col = ANY (子查询) 与 IN 等价；(col = ANY (子查询)) IS FALSE 与 NOT IN 等价，mysql和postgres均支持
+func (s SubQuery) GormValue(context.Context, *gorm.DB) clause.Expr {
+	if s.not {
+		return clause.Expr{SQL: "ANY (?) IS FALSE", Vars: []any{s.db}}
+	}
+	return clause.Expr{SQL: "ANY (?)", Vars: []any{s.db}}
+}
+
+// SubQueryOf 查询满足条件的记录的column列作为子查询，会过滤软删除的记录
+// column和condition里的key兼容驼峰和蛇形
+func (b *BaseRepo[T]) SubQueryOf(ctx context.Context, column string, condition map[string]any) SubQuery {
+	var m T
+	if s, err := b.gormSchema(); err == nil {
+		column = columnName(s, column)
+	} else {
+		column = Camel2Snake(column)
+	}
+	db := b.withTransactionCtx(ctx).Model(&m).Select(column).
+		Scopes(b.notDeleted).Scopes(whereCond(b.columnKeys(condition)))
+	return InSubQuery(db)
+}
+
+// hasExprCond 条件中是否包含子查询或表达式条件，这类条件无法按列值缓存
+func hasExprCond(condition map[string]any) bool {
+	for _, v := range condition {
+		switch v.(type) {
+		case SubQuery, condExpr, columnCond:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package gormx
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type contextTimeoutKey struct{}
+
+// WithTimeout 与 context.WithTimeout 相同，同时让ctx内的查询在数据库端也限制执行时间：
+//
+//	ctx, cancel := gormx.WithTimeout(ctx, 3*time.Second)
+//	defer cancel()
+//	rows, total, err := repo.PageSelect(ctx, page, query, args...)
+//
+// mysql 在 SELECT 上添加 /*+ MAX_EXECUTION_TIME(毫秒) */ 提示；postgres 在事务中执行 SET LOCAL statement_timeout，
+// 对事务中之后的语句同样生效，同一个ctx在事务中只执行一次，不在事务中时只依赖ctx的超时（驱动会取消数据库端的查询）
+// 支持 SelectOne、Select、SelectByMap、PageSelect、加锁读和 SelectEach / Iterate
+func WithTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	ctx = context.WithValue(ctx, contextTimeoutKey{}, d)
+	return context.WithTimeout(ctx, d)
+}
+
+// statementTimeout ctx中设置的超时，ctx的截止时间更早时以截止时间为准
+func statementTimeout(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(contextTimeoutKey{}).(time.Duration)
+	if !ok {
+		return 0, false
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		d = min(d, time.Until(deadline))
+	}
+	return max(d, time.Millisecond), true
+}
+
+// maxExecutionTime 实现 gorm.StatementModifier，在 mysql 的 SELECT 之后写入执行时间提示
+type maxExecutionTime time.Duration
+
+func (t maxExecutionTime) ModifyStatement(stmt *gorm.Statement) {
+	c := stmt.Clauses["SELECT"]
+	c.AfterNameExpression = clause.Expr{SQL: fmt.Sprintf("/*+ MAX_EXECUTION_TIME(%d) */", time.Duration(t).Milliseconds())}
+	stmt.Clauses["SELECT"] = c
+}
+
+// Build 实现 clause.Expression，提示在 ModifyStatement 中写入
+func (maxExecutionTime) Build(clause.Builder) {}
+
+// withStatementTimeout 按ctx中的超时限制查询在数据库端的执行时间
+func withStatementTimeout(ctx context.Context, db *gorm.DB) *gorm.DB {
+	d, ok := statementTimeout(ctx)
+	if !ok {
+		return db
+	}
+	switch db.Dialector.Name() {
+	case "mysql":
+		db = db.Clauses(maxExecutionTime(d))
+	case "postgres":
+		if tx, inTx := ctx.Value(contextTxKey{}).(*gorm.DB); inTx {
+			deadline, _ := ctx.Deadline()
+			setLocalTimeout(tx, db, d.Milliseconds(), deadline)
+		}
+	}
+	return db
+}
+
+const statementTimeoutSettingKey = "gormx:statement_timeout"
+
+// txStatementTimeout 事务中最后一次 SET LOCAL statement_timeout 的值及对应的ctx截止时间，记录在事务的Statement上
+type txStatementTimeout struct {
+	tx       *gorm.DB
+	ms       int64
+	deadline time.Time
+}
+
+// setLocalTimeout 在事务上设置 statement_timeout，与上一次设置的值相同时不再执行，每条语句省去一次往返；
+// 超时按剩余时间计算，每次都会变小，截止时间相同时沿用上一次的值，超出的部分由ctx取消
+func setLocalTimeout(tx, db *gorm.DB, ms int64, deadline time.Time) {
+	last, _ := tx.Statement.Settings.Load(statementTimeoutSettingKey)
+	prev, _ := last.(*txStatementTimeout)
+	if prev != nil && prev.tx == tx && prev.ms > 0 && (prev.ms == ms || !deadline.IsZero() && prev.deadline.Equal(deadline)) {
+		return
+	}
+	// SET 不支持绑定参数，毫秒数为整数，直接拼接
+	sql := fmt.Sprintf("SET LOCAL statement_timeout = %d", ms)
+	if err := db.Session(&gorm.Session{NewDB: true}).Exec(sql).Error; err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	if prev != nil && prev.tx != tx {
+		// prev 是外层事务的记录，SAVEPOINT 中的设置释放后对外层生效、回滚后恢复，外层的记录不再可信
+		prev.ms = -1
+	}
+	tx.Statement.Settings.Store(statementTimeoutSettingKey, &txStatementTimeout{tx: tx, ms: ms, deadline: deadline})
+}
+
+// ErrDeadlineTooShort ctx剩余的时间不足 WithDeadlineCheck 的下限，语句没有发送到数据库
+var ErrDeadlineTooShort = errors.New("db: deadline too short")
+
+// WithDeadlineCheck 开启截止时间的预检：repo的每个方法在取连接之前检查ctx，
+// ctx已经取消或超时时直接返回，剩余时间不足floor时返回 ErrDeadlineTooShort；
+// 剩余时间同时作为查询在数据库端的执行时间限制，与 WithTimeout 相同。
+// 上游超时引起的级联超时中，注定超时的请求不再占用连接池
+func WithDeadlineCheck(floor time.Duration) Option {
+	return func(o *options) {
+		o.deadlineCheck = true
+		o.deadlineFloor = floor
+	}
+}
+
+// preflight 开启 WithDeadlineCheck 时检查ctx，不满足时返回带有错误的db，后续的操作直接返回该错误
+func (b *BaseRepo[T]) preflight(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if !b.opts.deadlineCheck {
+		return db
+	}
+	fail := func(err error) *gorm.DB {
+		// db可能是ctx中共享的事务，在新的会话上记录错误
+		db = db.Session(&gorm.Session{})
+		_ = db.AddError(err)
+		return db
+	}
+	if err := ctx.Err(); err != nil {
+		return fail(errors.Wrapf(err, "db: %s preflight", b.StructName))
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return db
+	}
+	remaining := time.Until(deadline)
+	if remaining < b.opts.deadlineFloor {
+		return fail(errors.Wrapf(ErrDeadlineTooShort, "db: %s preflight, remaining: %s, floor: %s", b.StructName, remaining, b.opts.deadlineFloor))
+	}
+	if _, ok = ctx.Value(contextTimeoutKey{}).(time.Duration); ok {
+		// 由 withQueryOptions 处理
+		return db
+	}
+	return withStatementTimeout(context.WithValue(ctx, contextTimeoutKey{}, remaining), db)
+}
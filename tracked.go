@@ -0,0 +1,97 @@
+package gormx
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// Tracked 记录加载时的字段快照，Save 只更新被修改过的列，支持把字段改为零值
+//
+// 注：切片、map等引用类型字段需要整体重新赋值才能被识别为修改
+type Tracked[T any] struct {
+	Entity *T
+
+	repo     *BaseRepo[T]
+	snapshot map[string]any
+}
+
+// Track 以m当前的值作为快照开始跟踪
+func (b *BaseRepo[T]) Track(ctx context.Context, m *T) *Tracked[T] {
+	t := &Tracked[T]{Entity: m, repo: b}
+	t.snapshot = t.values(ctx)
+	return t
+}
+
+// SelectOneByPKTracked 根据主键查找并跟踪修改，记录不存在时返回nil
+func (b *BaseRepo[T]) SelectOneByPKTracked(ctx context.Context, pk any) (*Tracked[T], error) {
+	m, err := b.SelectOneByPK(ctx, pk)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	return b.Track(ctx, m), nil
+}
+
+// SelectByMapTracked 根据条件查找并跟踪修改
+func (b *BaseRepo[T]) SelectByMapTracked(ctx context.Context, condition map[string]any) ([]*Tracked[T], error) {
+	rows, err := b.SelectByMap(ctx, condition)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*Tracked[T], 0, len(rows))
+	for _, m := range rows {
+		res = append(res, b.Track(ctx, m))
+	}
+	return res, nil
+}
+
+// Dirty 返回被修改过的列及其当前值
+func (t *Tracked[T]) Dirty(ctx context.Context) map[string]any {
+	dirty := make(map[string]any)
+	for column, v := range t.values(ctx) {
+		if !reflect.DeepEqual(v, t.snapshot[column]) {
+			dirty[column] = v
+		}
+	}
+	return dirty
+}
+
+// Save 只更新被修改过的列，没有修改时不执行SQL；成功后以当前值作为新的快照
+func (t *Tracked[T]) Save(ctx context.Context) (int64, error) {
+	dirty := t.Dirty(ctx)
+	if len(dirty) == 0 {
+		return 0, nil
+	}
+	pk, ok := t.snapshot[t.repo.PrimaryKey]
+	if !ok {
+		return 0, errors.Errorf("db: save tracked %s error, primary key %s not found", t.repo.StructName, t.repo.PrimaryKey)
+	}
+	delete(dirty, t.repo.PrimaryKey)
+	n, err := t.repo.UpdateByPKWithMap(ctx, pk, dirty)
+	if err != nil {
+		return 0, err
+	}
+	t.snapshot = t.values(ctx)
+	return n, nil
+}
+
+func (t *Tracked[T]) values(ctx context.Context) map[string]any {
+	s, err := t.repo.gormSchema()
+	if err != nil {
+		return nil
+	}
+	rv := indirectModel(reflect.ValueOf(t.Entity))
+	values := make(map[string]any, len(s.Fields))
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		v, _ := field.ValueOf(ctx, rv)
+		if bs, ok := v.([]byte); ok {
+			v = append([]byte(nil), bs...)
+		}
+		values[field.DBName] = v
+	}
+	return values
+}
@@ -0,0 +1,116 @@
+package gormx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTreeMaxDepth TreeOptions.MaxDepth 为0时的最大层数，防止数据中存在环时无限递归
+const DefaultTreeMaxDepth = 64
+
+// TreeOptions SelectTree 的选项
+type TreeOptions struct {
+	// 父节点列，为空时为 parent_id，兼容驼峰和蛇形
+	ParentColumn string
+	// 最大层数（根节点为第0层），<=0 时为 DefaultTreeMaxDepth
+	MaxDepth int
+	// 同一层内的排序，为空时按主键排序，例如 "sort_no, id"
+	OrderBy string
+	// 不使用 WITH RECURSIVE，逐层查询，用于 mysql 5.7 等不支持递归CTE的数据库
+	// mysql 执行递归CTE报语法错误时也会自动改为逐层查询
+	Emulate bool
+}
+
+// SelectTree 查询以rootPK为根的子树（包括根节点），按层从上到下返回，会过滤软删除的节点
+// 软删除的节点及其子树都不会返回；根节点不存在时返回空
+func (b *BaseRepo[T]) SelectTree(ctx context.Context, rootPK any, opts TreeOptions) ([]*T, error) {
+	if opts.ParentColumn == "" {
+		opts.ParentColumn = "parent_id"
+	}
+	if s, err := b.gormSchema(); err == nil {
+		opts.ParentColumn = columnName(s, opts.ParentColumn)
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultTreeMaxDepth
+	}
+	if opts.OrderBy == "" {
+		opts.OrderBy = b.GormDB.Statement.Quote(b.PrimaryKey)
+	}
+	if opts.Emulate {
+		return b.selectTreeByLevel(ctx, rootPK, opts)
+	}
+	res, err := b.selectTreeCTE(ctx, rootPK, opts)
+	if err != nil && b.GormDB.Dialector.Name() == "mysql" && strings.Contains(err.Error(), "Error 1064") {
+		return b.selectTreeByLevel(ctx, rootPK, opts)
+	}
+	return res, err
+}
+
+func (b *BaseRepo[T]) selectTreeCTE(ctx context.Context, rootPK any, opts TreeOptions) ([]*T, error) {
+	db := b.readDB(ctx)
+	quote := func(s string) string { return db.Statement.Quote(s) }
+	s, err := b.gormSchema()
+	if err != nil {
+		return nil, err
+	}
+	table, pk := quote(b.tableName()), quote(b.PrimaryKey)
+	// 未删除的条件，模型没有软删除字段时为恒真
+	rootCond, rootArgs := notDeletedSQL(s, quote, "t")
+	childCond, childArgs := notDeletedSQL(s, quote, "c")
+	if rootCond == "" {
+		rootCond, childCond = "1 = 1", "1 = 1"
+	}
+	sql := fmt.Sprintf(`WITH RECURSIVE gormx_tree AS (
+SELECT t.*, 0 AS gormx_depth FROM %[1]s t WHERE t.%[2]s = ? AND %[3]s
+UNION ALL
+SELECT c.*, p.gormx_depth + 1 FROM %[1]s c JOIN gormx_tree p ON c.%[4]s = p.%[2]s WHERE %[5]s AND p.gormx_depth < ?
+) SELECT * FROM gormx_tree ORDER BY gormx_depth, %[6]s`, table, pk, rootCond, quote(opts.ParentColumn), childCond, opts.OrderBy)
+
+	args := append(append(append([]any{rootPK}, rootArgs...), childArgs...), opts.MaxDepth)
+	res, err := b.find(db.Raw(sql, args...))
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: select %s tree error, root: %v", b.StructName, rootPK)
+	}
+	return res, nil
+}
+
+// selectTreeByLevel 逐层查询子节点，每层一条 IN 查询
+func (b *BaseRepo[T]) selectTreeByLevel(ctx context.Context, rootPK any, opts TreeOptions) ([]*T, error) {
+	var m T
+	res, err := b.find(b.readDB(ctx).Model(&m).Scopes(b.notDeleted).Where(map[string]any{b.PrimaryKey: rootPK}))
+	if err != nil {
+		return nil, errors.Wrapf(err, "db: select %s tree error, root: %v", b.StructName, rootPK)
+	}
+	visited := make(map[string]struct{})
+	level := res
+	for depth := 0; depth < opts.MaxDepth && len(level) > 0; depth++ {
+		parents := make([]any, 0, len(level))
+		for _, node := range level {
+			pk, ok := b.pkValue(ctx, node)
+			if !ok {
+				continue
+			}
+			// 数据中存在环时不重复展开
+			if _, seen := visited[cacheKeyPart(pk)]; seen {
+				continue
+			}
+			visited[cacheKeyPart(pk)] = struct{}{}
+			parents = append(parents, pk)
+		}
+		if len(parents) == 0 {
+			break
+		}
+		// 每层从新的db开始，避免上一层的条件累加
+		children, err := b.find(b.readDB(ctx).Model(&m).Scopes(b.notDeleted).Where(map[string]any{opts.ParentColumn: parents}).
+			Order(opts.OrderBy))
+		if err != nil {
+			return nil, errors.Wrapf(err, "db: select %s tree error, root: %v, depth: %d", b.StructName, rootPK, depth+1)
+		}
+		res = append(res, children...)
+		level = children
+	}
+	return res, nil
+}
@@ -0,0 +1,246 @@
+package gormx
+
+import (
+	"context"
+	"database/sql"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// TxPropagation 嵌套调用 InTxPropagation 时与外层事务的关系
+type TxPropagation int8
+
+const (
+	// TxNested 已有事务时创建SAVEPOINT，fn出错只回滚到SAVEPOINT，外层事务可以继续；没有事务时开启新事务
+	TxNested TxPropagation = iota
+	// TxRequired 已有事务时直接加入，fn出错时由外层事务决定是否回滚；没有事务时开启新事务
+	TxRequired
+	// TxRequiresNew 总是开启独立的新事务，与外层事务各自提交或回滚
+	// 注：新事务会占用另一个连接，连接池过小时可能死锁
+	TxRequiresNew
+	// TxNotSupported 在事务之外执行fn，fn内的db操作立即生效，不受外层事务回滚影响，例如写审计日志
+	TxNotSupported
+)
+
+// InTxPropagation 按传播方式执行fn，fn内通过ctx参与事务
+func (b *BaseRepo[T]) InTxPropagation(ctx context.Context, propagation TxPropagation, fn func(ctx context.Context) error) error {
+	outer, inTx := ctx.Value(contextTxKey{}).(*gorm.DB)
+	if propagation == TxNotSupported {
+		// 挂起外层事务
+		ctx = context.WithValue(ctx, contextTxKey{}, nil)
+		return fn(context.WithValue(ctx, contextTxHooksKey{}, nil))
+	}
+	if inTx && propagation == TxRequired {
+		return fn(ctx)
+	}
+	if inTx && propagation == TxNested {
+		// gorm在已有事务上调用Transaction时使用SAVEPOINT
+		return runTx(ctx, outer, txHooksFromCtx(ctx), fn)
+	}
+	return runTx(ctx, b.GormDB.WithContext(ctx), nil, fn)
+}
+
+// runTx 在db上开启事务（db本身是事务时为SAVEPOINT）并处理事务回调
+// parent为外层事务的回调，SAVEPOINT成功时回调合并到外层，等外层提交后再执行
+func runTx(ctx context.Context, db *gorm.DB, parent *txHooks, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	if dryRunFromCtx(ctx) != nil {
+		// DryRun 不开启事务，fn内的SQL照常记录
+		return fn(ctx)
+	}
+	hooks := &txHooks{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		txCtx := context.WithValue(ctx, contextTxKey{}, tx)
+		return fn(context.WithValue(txCtx, contextTxHooksKey{}, hooks))
+	}, opts...)
+	switch {
+	case err != nil:
+		hooks.run(ctx, hooks.afterRollback)
+	case parent != nil:
+		parent.merge(hooks)
+	default:
+		hooks.run(ctx, hooks.afterCommit)
+	}
+	return err
+}
+
+type contextTxHooksKey struct{}
+
+// txHooks 事务提交或回滚后执行的回调
+type txHooks struct {
+	mu            sync.Mutex
+	afterCommit   []func(ctx context.Context)
+	afterRollback []func(ctx context.Context)
+	// marks 事务提交后需要标记的 TrackWrites，见 markOnCommit
+	marks map[*writeTracker]time.Duration
+}
+
+func txHooksFromCtx(ctx context.Context) *txHooks {
+	hooks, _ := ctx.Value(contextTxHooksKey{}).(*txHooks)
+	return hooks
+}
+
+func (h *txHooks) merge(child *txHooks) {
+	child.mu.Lock()
+	defer child.mu.Unlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.afterCommit = append(h.afterCommit, child.afterCommit...)
+	h.afterRollback = append(h.afterRollback, child.afterRollback...)
+}
+
+func (h *txHooks) run(ctx context.Context, fns []func(ctx context.Context)) {
+	h.mu.Lock()
+	fns = append([]func(ctx context.Context){}, fns...)
+	h.mu.Unlock()
+	for _, fn := range fns {
+		fn(ctx)
+	}
+}
+
+// AfterCommit 注册在ctx中的事务提交后执行的回调，例如发布事件、失效缓存，事务回滚时不会执行
+// 在SAVEPOINT内注册时，等最外层事务提交后才执行；ctx中没有事务时立即执行
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	hooks := txHooksFromCtx(ctx)
+	if hooks == nil {
+		fn(ctx)
+		return
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.afterCommit = append(hooks.afterCommit, fn)
+}
+
+// AfterRollback 注册在ctx中的事务（或SAVEPOINT）回滚后执行的回调；ctx中没有事务时不会执行
+func AfterRollback(ctx context.Context, fn func(ctx context.Context)) {
+	hooks := txHooksFromCtx(ctx)
+	if hooks == nil {
+		return
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.afterRollback = append(hooks.afterRollback, fn)
+}
+
+// InTxWithOptions 以指定的隔离级别或只读模式开启事务，例如：
+//
+//	repo.InTxWithOptions(ctx, sql.TxOptions{Isolation: sql.LevelSerializable}, fn)
+//	repo.InTxWithOptions(ctx, sql.TxOptions{ReadOnly: true}, fn)
+//
+// ctx中已经存在事务时无法修改隔离级别，按 TxNested 执行
+func (b *BaseRepo[T]) InTxWithOptions(ctx context.Context, opts sql.TxOptions, fn func(ctx context.Context) error) error {
+	if _, inTx := ctx.Value(contextTxKey{}).(*gorm.DB); inTx {
+		return b.InTxPropagation(ctx, TxNested, fn)
+	}
+	return runTx(ctx, b.GormDB.WithContext(ctx), nil, fn, &opts)
+}
+
+// RetryPolicy InTxWithRetry 的重试策略
+type RetryPolicy struct {
+	// 总执行次数，<=0 时为 DefaultTxMaxAttempts
+	MaxAttempts int
+	// 第attempt次失败后的等待时间（从1开始），为空时使用 ExponentialBackoff(10ms, 1s)
+	Backoff func(attempt int) time.Duration
+	// 判断错误是否可以重试，为空时使用 IsSerializationFailure
+	RetryableErrors func(err error) bool
+}
+
+const DefaultTxMaxAttempts = 3
+
+// ExponentialBackoff 指数退避，带50%的随机抖动
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base << (attempt - 1)
+		if d <= 0 || d > max {
+			d = max
+		}
+		return d/2 + rand.N(d/2+1)
+	}
+}
+
+// mysql死锁、锁等待超时
+var mysqlRetryableCodes = map[uint64]struct{}{1213: {}, 1205: {}}
+
+// postgres序列化失败、死锁
+var pgRetryableStates = map[string]struct{}{"40001": {}, "40P01": {}}
+
+// IsSerializationFailure 判断是否为死锁、锁等待超时或序列化失败
+// 支持 MySQL 1213/1205 和 Postgres 40001/40P01，不依赖具体的驱动
+func IsSerializationFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if isRetryableMessage(err.Error()) {
+		return true
+	}
+	return matchDBError(err, pgRetryableStates, mysqlRetryableCodes)
+}
+
+// matchDBError 沿错误链查找 Postgres SQLSTATE 或 MySQL 错误码是否在给定集合中
+func matchDBError(err error, states map[string]struct{}, codes map[uint64]struct{}) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		// pgconn.PgError 等实现了 SQLState 的错误
+		if e, ok := err.(interface{ SQLState() string }); ok {
+			if _, ok = states[e.SQLState()]; ok {
+				return true
+			}
+		}
+		// mysql.MySQLError 的错误码字段为 Number
+		if v := Indirect(reflect.ValueOf(err)); v.Kind() == reflect.Struct {
+			if f := v.FieldByName("Number"); f.IsValid() && f.CanUint() {
+				if _, ok := codes[f.Uint()]; ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// InTxWithRetry 与 InTx 相同，但在死锁、序列化失败等可重试的错误时按策略重新执行fn
+//
+// fn可能被执行多次，需要保证除数据库操作外没有副作用；
+// ctx中已经存在事务时不重试，由最外层的事务负责重试
+func (b *BaseRepo[T]) InTxWithRetry(ctx context.Context, fn func(ctx context.Context) error, policy RetryPolicy) error {
+	if _, ok := ctx.Value(contextTxKey{}).(*gorm.DB); ok {
+		return b.InTx(ctx, fn)
+	}
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DefaultTxMaxAttempts
+	}
+	if policy.Backoff == nil {
+		policy.Backoff = ExponentialBackoff(10*time.Millisecond, time.Second)
+	}
+	if policy.RetryableErrors == nil {
+		policy.RetryableErrors = IsSerializationFailure
+	}
+	for attempt := 1; ; attempt++ {
+		err := b.InTx(ctx, fn)
+		if err == nil || !policy.RetryableErrors(err) {
+			return err
+		}
+		if attempt >= policy.MaxAttempts {
+			return errors.Wrapf(err, "db: tx failed after %d attempts", attempt)
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "db: tx retry canceled after %d attempts, last error: %v", attempt, err)
+		case <-time.After(policy.Backoff(attempt)):
+		}
+	}
+}
+
+// isRetryableMessage 兜底识别未暴露错误码的驱动
+func isRetryableMessage(msg string) bool {
+	for _, s := range []string{"Error 1213", "Error 1205", "SQLSTATE 40001", "SQLSTATE 40P01", "deadlock detected"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package gormx
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// UnionQuery SelectUnion 中的一个查询，Table为空时为模型的表
+type UnionQuery struct {
+	Table     string
+	Condition map[string]any
+}
+
+// SelectUnion 把多个结构相同的表（例如热表和归档表）上的查询合并为一个 UNION 查询并分页，page为nil时不分页
+// all为true时使用 UNION ALL，不去重；每个查询都会过滤软删除的记录，condition里的key兼容驼峰和蛇形
+//
+//	rows, total, err := repo.SelectUnion(ctx, &gormx.PageParam{PageNo: 1, PageSize: 20, OrderBy: "create_at DESC"}, true,
+//		gormx.UnionQuery{Condition: condition},
+//		gormx.UnionQuery{Table: "orders_archive", Condition: condition})
+func (b *BaseRepo[T]) SelectUnion(ctx context.Context, page *PageParam, all bool, queries ...UnionQuery) ([]*T, int32, error) {
+	if len(queries) == 0 {
+		return nil, 0, nil
+	}
+	db := b.readDB(ctx)
+	parts := make([]any, 0, len(queries))
+	for _, q := range queries {
+		table := q.Table
+		if table == "" {
+			table = b.tableName()
+		}
+		parts = append(parts, db.Session(&gorm.Session{NewDB: true}).Table(table).Select("*").
+			Scopes(b.notDeleted).Scopes(whereCond(b.columnKeys(q.Condition))))
+	}
+	op := " UNION "
+	if all {
+		op = " UNION ALL "
+	}
+	union := "(" + strings.TrimSuffix(strings.Repeat("?"+op, len(parts)), op) + ") AS gormx_union"
+
+	var total int64
+	if page != nil {
+		// Raw 不会清空已有的参数，每条语句在新的会话上执行
+		if err := db.Session(&gorm.Session{}).Raw("SELECT COUNT(*) FROM "+union, parts...).Scan(&total).Error; err != nil {
+			return nil, 0, errors.Wrapf(err, "db: select %s union count error, queries: %+v", b.StructName, queries)
+		}
+	}
+	sql, args := "SELECT * FROM "+union, parts
+	if page != nil {
+		if page.OrderBy != "" {
+			sql += " ORDER BY " + page.OrderBy
+		}
+		sql += " LIMIT ? OFFSET ?"
+		args = append(args, int(page.PageSize), int(page.PageNo-1)*int(page.PageSize))
+	}
+	res, err := b.find(db.Session(&gorm.Session{}).Raw(sql, args...))
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "db: select %s union error, queries: %+v", b.StructName, queries)
+	}
+	if total == 0 {
+		total = int64(len(res))
+	}
+	return res, int32(total), nil
+}
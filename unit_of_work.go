@@ -0,0 +1,331 @@
+package gormx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type uowState int8
+
+const (
+	uowClean uowState = iota
+	uowNew
+	uowDirty
+	uowRemoved
+)
+
+// uowEntry 工作单元中的一个实体，操作由登记它的repo生成
+type uowEntry struct {
+	entity any
+	table  string
+	state  uowState
+	insert func(ctx context.Context) error
+	update func(ctx context.Context) error
+	remove func(ctx context.Context) error
+	// save 通过工作单元加载的实体，只更新被修改过的列
+	save func(ctx context.Context) error
+	// backup 保存实体当前的值，返回恢复的函数
+	backup func() (restore func())
+}
+
+type uowKey struct {
+	table string
+	pk    string
+}
+
+// UnitOfWork 工作单元，收集多个repo登记的新增、修改、删除，在 Commit 时按依赖顺序在一个事务中写入：
+//
+//	u := gormx.NewUnitOfWork(db)
+//	order, err := orderRepo.SelectOneByPKIn(ctx, u, orderID) // 同一个工作单元内同一主键返回同一个对象
+//	order.Status = Paid                                     // 通过工作单元加载的对象修改后自动更新
+//	itemRepo.RegisterNew(u, &Item{OrderID: order.ID})
+//	couponRepo.RegisterRemoved(u, coupon)
+//	err = u.Commit(ctx)
+//
+// 新增按表的依赖（belongs to、has one/many）先父表后子表，删除先子表后父表，修改在新增之后、删除之前。
+// 领域代码只登记变更，何时写入由调用方决定；工作单元不是并发安全的，Commit 后清空，用于一次业务操作
+type UnitOfWork struct {
+	db       *gorm.DB
+	entries  []*uowEntry
+	index    map[any]*uowEntry
+	identity map[uowKey]any
+	// deps 表 -> 依赖的父表，登记父表时也会记录子表的依赖，子表是否登记过以 known 为准
+	deps  map[string]map[string]struct{}
+	known map[string]bool
+	order []string
+	err   error
+}
+
+// NewUnitOfWork 创建工作单元，db用于开启事务，ctx中已有事务时加入该事务
+func NewUnitOfWork(db *gorm.DB) *UnitOfWork {
+	return &UnitOfWork{
+		db:       db,
+		index:    make(map[any]*uowEntry),
+		identity: make(map[uowKey]any),
+		deps:     make(map[string]map[string]struct{}),
+		known:    make(map[string]bool),
+	}
+}
+
+// addSchema 记录表和表之间的依赖
+func (u *UnitOfWork) addSchema(s *schema.Schema) {
+	if u.known[s.Table] {
+		return
+	}
+	u.known[s.Table] = true
+	u.order = append(u.order, s.Table)
+	for _, rel := range s.Relationships.Relations {
+		if rel.FieldSchema == nil || rel.FieldSchema.Table == s.Table {
+			continue
+		}
+		switch rel.Type {
+		case schema.BelongsTo:
+			u.dependOn(s.Table, rel.FieldSchema.Table)
+		case schema.HasOne, schema.HasMany:
+			u.dependOn(rel.FieldSchema.Table, s.Table)
+		}
+	}
+}
+
+func (u *UnitOfWork) dependOn(child, parent string) {
+	if u.deps[child] == nil {
+		u.deps[child] = make(map[string]struct{})
+	}
+	u.deps[child][parent] = struct{}{}
+}
+
+// tableRanks 按依赖排序后每个表的顺序，父表在前；有循环依赖时剩余的表按登记顺序
+func (u *UnitOfWork) tableRanks() map[string]int {
+	ranks := make(map[string]int, len(u.order))
+	for len(ranks) < len(u.order) {
+		progressed := false
+		for _, table := range u.order {
+			if _, done := ranks[table]; done {
+				continue
+			}
+			ready := true
+			for parent := range u.deps[table] {
+				if !u.known[parent] {
+					// 父表没有参与工作单元
+					continue
+				}
+				if _, done := ranks[parent]; !done {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ranks[table] = len(ranks)
+				progressed = true
+			}
+		}
+		if !progressed {
+			for _, table := range u.order {
+				if _, done := ranks[table]; !done {
+					ranks[table] = len(ranks)
+				}
+			}
+		}
+	}
+	return ranks
+}
+
+// entry 查找或创建实体的登记项
+func (u *UnitOfWork) entry(entity any, table string) (*uowEntry, bool) {
+	if e, ok := u.index[entity]; ok {
+		return e, true
+	}
+	e := &uowEntry{entity: entity, table: table}
+	u.index[entity] = e
+	u.entries = append(u.entries, e)
+	return e, false
+}
+
+func (u *UnitOfWork) forget(e *uowEntry) {
+	delete(u.index, e.entity)
+	u.entries = slices.DeleteFunc(u.entries, func(x *uowEntry) bool { return x == e })
+}
+
+// Commit 在一个事务中按依赖顺序写入所有变更，失败时回滚，并把登记的实体恢复为 Commit 前的值
+// （回填的主键、递增的版本号、跟踪的快照），工作单元保持不变，可以修正后重试；
+// 恢复是对实体的浅拷贝，关联字段中被回填的子对象不会恢复
+func (u *UnitOfWork) Commit(ctx context.Context) error {
+	if u.err != nil {
+		return u.err
+	}
+	if len(u.entries) == 0 {
+		return nil
+	}
+	ranks := u.tableRanks()
+	byRank := func(desc bool) func(a, b *uowEntry) int {
+		return func(a, b *uowEntry) int {
+			if desc {
+				return ranks[b.table] - ranks[a.table]
+			}
+			return ranks[a.table] - ranks[b.table]
+		}
+	}
+	var inserts, updates, removes []*uowEntry
+	for _, e := range u.entries {
+		switch e.state {
+		case uowNew:
+			inserts = append(inserts, e)
+		case uowDirty, uowClean:
+			updates = append(updates, e)
+		case uowRemoved:
+			removes = append(removes, e)
+		}
+	}
+	slices.SortStableFunc(inserts, byRank(false))
+	slices.SortStableFunc(removes, byRank(true))
+
+	restores := make([]func(), 0, len(u.entries))
+	for _, e := range u.entries {
+		restores = append(restores, e.backup())
+	}
+	fn := func(ctx context.Context) error {
+		for _, e := range inserts {
+			if err := e.insert(ctx); err != nil {
+				return err
+			}
+		}
+		for _, e := range updates {
+			switch {
+			case e.save != nil:
+				if err := e.save(ctx); err != nil {
+					return err
+				}
+			case e.state == uowDirty:
+				if err := e.update(ctx); err != nil {
+					return err
+				}
+			}
+		}
+		for _, e := range removes {
+			if err := e.remove(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var err error
+	if outer, ok := ctx.Value(contextTxKey{}).(*gorm.DB); ok {
+		err = runTx(ctx, outer, txHooksFromCtx(ctx), fn)
+	} else {
+		err = runTx(ctx, u.db.WithContext(ctx), nil, fn)
+	}
+	if err != nil {
+		for _, restore := range restores {
+			restore()
+		}
+		return errors.WithMessage(err, "db: commit unit of work")
+	}
+	u.Reset()
+	return nil
+}
+
+// Reset 放弃所有登记的变更和加载的实体
+func (u *UnitOfWork) Reset() {
+	u.entries = nil
+	u.index = make(map[any]*uowEntry)
+	u.identity = make(map[uowKey]any)
+}
+
+// register 在工作单元中登记m，返回登记项，schema解析失败时记录错误由 Commit 返回
+func (b *BaseRepo[T]) register(u *UnitOfWork, m *T) *uowEntry {
+	s, err := b.gormSchema()
+	if err != nil {
+		if u.err == nil {
+			u.err = err
+		}
+		return nil
+	}
+	u.addSchema(s)
+	e, existed := u.entry(m, s.Table)
+	if !existed {
+		e.insert = func(ctx context.Context) error { return b.Insert(ctx, m) }
+		e.update = func(ctx context.Context) error {
+			_, err := b.UpdateByPK(ctx, m)
+			return err
+		}
+		e.remove = func(ctx context.Context) error {
+			pk, ok := b.pkValue(ctx, m)
+			if !ok {
+				return errors.Errorf("db: remove %s error, primary key is zero", b.StructName)
+			}
+			_, err := b.DeleteByPK(ctx, pk)
+			return err
+		}
+		e.backup = func() func() {
+			old := *m
+			return func() { *m = old }
+		}
+	}
+	return e
+}
+
+// RegisterNew 登记新增的记录，Commit 时插入
+func (b *BaseRepo[T]) RegisterNew(u *UnitOfWork, m *T) {
+	if e := b.register(u, m); e != nil {
+		e.state = uowNew
+	}
+}
+
+// RegisterDirty 登记修改过的记录，Commit 时按主键更新非零值字段；通过 SelectOneByPKIn 加载的记录不需要登记
+func (b *BaseRepo[T]) RegisterDirty(u *UnitOfWork, m *T) {
+	if e := b.register(u, m); e != nil && e.state == uowClean && e.save == nil {
+		e.state = uowDirty
+	}
+}
+
+// RegisterRemoved 登记删除的记录，Commit 时按主键删除；登记为新增、还没有写入的记录直接移除
+func (b *BaseRepo[T]) RegisterRemoved(u *UnitOfWork, m *T) {
+	e := b.register(u, m)
+	if e == nil {
+		return
+	}
+	if e.state == uowNew {
+		u.forget(e)
+		return
+	}
+	e.state = uowRemoved
+}
+
+// SelectOneByPKIn 在工作单元内根据主键查找，同一个主键只查询一次并返回同一个对象，
+// 对象的修改在 Commit 时写入（只更新修改过的列）；已登记删除或不存在时返回nil
+func (b *BaseRepo[T]) SelectOneByPKIn(ctx context.Context, u *UnitOfWork, pk any) (*T, error) {
+	s, err := b.gormSchema()
+	if err != nil {
+		return nil, err
+	}
+	key := uowKey{table: s.Table, pk: fmt.Sprint(pk)}
+	if v, ok := u.identity[key]; ok {
+		m := v.(*T)
+		if e := u.index[m]; e != nil && e.state == uowRemoved {
+			return nil, nil
+		}
+		return m, nil
+	}
+	tracked, err := b.SelectOneByPKTracked(ctx, pk)
+	if err != nil || tracked == nil {
+		return nil, err
+	}
+	m := tracked.Entity
+	u.identity[key] = m
+	if e := b.register(u, m); e != nil {
+		e.save = func(ctx context.Context) error {
+			_, err := tracked.Save(ctx)
+			return err
+		}
+		e.backup = func() func() {
+			old, snapshot := *m, tracked.snapshot
+			return func() { *m, tracked.snapshot = old, snapshot }
+		}
+	}
+	return m, nil
+}
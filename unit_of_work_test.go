@@ -0,0 +1,226 @@
+package gormx_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github/flandersRin/gormx"
+	"github/flandersRin/gormx/gormxtest"
+)
+
+type uowCustomer struct {
+	ID     int64 `gorm:"primaryKey"`
+	Name   string
+	Orders []*uowOrder `gorm:"foreignKey:CustomerID"`
+}
+
+type uowOrder struct {
+	ID         int64 `gorm:"primaryKey"`
+	CustomerID int64
+	Customer   *uowCustomer
+	Status     int
+	Items      []*uowItem `gorm:"foreignKey:OrderID"`
+}
+
+type uowItem struct {
+	ID      int64 `gorm:"primaryKey"`
+	OrderID int64
+	Order   *uowOrder
+	Sku     string `gorm:"uniqueIndex"`
+}
+
+type uowRepos struct {
+	db        *gorm.DB
+	customers gormx.BaseRepo[uowCustomer]
+	orders    gormx.BaseRepo[uowOrder]
+	items     gormx.BaseRepo[uowItem]
+	log       *[]string
+}
+
+// newUowRepos 三张表的repo，log 按顺序记录写入的 操作+表名
+func newUowRepos(t *testing.T) uowRepos {
+	db := gormxtest.NewSQLite(t, &uowCustomer{}, &uowOrder{}, &uowItem{})
+	log := new([]string)
+	record := func(op string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			if tx.Error == nil {
+				*log = append(*log, op+" "+tx.Statement.Table)
+			}
+		}
+	}
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("test:record", record("insert")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.Update().After("gorm:update").Register("test:record", record("update")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.Delete().After("gorm:delete").Register("test:record", record("delete")); err != nil {
+		t.Fatal(err)
+	}
+	return uowRepos{
+		db:        db,
+		customers: gormx.NewBaseRepo[uowCustomer](db),
+		orders:    gormx.NewBaseRepo[uowOrder](db),
+		items:     gormx.NewBaseRepo[uowItem](db),
+		log:       log,
+	}
+}
+
+func TestUnitOfWorkOrder(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		// register 按给定顺序登记三张表的记录
+		register []string
+	}{
+		{"parent then child", []string{"customer", "order", "item"}},
+		{"child then parent", []string{"item", "order", "customer"}},
+		{"middle first", []string{"order", "item", "customer"}},
+		{"middle last", []string{"item", "customer", "order"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newUowRepos(t)
+			customer := &uowCustomer{ID: 1, Name: "c"}
+			order := &uowOrder{ID: 1, CustomerID: 1}
+			item := &uowItem{ID: 1, OrderID: 1, Sku: "a"}
+
+			u := gormx.NewUnitOfWork(r.db)
+			for _, table := range tt.register {
+				switch table {
+				case "customer":
+					r.customers.RegisterNew(u, customer)
+				case "order":
+					r.orders.RegisterNew(u, order)
+				case "item":
+					r.items.RegisterNew(u, item)
+				}
+			}
+			if err := u.Commit(ctx); err != nil {
+				t.Fatal(err)
+			}
+			want := []string{"insert uow_customers", "insert uow_orders", "insert uow_items"}
+			if !slices.Equal(*r.log, want) {
+				t.Errorf("insert order: got %v, want %v", *r.log, want)
+			}
+
+			*r.log = nil
+			for _, table := range tt.register {
+				switch table {
+				case "customer":
+					r.customers.RegisterRemoved(u, customer)
+				case "order":
+					r.orders.RegisterRemoved(u, order)
+				case "item":
+					r.items.RegisterRemoved(u, item)
+				}
+			}
+			if err := u.Commit(ctx); err != nil {
+				t.Fatal(err)
+			}
+			want = []string{"delete uow_items", "delete uow_orders", "delete uow_customers"}
+			if !slices.Equal(*r.log, want) {
+				t.Errorf("delete order: got %v, want %v", *r.log, want)
+			}
+		})
+	}
+}
+
+func TestUnitOfWorkCommit(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		run  func(t *testing.T, r uowRepos, u *gormx.UnitOfWork)
+	}{
+		{"identity map", func(t *testing.T, r uowRepos, u *gormx.UnitOfWork) {
+			first, err := r.orders.SelectOneByPKIn(ctx, u, 1)
+			if err != nil || first == nil {
+				t.Fatalf("load: %+v, %v", first, err)
+			}
+			second, err := r.orders.SelectOneByPKIn(ctx, u, 1)
+			if err != nil || second != first {
+				t.Fatalf("same pk returned another object: %p, %p, %v", first, second, err)
+			}
+			first.Status = 2
+			*r.log = nil
+			if err = u.Commit(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"update uow_orders"}; !slices.Equal(*r.log, want) {
+				t.Errorf("writes: got %v, want %v", *r.log, want)
+			}
+			got, err := r.orders.SelectOneByPK(ctx, 1)
+			if err != nil || got.Status != 2 || got.CustomerID != 1 {
+				t.Errorf("after commit: %+v, %v", got, err)
+			}
+		}},
+		{"removed after load", func(t *testing.T, r uowRepos, u *gormx.UnitOfWork) {
+			item, err := r.items.SelectOneByPKIn(ctx, u, 1)
+			if err != nil || item == nil {
+				t.Fatalf("load: %+v, %v", item, err)
+			}
+			r.items.RegisterRemoved(u, item)
+			if again, err := r.items.SelectOneByPKIn(ctx, u, 1); err != nil || again != nil {
+				t.Errorf("removed entity returned: %+v, %v", again, err)
+			}
+			if err = u.Commit(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := r.items.SelectOneByPK(ctx, 1); err != nil || got != nil {
+				t.Errorf("after commit: %+v, %v", got, err)
+			}
+		}},
+		{"rollback restores entities", func(t *testing.T, r uowRepos, u *gormx.UnitOfWork) {
+			order, err := r.orders.SelectOneByPKIn(ctx, u, 1)
+			if err != nil || order == nil {
+				t.Fatalf("load: %+v, %v", order, err)
+			}
+			order.Status = 3
+			ok := &uowItem{OrderID: 1, Sku: "b"}
+			dup := &uowItem{OrderID: 1, Sku: "a"} // 与已有记录的 sku 冲突
+			r.items.RegisterNew(u, ok)
+			r.items.RegisterNew(u, dup)
+			if err = u.Commit(ctx); err == nil {
+				t.Fatal("commit with duplicate sku succeeded")
+			}
+			if ok.ID != 0 || dup.ID != 0 || order.Status != 3 {
+				t.Errorf("entities not restored: %+v, %+v, %+v", ok, dup, order)
+			}
+			if rows, err := r.items.SelectByMap(ctx, map[string]any{"sku": "b"}); err != nil || len(rows) != 0 {
+				t.Errorf("rolled back insert is visible: %d, %v", len(rows), err)
+			}
+
+			// 修正后重试，之前回滚的修改和新增都会写入
+			dup.Sku = "c"
+			if err = u.Commit(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if ok.ID == 0 || dup.ID == 0 {
+				t.Errorf("ids not assigned: %+v, %+v", ok, dup)
+			}
+			got, err := r.orders.SelectOneByPK(ctx, 1)
+			if err != nil || got.Status != 3 {
+				t.Errorf("order after retry: %+v, %v", got, err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newUowRepos(t)
+			if err := r.customers.Insert(ctx, &uowCustomer{ID: 1, Name: "c"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.orders.Insert(ctx, &uowOrder{ID: 1, CustomerID: 1, Status: 1}); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.items.Insert(ctx, &uowItem{ID: 1, OrderID: 1, Sku: "a"}); err != nil {
+				t.Fatal(err)
+			}
+			tt.run(t, r, gormx.NewUnitOfWork(r.db))
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package gormx
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ErrStaleObject 乐观锁更新失败：记录已被其他请求修改（或已不存在）
+var ErrStaleObject = errors.New("db: stale object")
+
+// gormxTagSettings 解析字段上的 gormx 标签，key为大写，例如 `gormx:"version"`
+func gormxTagSettings(field *schema.Field) map[string]string {
+	return schema.ParseTagSetting(field.Tag.Get("gormx"), ";")
+}
+
+// versionField 带有 `gormx:"version"` 或 `gorm:"version"` 标签的整数字段
+func (b *BaseRepo[T]) versionField() *schema.Field {
+	s, err := b.gormSchema()
+	if err != nil {
+		return nil
+	}
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		_, gormxVersion := gormxTagSettings(field)["VERSION"]
+		_, gormVersion := field.TagSettings["VERSION"]
+		if gormxVersion || gormVersion {
+			return field
+		}
+	}
+	return nil
+}
+
+// updateByPKVersioned UPDATE ... SET version = version + 1 WHERE pk = ? AND version = ?
+// 成功后t中的版本号同步加一，影响行数为0时返回 ErrStaleObject
+func (b *BaseRepo[T]) updateByPKVersioned(ctx context.Context, t *T, vf *schema.Field) (int64, error) {
+	rv := indirectModel(reflect.ValueOf(t))
+	version, _ := vf.ValueOf(ctx, rv)
+	db := b.withTransactionCtx(ctx)
+	column := db.Statement.Quote(vf.DBName)
+
+	updateData := b.rowColumns(ctx, t, false)
+	delete(updateData, b.PrimaryKey)
+	updateData[vf.DBName] = gorm.Expr(column + " + 1")
+
+	tx := db.Model(t).Where(column+" = ?", version).Updates(updateData)
+	if err := tx.Error; err != nil {
+		return 0, errors.Wrapf(err, "db: update %s by pk error, param: %+v", b.StructName, t)
+	}
+	if tx.RowsAffected == 0 {
+		return 0, errors.Wrapf(ErrStaleObject, "db: update %s by pk error, version: %v, param: %+v", b.StructName, version, t)
+	}
+	fv := Indirect(rv.FieldByIndex(vf.StructField.Index))
+	if fv.CanInt() {
+		fv.SetInt(fv.Int() + 1)
+	} else if fv.CanUint() {
+		fv.SetUint(fv.Uint() + 1)
+	}
+	return tx.RowsAffected, nil
+}